@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/stackgen-cli/stackgen/internal/detect"
 	"github.com/stackgen-cli/stackgen/internal/generator"
 	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/yamledit"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
@@ -15,36 +19,216 @@ import (
 )
 
 var addCmd = &cobra.Command{
-	Use:   "add [datastore|runtime] [type]",
-	Short: "Add a datastore or runtime to existing configuration",
-	Long: `Add a new datastore or runtime to an existing stackgen configuration.
+	Use:   "add [datastore|runtime|tracing] [type...]",
+	Short: "Add a datastore, runtime or tracing backend to existing configuration",
+	Long: `Add a new datastore, runtime or tracing backend to an existing stackgen configuration.
+
+Several components can be added at once by listing more types, or more
+categories each followed by their types; the files are then regenerated
+only once, after the last component.
 
 Examples:
   stackgen add datastore postgres    # Add PostgreSQL
   stackgen add datastore redis       # Add Redis
+  stackgen add datastore postgres --no-password  # Passwordless (insecure)
+  stackgen add datastore postgres --replicas 2   # Primary + 2 read replicas
+  stackgen add datastore redis --expose=false    # Reachable from containers only
+  stackgen add datastore postgres --port 0       # Docker picks a free host port
+  stackgen add datastore postgres --internal-only-network  # Tiered network, no host access
+  stackgen add datastore postgres --update --tag 15  # Bump an existing datastore
+  stackgen add datastore mysql --version lts  # Friendly version, resolved to 8.4
+  stackgen add datastore postgres --readonly-root  # read_only: true plus tmpfs mounts
+  stackgen add datastore neo4j --ulimit nofile=65535:65535  # Raise a ulimit
+  stackgen add datastore redis --cap-drop ALL --security-opt no-new-privileges:true  # Harden
+  stackgen add datastore postgres --shm-size 1g  # Larger /dev/shm
+  stackgen add datastore postgres --seed-data ./seed.sql  # Load fixtures on first start
+  stackgen add datastore postgres --databases app,analytics  # Several databases in one server
+  stackgen add datastore redis --mem-swappiness 0 --oom-kill-disable  # Memory-pressure testing
+  stackgen add datastore postgres --expose-metrics  # postgres_exporter on :9187
+  stackgen add datastore redis --persistence none  # Throwaway cache on tmpfs
   stackgen add runtime node          # Add Node.js runtime
   stackgen add runtime go            # Add Go runtime
+  stackgen add runtime go --toolchain-version 1.23  # Build on golang:1.23-alpine
+  stackgen add runtime node --init --stop-grace-period 30s  # Clean shutdowns
+  stackgen add runtime --from-dir ./service-a  # Detect runtime from a directory
+  stackgen add runtime go --context ./services/api  # Code outside <name>/
+  stackgen add runtime node --dockerfile Dockerfile.dev  # Use your own Dockerfile
+  stackgen add runtime python --port-mode none  # Worker without published ports
+  stackgen add runtime node --port-mode range   # 3000-3009 for --scale
+  stackgen add runtime go --publish-range 10000-10100/udp  # WebRTC media ports
+  stackgen add runtime node --env-file .env --env-file .env.local  # Local overrides
+  stackgen add runtime node --package-manager pnpm  # Install with pnpm
+  stackgen add runtime python --dep-tool uv     # Install from uv.lock
+  stackgen add runtime go --replicas-behind-proxy 3  # 3 replicas behind nginx
+  stackgen add runtime go --runtime-env LOG_LEVEL=debug --sentry  # Extra env
+  stackgen add runtime go --healthcheck-path /readyz  # HTTP healthcheck
+  stackgen add runtime go --healthcheck-cmd "grpc_health_probe -addr=:9090"  # Custom probe
+  stackgen add runtime node --expose-debug-port  # Attach a debugger on 9229
+  stackgen add tracing jaeger        # Add Jaeger tracing backend
+  stackgen add datastore postgres redis runtime go node  # Several at once
   stackgen add                       # Interactive mode`,
 	RunE: runAdd,
 }
 
+var (
+	addNoPassword       bool
+	addReplicas         int
+	addInit             bool
+	addStopGracePeriod  string
+	addFromDir          string
+	addRuntimeEnv       []string
+	addSentry           bool
+	addExpose           bool
+	addToolchainVersion string
+	addPortMode         string
+	addPortRange        int
+	addContext          string
+	addDockerfile       string
+	addPort             int
+	addInternalNetwork  bool
+	addUpdate           bool
+	addTag              string
+	addFramework        string
+	addProxyReplicas    int
+	addReadOnlyRoot     bool
+	addUlimits          []string
+	addCapAdd           []string
+	addCapDrop          []string
+	addSecurityOpt      []string
+	addShmSize          string
+	addSeedData         string
+	addVersion          string
+	addHealthCmd        string
+	addHealthPath       string
+	addHealthInterval   string
+	addDatabases        []string
+	addDatabaseRoles    bool
+	addMemSwappiness    int
+	addOOMKillDisable   bool
+	addExposeMetrics    bool
+	addPublishRanges    []string
+	addEnvFiles         []string
+	addPersistence      string
+	addCharset          string
+	addCollation        string
+	addPackageManager   string
+	addDepTool          string
+	addExposeDebugPort  bool
+
+	// addInitOption is --init, or nil when the flag was not given so the
+	// compose file keeps the Docker default
+	addInitOption *bool
+	// addExposeOption is --expose, or nil to keep the default (published)
+	addExposeOption *bool
+	// addPortSet records an explicit --port, since 0 is a valid value
+	addPortSet bool
+	// addMemSwappinessOption and addOOMKillDisableOption are nil unless
+	// --mem-swappiness and --oom-kill-disable were given
+	addMemSwappinessOption  *int
+	addOOMKillDisableOption *bool
+	// addDeferSave is set while several components are added in one run,
+	// so that they are saved and regenerated once at the end
+	addDeferSave bool
+)
+
 func init() {
 	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().BoolVar(&addInit, "init", false, "run an init process (tini) as PID 1 to forward signals and reap zombies")
+	addCmd.Flags().StringVar(&addStopGracePeriod, "stop-grace-period", "", "time to wait for shutdown before SIGKILL (e.g. 30s)")
+	addCmd.Flags().StringArrayVar(&addRuntimeEnv, "runtime-env", nil, "extra KEY=VALUE environment variable for the runtime (repeatable)")
+	addCmd.Flags().BoolVar(&addSentry, "sentry", false, "add a SENTRY_DSN placeholder to .env for error tracking")
+	addCmd.Flags().StringVar(&addToolchainVersion, "toolchain-version", "", "toolchain version for the runtime's base image, e.g. 1.23 for Go (default: stackgen's pinned version)")
+	addCmd.Flags().StringVar(&addPortMode, "port-mode", "", "runtime port exposure: host (default), none (exposed to other services only), or range for scaled replicas")
+	addCmd.Flags().IntVar(&addPortRange, "port-range", 0, "number of host ports published with --port-mode range (default 10)")
+	addCmd.Flags().StringVar(&addHealthCmd, "healthcheck-cmd", "", "shell command run as the runtime's healthcheck, e.g. a gRPC or TCP probe")
+	addCmd.Flags().StringVar(&addHealthPath, "healthcheck-path", "", "HTTP path probed by the runtime's healthcheck (default /health)")
+	addCmd.Flags().StringVar(&addHealthInterval, "healthcheck-interval", "", "time between runtime healthchecks, e.g. 30s (default 10s)")
+	addCmd.Flags().StringVar(&addContext, "context", "", "build context for the runtime, relative to the output directory (default: the service name)")
+	addCmd.Flags().StringVar(&addDockerfile, "dockerfile", "", "Dockerfile path relative to the build context (e.g. Dockerfile.dev); stackgen then does not generate one")
+	addCmd.Flags().StringVar(&addFromDir, "from-dir", "", "detect runtime and framework from an existing project directory")
+	addCmd.Flags().IntVar(&addPort, "port", 0, "host port (default: next free from the standard port; for datastores 0 lets Docker pick an ephemeral port)")
+	addCmd.Flags().StringVar(&addVersion, "version", "", "friendly datastore version resolved to a concrete tag: latest, stable, lts or a major version like 16")
+	addCmd.Flags().StringVar(&addTag, "tag", "", "datastore image tag (default: stackgen's pinned tag)")
+	addCmd.Flags().StringVar(&addFramework, "framework", "", "runtime framework (default: the project default or a prompt)")
+	addCmd.Flags().IntVar(&addProxyReplicas, "replicas-behind-proxy", 0, "run this many runtime replicas load-balanced by a generated nginx proxy on the runtime's port")
+	addCmd.Flags().BoolVar(&addUpdate, "update", false, "update tag, version, port, framework or healthcheck of a component already in the configuration instead of failing")
+	addCmd.Flags().BoolVar(&addInternalNetwork, "internal-only-network", false, "put the datastore on an internal network reachable only from runtimes (implies --expose=false)")
+	addCmd.Flags().StringVar(&addSeedData, "seed-data", "", "fixture file (.sql, .sql.gz or .sh) loaded after schema scripts on first start (postgres, mysql)")
+	addCmd.Flags().StringSliceVar(&addDatabases, "databases", nil, "extra databases created on first start, each with a <NAME>_DATABASE_URL (postgres only)")
+	addCmd.Flags().BoolVar(&addDatabaseRoles, "database-roles", false, "with --databases, create a login role owning each database")
+	addCmd.Flags().IntVar(&addMemSwappiness, "mem-swappiness", 0, "mem_swappiness (0-100) of the service, e.g. 0 to avoid swapping (default: Docker's)")
+	addCmd.Flags().BoolVar(&addOOMKillDisable, "oom-kill-disable", false, "set oom_kill_disable so the kernel does not OOM-kill the service's processes")
+	addCmd.Flags().BoolVar(&addExposeMetrics, "expose-metrics", false, "add a Prometheus exporter sidecar publishing the datastore's metrics (postgres, mysql, redis)")
+	addCmd.Flags().StringArrayVar(&addPublishRanges, "publish-range", nil, "publish a contiguous port range, e.g. 8000-8010:8000-8010 or 10000-10100/udp (repeatable)")
+	addCmd.Flags().StringArrayVar(&addEnvFiles, "env-file", nil, "env file the runtime loads instead of .env, in order of precedence, e.g. --env-file .env --env-file .env.local (repeatable)")
+	addCmd.Flags().StringVar(&addPersistence, "persistence", "", "redis persistence: none (tmpfs, nothing kept), rdb, aof or both (default: --appendonly yes)")
+	addCmd.Flags().StringVar(&addDepTool, "dep-tool", "", "python dependency tool used by the generated Dockerfiles: pip (default, requirements.txt), poetry, pipenv or uv")
+	addCmd.Flags().StringVar(&addCharset, "charset", "", "mysql server character set (default for new datastores: utf8mb4)")
+	addCmd.Flags().StringVar(&addCollation, "collation", "", "mysql server collation (default for new datastores: utf8mb4_unicode_ci)")
+	addCmd.Flags().BoolVar(&addExposeDebugPort, "expose-debug-port", false, "publish the runtime's debugger port (go 2345, node 9229, python 5678, java 5005) and start the app with the debugger enabled")
+	addCmd.Flags().StringVar(&addPackageManager, "package-manager", "", "node package manager used by the generated Dockerfiles: npm (default), pnpm or yarn")
+	addCmd.Flags().StringVar(&addShmSize, "shm-size", "", "datastore /dev/shm size, e.g. 256m (default: 256m for postgres, Docker's 64m otherwise)")
+	addCmd.Flags().StringArrayVar(&addCapAdd, "cap-add", nil, "Linux capability to add to the datastore, e.g. SYS_NICE or IPC_LOCK (repeatable)")
+	addCmd.Flags().StringArrayVar(&addCapDrop, "cap-drop", nil, "Linux capability to drop from the datastore, e.g. ALL (repeatable)")
+	addCmd.Flags().StringArrayVar(&addSecurityOpt, "security-opt", nil, "datastore security option, e.g. no-new-privileges:true (repeatable)")
+	addCmd.Flags().StringArrayVar(&addUlimits, "ulimit", nil, "datastore ulimit as name=soft[:hard], e.g. nofile=65535:65535 (repeatable; overrides stackgen's defaults)")
+	addCmd.Flags().BoolVar(&addReadOnlyRoot, "readonly-root", false, "run the datastore with a read-only root filesystem and tmpfs for the paths it writes")
+	addCmd.Flags().BoolVar(&addExpose, "expose", true, "publish datastore ports on the host (--expose=false keeps them on the compose network only)")
+	addCmd.Flags().IntVar(&addReplicas, "replicas", 0, "number of streaming read replicas (postgres only)")
+	addCmd.Flags().BoolVar(&addNoPassword, "no-password", false, "run the datastore without authentication (insecure, local dev only)")
 }
 
 func runAdd(cmd *cobra.Command, args []string) error {
+	if cmd.Flags().Changed("init") {
+		addInitOption = &addInit
+	}
+	if cmd.Flags().Changed("expose") {
+		addExposeOption = &addExpose
+	}
+	if cmd.Flags().Changed("mem-swappiness") {
+		addMemSwappinessOption = &addMemSwappiness
+		if err := models.ValidateMemSwappiness(addMemSwappinessOption); err != nil {
+			return fmt.Errorf("--mem-swappiness: %w", err)
+		}
+	}
+	if cmd.Flags().Changed("oom-kill-disable") {
+		addOOMKillDisableOption = &addOOMKillDisable
+	}
+	if err := models.ValidateToolchainVersion(addToolchainVersion); err != nil {
+		return fmt.Errorf("--toolchain-version: %w", err)
+	}
+	for _, spec := range addPublishRanges {
+		if _, err := models.ParsePortRange(spec); err != nil {
+			return fmt.Errorf("--publish-range: %w", err)
+		}
+	}
+	for _, file := range addEnvFiles {
+		if strings.TrimSpace(file) == "" {
+			return fmt.Errorf("--env-file needs a file path")
+		}
+	}
+	addPortSet = cmd.Flags().Changed("port")
+	if addPortSet && addPort < 0 {
+		return fmt.Errorf("--port must be 0 or a positive port number")
+	}
+
 	// Find config file
 	configPath := cfgFile
 	if configPath == "" {
 		configPath = "stackgen.yaml"
 	}
 
+	// add saves the updated project back to the config file
+	if configPath == stdinConfig {
+		return fmt.Errorf("add cannot update a config read from stdin; pass a file with --config")
+	}
+
 	// Check if stackgen.yaml exists, if not check for docker-compose.yml
 	var project *models.Project
 	
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// Try to infer from docker-compose.yml
-		if _, err := os.Stat("docker-compose.yml"); os.IsNotExist(err) {
+		// Try to infer from an existing compose file
+		if generator.FindComposeFile(".") == "" {
 			return fmt.Errorf("no configuration found. Run 'stackgen init' first")
 		}
 		// Create minimal project from directory name
@@ -65,28 +249,116 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if addFromDir != "" {
+		if addContext != "" {
+			return fmt.Errorf("--context cannot be combined with --from-dir, which uses the directory as the context")
+		}
+		if len(args) > 0 && !isRuntimeCategory(args[0]) {
+			return fmt.Errorf("--from-dir only applies to runtimes")
+		}
+		return addRuntimeFromDir(project, configPath, addFromDir)
+	}
+
 	// Interactive or argument-based
 	if len(args) < 2 {
+		if err := requireInteractive("use 'stackgen add <datastore|runtime|tracing> <type>'"); err != nil {
+			return err
+		}
 		return interactiveAdd(project, configPath)
 	}
 
-	category := strings.ToLower(args[0])
-	typeName := strings.ToLower(args[1])
+	components, err := parseAddComponents(args)
+	if err != nil {
+		return err
+	}
+	if len(components) == 1 {
+		return addComponent(project, configPath, components[0])
+	}
+
+	// Flags naming a single port or directory cannot apply to every component
+	for _, flag := range []string{"port", "context", "dockerfile"} {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--%s applies to a single component; add the components one at a time", flag)
+		}
+	}
+	addDeferSave = true
+	for _, c := range components {
+		if err := addComponent(project, configPath, c); err != nil {
+			return fmt.Errorf("%s %s: %w", c.category, c.typeName, err)
+		}
+	}
+	addDeferSave = false
+	return saveAndRegenerate(project, configPath)
+}
+
+// addSpec is one component named on the command line
+type addSpec struct {
+	category string
+	typeName string
+}
+
+// parseAddComponents splits arguments into components: each category is
+// followed by one or more types, e.g. "datastore postgres redis runtime go"
+func parseAddComponents(args []string) ([]addSpec, error) {
+	var components []addSpec
+	category := ""
+	expectType := false
+	for _, arg := range args {
+		arg = strings.ToLower(arg)
+		if c := addCategory(arg); c != "" && !expectType {
+			category = c
+			expectType = true
+			continue
+		}
+		if category == "" {
+			return nil, fmt.Errorf("unknown category: %s. Use: datastore, runtime or tracing", arg)
+		}
+		components = append(components, addSpec{category: category, typeName: arg})
+		expectType = false
+	}
+	if expectType {
+		return nil, fmt.Errorf("%s needs a type, e.g. 'stackgen add %s <type>'", category, category)
+	}
+	return components, nil
+}
 
-	switch category {
+// addCategory normalizes a category or one of its aliases, returning ""
+// for anything else
+func addCategory(arg string) string {
+	switch arg {
 	case "datastore", "ds", "d":
-		return addDatastore(project, configPath, models.DatastoreType(typeName))
+		return "datastore"
 	case "runtime", "rt", "r":
-		return addRuntime(project, configPath, models.RuntimeType(typeName))
+		return "runtime"
+	case "tracing", "t":
+		return "tracing"
+	}
+	return ""
+}
+
+func addComponent(project *models.Project, configPath string, c addSpec) error {
+	switch c.category {
+	case "datastore":
+		return addDatastore(project, configPath, models.DatastoreType(c.typeName))
+	case "runtime":
+		return addRuntime(project, configPath, models.RuntimeType(c.typeName))
 	default:
-		return fmt.Errorf("unknown category: %s. Use: datastore or runtime", category)
+		return addTracing(project, configPath, c.typeName)
 	}
 }
 
+func isRuntimeCategory(category string) bool {
+	switch strings.ToLower(category) {
+	case "runtime", "rt", "r":
+		return true
+	}
+	return false
+}
+
 func interactiveAdd(project *models.Project, configPath string) error {
 	prompt := promptui.Select{
 		Label: "What do you want to add?",
-		Items: []string{"Datastore", "Runtime"},
+		Items: []string{"Datastore", "Runtime", "Tracing (Jaeger)"},
 	}
 
 	idx, _, err := prompt.Run()
@@ -94,6 +366,10 @@ func interactiveAdd(project *models.Project, configPath string) error {
 		return err
 	}
 
+	if idx == 2 {
+		return addTracing(project, configPath, "jaeger")
+	}
+
 	if idx == 0 {
 		// Add datastore
 		items := make([]string, 0)
@@ -135,48 +411,309 @@ func interactiveAdd(project *models.Project, configPath string) error {
 
 func addDatastore(project *models.Project, configPath string, dsType models.DatastoreType) error {
 	// Check if already exists
-	for _, ds := range project.Datastores {
+	for i, ds := range project.Datastores {
 		if ds.Type == dsType {
-			return fmt.Errorf("%s is already in the configuration", dsType)
+			if addUpdate {
+				return updateDatastore(project, configPath, &project.Datastores[i])
+			}
+			return fmt.Errorf("%s is already in the configuration; use --update to change its tag or port", dsType)
 		}
 	}
 
+	if addReplicas > 0 && dsType != models.DatastorePostgres {
+		return fmt.Errorf("--replicas is only supported for postgres")
+	}
+
+	ulimits, err := parseUlimits(addUlimits)
+	if err != nil {
+		return err
+	}
+	if addVersion != "" {
+		if addTag != "" {
+			return fmt.Errorf("--version and --tag cannot be combined")
+		}
+		if _, err := models.ResolveVersion(dsType, addVersion); err != nil {
+			return err
+		}
+	}
+
+	seedFile, err := seedDataPath(project, addSeedData)
+	if err != nil {
+		return err
+	}
+	if err := validateDatabasesFlags(dsType); err != nil {
+		return err
+	}
+	if addDatabaseRoles && len(addDatabases) == 0 {
+		return fmt.Errorf("--database-roles needs --databases")
+	}
+	if err := validatePersistenceFlag(dsType); err != nil {
+		return err
+	}
+	charset, collation, err := charsetFlags(dsType)
+	if err != nil {
+		return err
+	}
+	if dsType == models.DatastoreMySQL && charset == "" {
+		charset, collation = models.DefaultMySQLCharset, models.DefaultMySQLCollation
+	}
+	metricsPort, err := metricsPortFlag(project, dsType)
+	if err != nil {
+		return err
+	}
+
 	info := models.GetDatastoreInfo(dsType)
 	
 	// Find available port
 	port := info.DefaultPort
 	usedPorts := make(map[int]bool)
 	for _, ds := range project.Datastores {
-		usedPorts[ds.Port] = true
+		// Ephemeral (0) ports claim nothing on the host
+		if ds.Port == 0 {
+			continue
+		}
+		for i := 0; i <= ds.Replicas; i++ {
+			usedPorts[ds.Port+i] = true
+		}
 	}
-	for usedPorts[port] {
+	for portRangeUsed(usedPorts, port, addReplicas+1) {
 		port++
 	}
+	if addPortSet {
+		port = addPort
+	}
+	tag := addTag
+	if tag == "" && addVersion == "" {
+		tag = getDefaultTag(dsType)
+	}
 
 	ds := models.Datastore{
-		Type:         dsType,
-		Name:         string(dsType),
-		Port:         port,
-		InternalPort: info.DefaultPort,
-		Tag:          getDefaultTag(dsType),
+		Type:            dsType,
+		Name:            string(dsType),
+		Port:            port,
+		InternalPort:    info.DefaultPort,
+		Tag:             tag,
+		Version:         addVersion,
+		NoPassword:      addNoPassword,
+		Replicas:        addReplicas,
+		StopGracePeriod: addStopGracePeriod,
+		Init:            addInitOption,
+		Expose:          addExposeOption,
+		InternalNetwork: addInternalNetwork,
+		ReadOnlyRootFS:  addReadOnlyRoot,
+		Ulimits:         ulimits,
+		CapAdd:          addCapAdd,
+		CapDrop:         addCapDrop,
+		SecurityOpt:     addSecurityOpt,
+		ShmSize:         addShmSize,
+		SeedFile:        seedFile,
+		Databases:       addDatabases,
+		DatabaseRoles:   addDatabaseRoles,
+		MemSwappiness:   addMemSwappinessOption,
+		OOMKillDisable:  addOOMKillDisableOption,
+		MetricsPort:     metricsPort,
+		PortRanges:      addPublishRanges,
+		Persistence:     addPersistence,
+		Charset:         charset,
+		Collation:       collation,
 	}
 	project.Datastores = append(project.Datastores, ds)
 
 	// Save and regenerate
-	if err := saveAndRegenerate(project, configPath); err != nil {
+	if err := saveAdded(project, configPath); err != nil {
 		return err
 	}
 
+	if !ds.HasService() {
+		color.Green("✅ Added %s (./data mounted at /data in runtimes, DATABASE_URL in .env)\n", info.DisplayName)
+		return nil
+	}
+	if !ds.IsExposed() {
+		color.Green("✅ Added %s (internal only, not published on the host)\n", info.DisplayName)
+		return nil
+	}
+	if port == 0 {
+		color.Green("✅ Added %s (ephemeral host port, see 'docker compose port %s %d')\n", info.DisplayName, ds.Name, info.DefaultPort)
+		return nil
+	}
 	color.Green("✅ Added %s (port %d)\n", info.DisplayName, port)
 	return nil
 }
 
+// parseEnvPairs parses KEY=VALUE flag values
+func parseEnvPairs(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	env := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", pair)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// seedDataPath validates a --seed-data path and makes it relative to the
+// output directory, where compose resolves bind mounts. A missing file only
+// warns since it may be written later.
+func seedDataPath(project *models.Project, path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	outputDir := project.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	if !filepath.IsAbs(path) {
+		// Relative to the working directory, like other path flags
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		path = abs
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+	rel, err := filepath.Rel(absOutput, path)
+	if err != nil {
+		return "", fmt.Errorf("--seed-data: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return "", fmt.Errorf("--seed-data %s is a directory", path)
+	case err != nil:
+		color.Yellow("⚠ %s does not exist yet; create it before the datastore's first start", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// runtimeHealthCheck applies the --healthcheck-* flags on top of hc,
+// returning hc itself when none of them is set
+func runtimeHealthCheck(hc *models.RuntimeHealthCheck) (*models.RuntimeHealthCheck, error) {
+	if addHealthCmd == "" && addHealthPath == "" && addHealthInterval == "" {
+		return hc, nil
+	}
+	if addHealthCmd != "" && addHealthPath != "" {
+		return nil, fmt.Errorf("--healthcheck-cmd replaces the HTTP probe and cannot be combined with --healthcheck-path")
+	}
+	if addHealthInterval != "" {
+		if _, err := time.ParseDuration(addHealthInterval); err != nil {
+			return nil, fmt.Errorf("invalid --healthcheck-interval %q, expected a duration like 30s", addHealthInterval)
+		}
+	}
+
+	updated := models.RuntimeHealthCheck{}
+	if hc != nil {
+		updated = *hc
+	}
+	switch {
+	case addHealthCmd != "":
+		updated.Cmd, updated.Path = addHealthCmd, ""
+	case addHealthPath != "":
+		updated.Cmd, updated.Path = "", addHealthPath
+	}
+	if addHealthInterval != "" {
+		updated.Interval = addHealthInterval
+	}
+	return &updated, nil
+}
+
+// parseUlimits parses --ulimit name=soft[:hard] flag values
+func parseUlimits(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	ulimits := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid ulimit %q, expected name=soft[:hard]", pair)
+		}
+		if _, err := generator.ParseUlimit(value); err != nil {
+			return nil, fmt.Errorf("invalid ulimit %s: %w", name, err)
+		}
+		ulimits[name] = value
+	}
+	return ulimits, nil
+}
+
+// portRangeUsed reports whether any of count ports starting at port is taken
+func portRangeUsed(used map[int]bool, port, count int) bool {
+	for i := 0; i < count; i++ {
+		if used[port+i] {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimeContext validates a --context path and normalizes it to a clean,
+// slash-separated path relative to the output directory
+func runtimeContext(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if filepath.IsAbs(dir) {
+		return "", fmt.Errorf("--context must be relative to the output directory, got %s", dir)
+	}
+	return filepath.ToSlash(filepath.Clean(dir)), nil
+}
+
+// runtimeDockerfile validates a --dockerfile path, which compose resolves
+// against the build context. A missing file only warns since it may be
+// written later.
+func runtimeDockerfile(project *models.Project, buildContext, path string) (string, error) {
+	if path == "" {
+		return "Dockerfile", nil
+	}
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("--dockerfile must be relative to the build context, got %s", path)
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+
+	full := filepath.Join(project.OutputDir, buildContext, path)
+	info, err := os.Stat(full)
+	switch {
+	case err == nil && info.IsDir():
+		return "", fmt.Errorf("--dockerfile %s is a directory", full)
+	case err != nil:
+		color.Yellow("⚠ %s does not exist yet; create it before running docker compose build", full)
+	}
+	return path, nil
+}
+
 func addRuntime(project *models.Project, configPath string, rtType models.RuntimeType) error {
 	info := models.GetRuntimeInfo(rtType)
 	
-	// Select framework if multiple available
-	framework := info.Frameworks[0]
-	if len(info.Frameworks) > 1 {
+	if err := models.ValidateDefaultFrameworks(project.DefaultFrameworks); err != nil {
+		return err
+	}
+
+	if addFramework != "" && !isFramework(info, addFramework) {
+		return fmt.Errorf("%s does not support framework %s (available: %s)", rtType, addFramework, strings.Join(info.Frameworks, ", "))
+	}
+	if addUpdate {
+		for i, rt := range project.Runtimes {
+			if rt.Type == rtType {
+				return updateRuntime(project, configPath, &project.Runtimes[i])
+			}
+		}
+	}
+
+	// Select framework if multiple available, unless given or the project
+	// sets a default
+	framework, hasDefault := project.DefaultFrameworks[rtType]
+	if addFramework != "" {
+		framework, hasDefault = addFramework, true
+	}
+	if !hasDefault {
+		framework = info.Frameworks[0]
+	}
+	if !hasDefault && len(info.Frameworks) > 1 && isInteractive() {
 		prompt := promptui.Select{
 			Label: "Select framework",
 			Items: info.Frameworks,
@@ -184,8 +721,74 @@ func addRuntime(project *models.Project, configPath string, rtType models.Runtim
 		_, framework, _ = prompt.Run()
 	}
 
+	buildContext, err := runtimeContext(addContext)
+	if err != nil {
+		return err
+	}
+	return appendRuntime(project, configPath, rtType, framework, string(rtType)+"-app", buildContext)
+}
+
+// addRuntimeFromDir detects the runtime and framework of an existing
+// project directory and adds it with that directory as build context
+func addRuntimeFromDir(project *models.Project, configPath, dir string) error {
+	matches, err := detect.Runtimes(dir)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", dir, err)
+	}
+
+	var match detect.Match
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no supported runtime detected in %s. Use: stackgen add runtime <type>", dir)
+	case 1:
+		match = matches[0]
+	default:
+		if err := requireInteractive(fmt.Sprintf("%s contains several runtimes, use 'stackgen add runtime <type>'", dir)); err != nil {
+			return err
+		}
+		items := make([]string, len(matches))
+		for i, m := range matches {
+			items[i] = fmt.Sprintf("%s [%s]", m.Type, m.Framework)
+		}
+		prompt := promptui.Select{
+			Label: "Several runtimes detected, select one",
+			Items: items,
+		}
+		idx, _, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+		match = matches[idx]
+	}
+	color.Cyan("🔍 Detected %s [%s] in %s\n", match.Type, match.Framework, dir)
+
+	// Build context is relative to the output directory
+	outputDir := project.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+	absDir, _ := filepath.Abs(dir)
+	buildContext, err := filepath.Rel(absOutput, absDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	// Regenerating writes a Dockerfile into the build context
+	if _, err := os.Stat(filepath.Join(absDir, "Dockerfile")); err == nil && !forceWrite && !assumeYes {
+		return fmt.Errorf("%s already has a Dockerfile; use --force or --yes to replace it with a generated one", dir)
+	}
+
+	return appendRuntime(project, configPath, match.Type, match.Framework, sanitizeName(filepath.Base(absDir)), filepath.ToSlash(buildContext))
+}
+
+// appendRuntime adds a runtime under a unique name derived from baseName,
+// using the name as build context unless one is given, then saves and
+// regenerates
+func appendRuntime(project *models.Project, configPath string, rtType models.RuntimeType, framework, baseName, buildContext string) error {
+	info := models.GetRuntimeInfo(rtType)
+
 	// Check for duplicate name
-	baseName := string(rtType) + "-app"
 	name := baseName
 	counter := 1
 	for {
@@ -202,6 +805,40 @@ func addRuntime(project *models.Project, configPath string, rtType models.Runtim
 		counter++
 		name = fmt.Sprintf("%s-%d", baseName, counter)
 	}
+	if buildContext == "" {
+		buildContext = name
+	}
+
+	environment, err := parseEnvPairs(addRuntimeEnv)
+	if err != nil {
+		return err
+	}
+	if err := validatePackageManagerFlag(rtType); err != nil {
+		return err
+	}
+	if err := validateDepToolFlag(rtType); err != nil {
+		return err
+	}
+	if err := models.ValidatePortMode(addPortMode); err != nil {
+		return err
+	}
+	if addProxyReplicas > 1 && addPortMode != "" && addPortMode != models.PortModeHost {
+		return fmt.Errorf("--replicas-behind-proxy publishes the port through the proxy and cannot be combined with --port-mode %s", addPortMode)
+	}
+	dockerfile, err := runtimeDockerfile(project, buildContext, addDockerfile)
+	if err != nil {
+		return err
+	}
+	healthCheck, err := runtimeHealthCheck(nil)
+	if err != nil {
+		return err
+	}
+	if addExposeDebugPort && info.DebugPort == 0 {
+		return fmt.Errorf("--expose-debug-port is not supported for %s", info.DisplayName)
+	}
+	if addSentry {
+		project.Sentry = true
+	}
 
 	// Find available port
 	port := info.DefaultPort
@@ -212,46 +849,387 @@ func addRuntime(project *models.Project, configPath string, rtType models.Runtim
 	for usedPorts[port] {
 		port += 1000
 	}
+	if addPortSet {
+		port = addPort
+	}
+	debugPort := 0
+	if addExposeDebugPort {
+		debugPort = info.DebugPort
+		for _, rt := range project.Runtimes {
+			usedPorts[rt.DebugPort] = true
+		}
+		usedPorts[port] = true
+		for usedPorts[debugPort] {
+			debugPort++
+		}
+	}
 
 	// Build depends_on from datastores
 	var dependsOn []string
 	for _, ds := range project.Datastores {
-		dependsOn = append(dependsOn, ds.Name)
+		if ds.HasService() {
+			dependsOn = append(dependsOn, ds.Name)
+		}
 	}
 
 	rt := models.Runtime{
-		Type:         rtType,
-		Name:         name,
-		Framework:    framework,
-		Port:         port,
-		InternalPort: info.DefaultPort,
-		BuildContext: name,
-		Dockerfile:   "Dockerfile",
-		DependsOn:    dependsOn,
+		Type:            rtType,
+		Name:            name,
+		Framework:       framework,
+		Version:         addToolchainVersion,
+		Port:            port,
+		PortMode:        addPortMode,
+		PortRange:       addPortRange,
+		Replicas:        addProxyReplicas,
+		InternalPort:    info.DefaultPort,
+		BuildContext:    buildContext,
+		Dockerfile:      dockerfile,
+		Environment:     environment,
+		DependsOn:       dependsOn,
+		StopGracePeriod: addStopGracePeriod,
+		Init:            addInitOption,
+		HealthCheck:     healthCheck,
+		MemSwappiness:   addMemSwappinessOption,
+		OOMKillDisable:  addOOMKillDisableOption,
+		PortRanges:      addPublishRanges,
+		EnvFiles:        addEnvFiles,
+		PackageManager:  addPackageManager,
+		DepTool:         addDepTool,
+		DebugPort:       debugPort,
 	}
 	project.Runtimes = append(project.Runtimes, rt)
 
 	// Save and regenerate
-	if err := saveAndRegenerate(project, configPath); err != nil {
+	if err := saveAdded(project, configPath); err != nil {
 		return err
 	}
 
-	color.Green("✅ Added %s [%s] (port %d)\n", info.DisplayName, framework, port)
+	switch {
+	case rt.Replicas > 1:
+		color.Green("✅ Added %s [%s] (%d replicas behind %s on port %d)\n", info.DisplayName, framework, rt.Replicas, rt.Name+"-proxy", port)
+	case addPortMode == models.PortModeNone:
+		color.Green("✅ Added %s [%s] (no published ports)\n", info.DisplayName, framework)
+	case addPortMode == models.PortModeRange:
+		color.Green("✅ Added %s [%s] (ports %d-%d)\n", info.DisplayName, framework, port, port+rt.PortRangeSize()-1)
+	default:
+		color.Green("✅ Added %s [%s] (port %d)\n", info.DisplayName, framework, port)
+	}
+	if debugPort != 0 {
+		fmt.Printf("   Debugger listening on localhost:%d\n", debugPort)
+	}
 	return nil
 }
 
-func saveAndRegenerate(project *models.Project, configPath string) error {
-	// Save stackgen.yaml
-	data, err := yaml.Marshal(project)
+// updateDatastore applies --tag and --port to an existing datastore and
+// regenerates
+func updateDatastore(project *models.Project, configPath string, ds *models.Datastore) error {
+	var changes []string
+	if addVersion != "" && addVersion != ds.Version {
+		changes = append(changes, fmt.Sprintf("version %s → %s", versionLabel(ds.Version), addVersion))
+		ds.Version = addVersion
+		if ds.Digest != "" {
+			ds.Digest = ""
+			changes = append(changes, "digest unpinned")
+		}
+	}
+	if addTag != "" && (addTag != ds.Tag || ds.Version != "") {
+		changes = append(changes, fmt.Sprintf("tag %s → %s", ds.Tag, addTag))
+		ds.Tag = addTag
+		// An explicit tag replaces a friendly version
+		ds.Version = ""
+		if ds.Digest != "" {
+			// The pinned digest belongs to the old tag
+			ds.Digest = ""
+			changes = append(changes, "digest unpinned")
+		}
+	}
+	if addPortSet && addPort != ds.Port {
+		changes = append(changes, fmt.Sprintf("port %d → %d", ds.Port, addPort))
+		ds.Port = addPort
+	}
+	if err := validateDatabasesFlags(ds.Type); err != nil {
+		return err
+	}
+	var added []string
+	for _, name := range addDatabases {
+		if !slices.Contains(ds.Databases, name) {
+			ds.Databases = append(ds.Databases, name)
+			added = append(added, name)
+		}
+	}
+	if len(added) > 0 {
+		changes = append(changes, "databases +"+strings.Join(added, ","))
+		color.Yellow("⚠ Init scripts only run on an empty data volume; create %s by hand or recreate the %s volume", strings.Join(added, ", "), ds.Name+"-data")
+	}
+	if addDatabaseRoles && !ds.DatabaseRoles {
+		changes = append(changes, "database roles")
+		ds.DatabaseRoles = true
+	}
+	if ds.MetricsPort == 0 {
+		metricsPort, err := metricsPortFlag(project, ds.Type)
+		if err != nil {
+			return err
+		}
+		if metricsPort > 0 {
+			changes = append(changes, fmt.Sprintf("metrics on port %d", metricsPort))
+			ds.MetricsPort = metricsPort
+		}
+	}
+	if ranges, added := addPortRanges(ds.PortRanges); len(added) > 0 {
+		changes = append(changes, "port ranges +"+strings.Join(added, ","))
+		ds.PortRanges = ranges
+	}
+	if err := validatePersistenceFlag(ds.Type); err != nil {
+		return err
+	}
+	if addPersistence != "" && addPersistence != ds.Persistence {
+		changes = append(changes, fmt.Sprintf("persistence %s → %s", versionLabel(ds.Persistence), addPersistence))
+		ds.Persistence = addPersistence
+	}
+	charset, collation, err := charsetFlags(ds.Type)
+	if err != nil {
+		return err
+	}
+	if charset != "" && (charset != ds.Charset || collation != ds.Collation) {
+		changes = append(changes, fmt.Sprintf("charset %s → %s", versionLabel(charsetLabel(ds.Charset, ds.Collation)), charsetLabel(charset, collation)))
+		ds.Charset, ds.Collation = charset, collation
+	}
+	return saveUpdate(project, configPath, ds.Name, changes)
+}
+
+// metricsPortFlag returns the host port for --expose-metrics: the
+// exporter's standard port, or the next one free of other exporters. It is
+// 0 without the flag.
+func metricsPortFlag(project *models.Project, dsType models.DatastoreType) (int, error) {
+	if !addExposeMetrics {
+		return 0, nil
+	}
+	port, ok := generator.MetricsExporterPort(dsType)
+	if !ok {
+		return 0, fmt.Errorf("--expose-metrics is only supported for postgres, mysql and redis")
+	}
+	used := make(map[int]bool)
+	for _, ds := range project.Datastores {
+		used[ds.MetricsPort] = true
+	}
+	for used[port] {
+		port++
+	}
+	return port, nil
+}
+
+// validatePackageManagerFlag checks --package-manager against the runtime
+func validatePackageManagerFlag(rtType models.RuntimeType) error {
+	if addPackageManager == "" {
+		return nil
+	}
+	if rtType != models.RuntimeNode {
+		return fmt.Errorf("--package-manager is only supported for node runtimes")
+	}
+	return models.ValidatePackageManager(addPackageManager)
+}
+
+// validateDepToolFlag checks --dep-tool against the runtime
+func validateDepToolFlag(rtType models.RuntimeType) error {
+	if addDepTool == "" {
+		return nil
+	}
+	if rtType != models.RuntimePython {
+		return fmt.Errorf("--dep-tool is only supported for python runtimes")
+	}
+	return models.ValidateDepTool(addDepTool)
+}
+
+// charsetFlags checks --charset and --collation against the datastore
+// type, taking the character set from the collation when only that is
+// given. Both are empty when neither flag was.
+func charsetFlags(dsType models.DatastoreType) (string, string, error) {
+	if addCharset == "" && addCollation == "" {
+		return "", "", nil
+	}
+	if dsType != models.DatastoreMySQL {
+		return "", "", fmt.Errorf("--charset and --collation are only supported for mysql")
+	}
+	charset := addCharset
+	if charset == "" {
+		charset = models.CollationCharset(addCollation)
+	}
+	if err := models.ValidateCharset(charset, addCollation); err != nil {
+		return "", "", err
+	}
+	return charset, addCollation, nil
+}
+
+// validatePersistenceFlag checks --persistence against the datastore type
+func validatePersistenceFlag(dsType models.DatastoreType) error {
+	if addPersistence == "" {
+		return nil
+	}
+	if dsType != models.DatastoreRedis && dsType != models.DatastoreRedisStack {
+		return fmt.Errorf("--persistence is only supported for redis and redis-stack")
+	}
+	return models.ValidatePersistence(addPersistence)
+}
+
+// validateDatabasesFlags checks --databases against the datastore type
+func validateDatabasesFlags(dsType models.DatastoreType) error {
+	if len(addDatabases) > 0 && dsType != models.DatastorePostgres {
+		return fmt.Errorf("--databases is only supported for postgres")
+	}
+	for _, name := range addDatabases {
+		if err := generator.ValidateDatabaseName(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRuntime applies --framework, --port and --toolchain-version to an
+// existing runtime and regenerates
+func updateRuntime(project *models.Project, configPath string, rt *models.Runtime) error {
+	var changes []string
+	if addFramework != "" && addFramework != rt.Framework {
+		changes = append(changes, fmt.Sprintf("framework %s → %s", rt.Framework, addFramework))
+		rt.Framework = addFramework
+	}
+	if addPortSet && addPort != rt.Port {
+		changes = append(changes, fmt.Sprintf("port %d → %d", rt.Port, addPort))
+		rt.Port = addPort
+	}
+	if addToolchainVersion != "" && addToolchainVersion != rt.Version {
+		changes = append(changes, fmt.Sprintf("toolchain %s → %s", versionLabel(rt.Version), addToolchainVersion))
+		rt.Version = addToolchainVersion
+	}
+	healthCheck, err := runtimeHealthCheck(rt.HealthCheck)
+	if err != nil {
+		return err
+	}
+	if healthCheck != rt.HealthCheck {
+		changes = append(changes, "healthcheck")
+		rt.HealthCheck = healthCheck
+	}
+	if ranges, added := addPortRanges(rt.PortRanges); len(added) > 0 {
+		changes = append(changes, "port ranges +"+strings.Join(added, ","))
+		rt.PortRanges = ranges
+	}
+	if err := validatePackageManagerFlag(rt.Type); err != nil {
+		return err
+	}
+	if addPackageManager != "" && addPackageManager != rt.PackageManager {
+		changes = append(changes, fmt.Sprintf("package manager %s → %s", versionLabel(rt.PackageManager), addPackageManager))
+		rt.PackageManager = addPackageManager
+	}
+	if err := validateDepToolFlag(rt.Type); err != nil {
+		return err
+	}
+	if addDepTool != "" && addDepTool != rt.DepTool {
+		changes = append(changes, fmt.Sprintf("dependency tool %s → %s", versionLabel(rt.DepTool), addDepTool))
+		rt.DepTool = addDepTool
+	}
+	if len(addEnvFiles) > 0 && !slices.Equal(addEnvFiles, rt.EnvFiles) {
+		changes = append(changes, "env files "+strings.Join(addEnvFiles, ","))
+		rt.EnvFiles = addEnvFiles
+	}
+	return saveUpdate(project, configPath, rt.Name, changes)
+}
+
+// addPortRanges appends the --publish-range values not yet configured,
+// returning the new list and the added ranges
+func addPortRanges(ranges []string) ([]string, []string) {
+	var added []string
+	for _, spec := range addPublishRanges {
+		if !slices.Contains(ranges, spec) {
+			ranges = append(ranges, spec)
+			added = append(added, spec)
+		}
+	}
+	return ranges, added
+}
+
+// saveUpdate regenerates after an --update, or reports that nothing changed
+func saveUpdate(project *models.Project, configPath, name string, changes []string) error {
+	if len(changes) == 0 {
+		color.Yellow("%s is already up to date", name)
+		return nil
+	}
+	if err := saveAdded(project, configPath); err != nil {
+		return err
+	}
+	color.Green("✅ Updated %s (%s)\n", name, strings.Join(changes, ", "))
+	return nil
+}
+
+// charsetLabel names a character set and collation for change summaries
+func charsetLabel(charset, collation string) string {
+	return strings.TrimSuffix(charset+"/"+collation, "/")
+}
+
+func versionLabel(version string) string {
+	if version == "" {
+		return "default"
+	}
+	return version
+}
+
+func isFramework(info models.RuntimeInfo, framework string) bool {
+	for _, fw := range info.Frameworks {
+		if fw == framework {
+			return true
+		}
+	}
+	return false
+}
+
+func addTracing(project *models.Project, configPath string, backend string) error {
+	if backend != "jaeger" {
+		return fmt.Errorf("unknown tracing backend: %s. Use: jaeger", backend)
+	}
+	if project.Jaeger {
+		return fmt.Errorf("jaeger is already in the configuration")
+	}
+	project.Jaeger = true
+
+	// Save and regenerate
+	if err := saveAdded(project, configPath); err != nil {
+		return err
+	}
+
+	color.Green("✅ Added Jaeger (UI on port 16686, OTLP on 4317/4318)\n")
+	return nil
+}
+
+// saveProject writes the project to its config file, keeping comments
+// from the version it replaces
+func saveProject(project *models.Project, configPath string) error {
+	project.SchemaVersion = models.SchemaVersion
+	previous, _ := os.ReadFile(configPath)
+	data, err := yamledit.Marshal(project, previous)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 	if err := os.WriteFile(configPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
+	return nil
+}
+
+// saveAdded saves and regenerates after a component was added, unless
+// several are being added and regenerate together at the end
+func saveAdded(project *models.Project, configPath string) error {
+	if addDeferSave {
+		return nil
+	}
+	return saveAndRegenerate(project, configPath)
+}
+
+func saveAndRegenerate(project *models.Project, configPath string) error {
+	// Save stackgen.yaml
+	if err := saveProject(project, configPath); err != nil {
+		return err
+	}
 
 	// Regenerate
-	gen := generator.New(project)
+	gen := newGenerator(project)
 	output, err := gen.Generate()
 	if err != nil {
 		return fmt.Errorf("failed to generate configuration: %w", err)
@@ -263,5 +1241,5 @@ func saveAndRegenerate(project *models.Project, configPath string) error {
 	}
 	absOutput, _ := filepath.Abs(outputDir)
 	
-	return output.WriteToDir(absOutput)
+	return writeOutput(output, absOutput)
 }
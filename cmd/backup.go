@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+// backupDir is where backups are written, relative to the output directory
+const backupDir = "backups"
+
+// redisDataFile is the RDB snapshot path inside redis containers
+const redisDataFile = "/data/dump.rdb"
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <datastore>",
+	Short: "Dump a running datastore to ./backups",
+	Long: `Dump a running datastore into the backups directory next to the
+generated docker-compose.yml.
+
+The dump runs inside the container started by docker compose, using the
+credentials from its environment or the generated .env.
+
+Supported datastores:
+  postgres      pg_dump (plain SQL)
+  mysql         mysqldump (plain SQL)
+  redis         SAVE, then copy of dump.rdb
+  redis-stack   SAVE, then copy of dump.rdb
+
+Examples:
+  stackgen backup postgres    # Writes backups/postgres-<timestamp>.sql
+  stackgen backup cache       # Datastores are addressed by name`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBackup,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <datastore> <file>",
+	Short: "Load a backup into a running datastore",
+	Long: `Load a file written by 'stackgen backup' into a running datastore.
+
+SQL dumps are piped into psql or mysql. Redis snapshots replace the data
+directory and restart the container, discarding its current data.
+
+Examples:
+  stackgen restore postgres backups/postgres-20250101-120000.sql
+  stackgen restore redis backups/redis-20250101-120000.rdb`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRestore,
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	project, ds, err := loadDatastore(args[0])
+	if err != nil {
+		return err
+	}
+	container, err := serviceContainer(project, ds.Name)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(project.OutputDir, backupDir)
+	stamp := time.Now().Format("20060102-150405")
+
+	var path string
+	switch ds.Type {
+	case models.DatastorePostgres:
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s.sql", ds.Name, stamp))
+		err = dockerExecToFile(container, path, `pg_dump -U "$POSTGRES_USER" -d "$POSTGRES_DB"`)
+
+	case models.DatastoreMySQL:
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s.sql", ds.Name, stamp))
+		err = dockerExecToFile(container, path, `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysqldump -uroot --databases "$MYSQL_DATABASE"`)
+
+	case models.DatastoreRedis, models.DatastoreRedisStack:
+		path = filepath.Join(dir, fmt.Sprintf("%s-%s.rdb", ds.Name, stamp))
+		err = backupRedis(project, ds, container, path)
+
+	default:
+		return fmt.Errorf("backup is not supported for %s", ds.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	color.Green("✅ Backed up %s to %s", ds.Name, path)
+	return nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	project, ds, err := loadDatastore(args[0])
+	if err != nil {
+		return err
+	}
+	path := args[1]
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("backup file not found: %s", path)
+	}
+	container, err := serviceContainer(project, ds.Name)
+	if err != nil {
+		return err
+	}
+
+	switch ds.Type {
+	case models.DatastorePostgres:
+		err = dockerExecFromFile(container, path, `psql -q -v ON_ERROR_STOP=1 -U "$POSTGRES_USER" -d "$POSTGRES_DB"`)
+
+	case models.DatastoreMySQL:
+		err = dockerExecFromFile(container, path, `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -uroot`)
+
+	case models.DatastoreRedis, models.DatastoreRedisStack:
+		if !assumeYes {
+			if err := requireInteractive("restoring replaces all data, use --yes to confirm"); err != nil {
+				return err
+			}
+			prompt := promptui.Prompt{
+				Label:     fmt.Sprintf("Replace all data in %s and restart it", ds.Name),
+				IsConfirm: true,
+			}
+			if _, err := prompt.Run(); err != nil {
+				return fmt.Errorf("restore cancelled")
+			}
+		}
+		err = restoreRedis(project, ds, container, path)
+
+	default:
+		return fmt.Errorf("restore is not supported for %s", ds.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	color.Green("✅ Restored %s from %s", ds.Name, path)
+	return nil
+}
+
+// loadDatastore loads the project and finds a datastore by name
+func loadDatastore(name string) (*models.Project, *models.Datastore, error) {
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range project.Datastores {
+		if project.Datastores[i].Name == name {
+			return project, &project.Datastores[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("datastore %q not found in config", name)
+}
+
+// backupRedis snapshots redis with SAVE and copies the RDB file out
+func backupRedis(project *models.Project, ds *models.Datastore, container, path string) error {
+	auth, err := redisAuthEnv(project, ds)
+	if err != nil {
+		return err
+	}
+	execArgs := append([]string{"exec"}, auth...)
+	if err := dockerRun(append(execArgs, container, "redis-cli", "SAVE")...); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return dockerRun("cp", container+":"+redisDataFile, path)
+}
+
+// restoreRedis replaces the RDB file and restarts redis without saving,
+// removing the append-only files so the snapshot is what gets loaded
+func restoreRedis(project *models.Project, ds *models.Datastore, container, path string) error {
+	auth, err := redisAuthEnv(project, ds)
+	if err != nil {
+		return err
+	}
+	execArgs := append([]string{"exec"}, auth...)
+	if err := dockerRun(append(execArgs, container, "redis-cli", "CONFIG", "SET", "appendonly", "no")...); err != nil {
+		return err
+	}
+	if err := dockerRun("exec", container, "rm", "-rf", "/data/appendonlydir", "/data/appendonly.aof"); err != nil {
+		return err
+	}
+	if err := dockerRun("cp", path, container+":"+redisDataFile); err != nil {
+		return err
+	}
+	// The connection drops as the server exits, so the error is expected
+	_ = dockerRun(append(execArgs, container, "redis-cli", "SHUTDOWN", "NOSAVE")...)
+	return dockerRun("start", container)
+}
+
+// redisAuthEnv returns docker exec flags passing the redis password from
+// the generated .env, or none for passwordless datastores
+func redisAuthEnv(project *models.Project, ds *models.Datastore) ([]string, error) {
+	if ds.NoPassword {
+		return nil, nil
+	}
+	key := "REDIS_PASSWORD"
+	if ds.Type == models.DatastoreRedisStack {
+		key = "REDIS_STACK_PASSWORD"
+	}
+	prefix := envPrefix
+	if prefix == "" {
+		prefix = project.EnvPrefix
+	}
+
+	envPath := filepath.Join(project.OutputDir, ".env")
+	values, err := readEnvFile(envPath)
+	if err != nil {
+		return nil, err
+	}
+	password, ok := values[prefix+key]
+	if !ok {
+		return nil, fmt.Errorf("%s%s not found in %s", prefix, key, envPath)
+	}
+	return []string{"-e", "REDISCLI_AUTH=" + password}, nil
+}
+
+// readEnvFile parses KEY=value lines, skipping comments and blanks
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(key)] = value
+		}
+	}
+	return values, nil
+}
+
+// dockerExecToFile runs a shell command in the container and writes its
+// stdout to path, removing the file if the command fails
+func dockerExecToFile(container, path, script string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	c := exec.Command("docker", "exec", container, "sh", "-c", script)
+	c.Stdout = f
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("dump in %s failed (is the stack running?): %w", container, err)
+	}
+	return nil
+}
+
+// dockerExecFromFile runs a shell command in the container with path as
+// its stdin
+func dockerExecFromFile(container, path, script string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	c := exec.Command("docker", "exec", "-i", container, "sh", "-c", script)
+	c.Stdin = f
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("restore in %s failed (is the stack running?): %w", container, err)
+	}
+	return nil
+}
+
+// dockerRun runs a docker CLI command, passing its output through
+func dockerRun(args ...string) error {
+	c := exec.Command("docker", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("docker %s failed: %w", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+// composeContainer is a running container of a compose project
+type composeContainer struct {
+	Name    string
+	Image   string
+	Service string
+}
+
+// runningContainers returns the running containers docker compose created
+// for composeProject, found by its com.docker.compose.project label
+func runningContainers(composeProject string) ([]composeContainer, error) {
+	out, err := exec.Command("docker", "ps",
+		"--filter", "label=com.docker.compose.project="+composeProject,
+		"--format", `{{.Names}}\t{{.Image}}\t{{.Label "com.docker.compose.service"}}`).Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker ps failed (is the Docker daemon running?): %w", err)
+	}
+	var running []composeContainer
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 3 {
+			running = append(running, composeContainer{Name: fields[0], Image: fields[1], Service: fields[2]})
+		}
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Name < running[j].Name })
+	return running, nil
+}
+
+// serviceContainer returns the ID of the running container of a compose
+// service in the project's stack. Asking compose works whether or not the
+// container has a fixed name, e.g. under --minimal.
+func serviceContainer(project *models.Project, service string) (string, error) {
+	dir := outputDirOf(project)
+	out, err := exec.Command("docker", "compose", "-f", filepath.Join(dir, composeFileName(dir)), "ps", "-q", service).Output()
+	if err != nil {
+		return "", fmt.Errorf("docker compose ps failed (is the Docker daemon running?): %w", err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		return "", fmt.Errorf("%s is not running; start the stack with 'docker compose up -d'", service)
+	}
+	return ids[0], nil
+}
+
+// containerConflicts compares the running containers of the compose
+// project in dir with the services about to be generated. It reports
+// containers of services the new config no longer has and datastores
+// running a different image than configured, both of which keep old
+// settings alive after 'docker compose up'. Containers are matched by
+// their compose labels, so scaled and unnamed services are covered too.
+func containerConflicts(dir string, output *generator.GeneratedOutput) ([]string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, err
+	}
+	running, err := runningContainers(composeProjectName(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, c := range running {
+		image, ok := output.ServiceImages[c.Service]
+		switch {
+		case !ok:
+			conflicts = append(conflicts, fmt.Sprintf("%s is running but service %s is not part of the generated stack (left over from a previous config?); stop it with 'docker rm -f %s'", c.Name, c.Service, c.Name))
+		case image != "" && image != c.Image:
+			conflicts = append(conflicts, fmt.Sprintf("%s is running %s but the config now uses %s; recreate it with 'docker compose up -d --force-recreate'", c.Name, c.Image, image))
+		}
+	}
+	return conflicts, nil
+}
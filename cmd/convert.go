@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/spf13/cobra"
+)
+
+var convertFormat string
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Print generated environment variables in another format",
+	Long: `Print the environment variables generated from stackgen.yaml in a
+format other tools can read. Secrets (passwords and the URLs containing
+them) are taken from the existing .env, so they match the running stack;
+only secrets missing from it are freshly generated.
+
+Formats:
+  dotenv   KEY=value lines (same as .env)
+  json     JSON object
+  direnv   export lines for a .envrc
+  shell    export lines for eval or source
+
+Examples:
+  stackgen convert --format json             # Print env as JSON
+  stackgen convert --format direnv > .envrc  # Create a direnv file
+  eval "$(stackgen convert --format shell)"  # Load into current shell`,
+	RunE: runConvert,
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+	convertCmd.Flags().StringVar(&convertFormat, "format", "dotenv", "output format (dotenv, json, direnv, shell)")
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return err
+	}
+
+	output, err := newGenerator(project).Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate configuration: %w", err)
+	}
+
+	// Generated passwords are random on every run
+	envPath := filepath.Join(outputDirOf(project), ".env")
+	if existing, err := readEnvFile(envPath); err == nil {
+		for i, env := range output.EnvVars {
+			if value, ok := existing[env.Key]; ok && env.Secret {
+				output.EnvVars[i].Value = value
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	formatted, err := generator.FormatEnv(output.EnvVars, convertFormat)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatted)
+	return nil
+}
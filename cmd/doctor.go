@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/ports"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the configuration for host port conflicts and stale containers",
+	Long: `Check stackgen.yaml for host ports claimed by more than one service and
+for ports already in use on this machine.
+
+It also warns about running <project>-* containers that the generated stack
+no longer has, or that run a different image than configured, since
+'docker compose up' keeps their old settings.
+
+With --fix, conflicting services are moved to the next free ports, the
+config is saved and the stack is regenerated. Stop the stack first, or its
+own ports are reported as in use.
+
+Examples:
+  stackgen doctor          # Report conflicts
+  stackgen doctor --fix    # Reassign ports and regenerate`,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "move conflicting services to free ports and regenerate")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Stale containers only warn; without docker the check is skipped
+	if output, err := newGenerator(project).Generate(); err == nil {
+		absOutput, _ := filepath.Abs(outputDirOf(project))
+		stale, _ := containerConflicts(absOutput, output)
+		for _, c := range stale {
+			color.Yellow("⚠ %s", c)
+		}
+	}
+
+	conflicts := ports.Check(project, ports.InUse)
+	if len(conflicts) == 0 {
+		color.Green("✅ No port conflicts")
+		return nil
+	}
+
+	for _, c := range conflicts {
+		var reasons []string
+		if len(c.Services) > 1 {
+			reasons = append(reasons, "claimed by "+strings.Join(c.Services, ", "))
+		}
+		if c.InUse {
+			reasons = append(reasons, "in use on this host")
+		}
+		color.Yellow("⚠ port %d: %s", c.Port, strings.Join(reasons, "; "))
+	}
+
+	if !doctorFix {
+		return fmt.Errorf("%d port conflict(s); run 'stackgen doctor --fix' to reassign", len(conflicts))
+	}
+	if configPath == stdinConfig {
+		return fmt.Errorf("--fix updates the config file and cannot read it from stdin")
+	}
+
+	moves, err := ports.Fix(project, ports.InUse)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	for _, m := range moves {
+		fmt.Printf("  %s: %d → %d\n", m.Service, m.From, m.To)
+	}
+	if len(moves) == 0 {
+		return fmt.Errorf("conflicts are on fixed ports and cannot be reassigned")
+	}
+
+	if dryRun {
+		color.Yellow("\n--dry-run: %s not updated", configPath)
+		return nil
+	}
+	if err := saveAndRegenerate(project, configPath); err != nil {
+		return err
+	}
+	color.Green("\n✅ Moved %d service(s) and regenerated", len(moves))
+	return nil
+}
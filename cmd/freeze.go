@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze [datastore...]",
+	Short: "Pin datastores to the image digests their running containers use",
+	Long: `Inspect the running datastore containers and record the exact image
+digest each one runs in stackgen.yaml. Unlike 'stackgen pin', which asks
+the registry what a tag points at now, freeze captures what actually ran,
+so a drifted latest tag does not change the stack.
+
+Without arguments every datastore is frozen. Datastores whose container is
+not running are reported and left unchanged.
+
+Examples:
+  stackgen freeze               # Freeze all running datastores
+  stackgen freeze postgres      # Freeze one datastore
+  stackgen freeze --dry-run     # Show the digests without saving`,
+	SilenceUsage: true,
+	RunE:         runFreeze,
+}
+
+func init() {
+	rootCmd.AddCommand(freezeCmd)
+}
+
+func runFreeze(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return fmt.Errorf("freeze updates the config file and cannot read it from stdin")
+	}
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker not found in PATH; freeze inspects running containers")
+	}
+
+	selected := make(map[string]bool, len(args))
+	for _, name := range args {
+		selected[name] = true
+	}
+
+	found := 0
+	var frozen, unresolved []string
+	for i := range project.Datastores {
+		ds := &project.Datastores[i]
+		if len(selected) > 0 && !selected[ds.Name] {
+			continue
+		}
+		found++
+		if !ds.HasService() {
+			continue
+		}
+
+		repo, _ := generator.DatastoreImageRef(*ds)
+		container, err := serviceContainer(project, ds.Name)
+		if err != nil {
+			unresolved = append(unresolved, ds.Name)
+			color.Yellow("  %s: %v", ds.Name, err)
+			continue
+		}
+		digest, err := runningImageDigest(container, repo)
+		if err != nil {
+			unresolved = append(unresolved, ds.Name)
+			color.Yellow("  %s: %v", ds.Name, err)
+			continue
+		}
+		if digest == ds.Digest {
+			fmt.Printf("  %s: already pinned to %s\n", ds.Name, digest)
+			continue
+		}
+		ds.Digest = digest
+		frozen = append(frozen, ds.Name)
+		fmt.Printf("  %s: %s → %s\n", ds.Name, repo, digest)
+	}
+	if found < len(selected) {
+		return fmt.Errorf("datastore not found in config: %v", args)
+	}
+	if len(unresolved) > 0 {
+		color.Yellow("\n⚠ Could not freeze %s; start the stack with 'docker compose up -d' and rerun", strings.Join(unresolved, ", "))
+	}
+
+	if len(frozen) == 0 {
+		color.Yellow("\nNothing to update in %s", configPath)
+		return nil
+	}
+	if dryRun {
+		color.Yellow("\n--dry-run: stackgen.yaml not updated")
+		return nil
+	}
+	if err := saveAndRegenerate(project, configPath); err != nil {
+		return err
+	}
+
+	color.Green("\n✅ Froze %d datastore(s) in %s", len(frozen), configPath)
+	return nil
+}
+
+// runningImageDigest returns the sha256 digest of repo that the running
+// container was started from
+func runningImageDigest(container, repo string) (string, error) {
+	out, err := exec.Command("docker", "inspect", "--format", "{{.State.Running}} {{.Image}}", container).Output()
+	if err != nil {
+		return "", fmt.Errorf("container %s not found", container)
+	}
+	running, imageID, _ := strings.Cut(strings.TrimSpace(string(out)), " ")
+	if running != "true" {
+		return "", fmt.Errorf("container %s is not running", container)
+	}
+
+	out, err = exec.Command("docker", "image", "inspect", "--format", "{{json .RepoDigests}}", imageID).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", imageID, err)
+	}
+	var repoDigests []string
+	if err := json.Unmarshal(out, &repoDigests); err != nil {
+		return "", fmt.Errorf("failed to parse digests of %s: %w", imageID, err)
+	}
+	for _, ref := range repoDigests {
+		name, digest, ok := strings.Cut(ref, "@")
+		if ok && normalizeRepo(name) == normalizeRepo(repo) {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("image of %s has no registry digest for %s (built or loaded locally?)", container, repo)
+}
+
+// normalizeRepo strips the implicit Docker Hub prefixes so that postgres
+// and docker.io/library/postgres compare equal
+func normalizeRepo(repo string) string {
+	repo = strings.TrimPrefix(repo, "docker.io/")
+	return strings.TrimPrefix(repo, "library/")
+}
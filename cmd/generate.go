@@ -1,16 +1,22 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 
+	"github.com/stackgen-cli/stackgen/internal/composespec"
 	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/migrate"
 	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/workspace"
 	"github.com/fatih/color"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var generateCmd = &cobra.Command{
@@ -26,50 +32,163 @@ Examples:
   stackgen generate --config my.yaml          # Use custom config file
   stackgen generate --dry-run                 # Preview without writing files
   stackgen generate --force                   # Overwrite existing files
-  stackgen generate --compose-out custom.yml  # Custom compose output path`,
+  stackgen generate --yes                     # Skip all confirmation prompts
+  stackgen generate --compose-out custom.yml  # Custom compose output path
+  cat stackgen.yaml | stackgen generate --config - --stdout  # Use as a filter
+  stackgen generate --compose-out - | docker compose -f - config  # Compose to stdout
+  stackgen generate --datastore-only          # Just the databases, app runs on the host
+  stackgen generate --check-names --fix      # Sanitize invalid service names
+  stackgen generate --allow-hooks             # Run hooks from stackgen.yaml
+  stackgen generate --workspace               # Every project in stackgen.workspace.yaml
+  stackgen generate --external-network shared  # Join an existing docker network
+  stackgen generate --validate                # Check the output against the compose spec
+  stackgen generate --compose-merge-with compose.yaml  # Layer onto a hand-written file
+  stackgen generate --explain | jq '.[] | select(.service == "postgres")'`,
 	RunE: runGenerate,
 }
 
+var (
+	generateStdout  bool
+	generateExplain bool
+	validateCompose bool
+	datastoreOnly   bool
+	runtimeOnly     bool
+	checkNames      bool
+	checkNamesFix   bool
+	allowHooks      bool
+	workspaceFile   string
+	externalNetwork string
+	pullPolicy      string
+	mergeWith       string
+)
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
+	generateCmd.Flags().BoolVar(&generateStdout, "stdout", false, "write only docker-compose.yml to stdout, without other output")
+	generateCmd.Flags().BoolVar(&datastoreOnly, "datastore-only", false, "generate only the datastore services (and tracing backend), leaving runtimes out")
+	generateCmd.Flags().BoolVar(&runtimeOnly, "runtime-only", false, "generate only the runtime services, leaving datastores out")
+	generateCmd.MarkFlagsMutuallyExclusive("datastore-only", "runtime-only")
+	generateCmd.Flags().BoolVar(&checkNames, "check-names", false, "only check that service and container names are valid for docker")
+	generateCmd.Flags().BoolVar(&checkNamesFix, "fix", false, "with --check-names, sanitize invalid names in the config file")
+	generateCmd.Flags().BoolVar(&allowHooks, "allow-hooks", false, "run the pre_generate and post_generate hooks from the config (they execute arbitrary shell commands)")
+	generateCmd.Flags().StringVar(&workspaceFile, "workspace", "", "generate every member project of a workspace file with ports unique across it (default "+workspace.FileName+")")
+	generateCmd.Flags().Lookup("workspace").NoOptDefVal = workspace.FileName
+	generateCmd.Flags().StringVar(&externalNetwork, "external-network", "", "attach services to this existing docker network (external: true) instead of creating one")
+	generateCmd.Flags().StringVar(&pullPolicy, "pull-policy", "", "pull_policy of datastore services: never (use only local images), missing or always (overrides pull_policy in config)")
+	generateCmd.Flags().StringVar(&mergeWith, "compose-merge-with", "", "deep-merge the generated services, volumes and networks into this existing compose file instead of writing a new one")
+	generateCmd.Flags().BoolVar(&validateCompose, "validate", false, "check the generated compose files against the Compose Specification before writing them")
+	generateCmd.Flags().BoolVar(&generateExplain, "explain", false, "print, as JSON, which config field or default produced each service key, without writing files")
 }
 
-func runGenerate(cmd *cobra.Command, args []string) error {
-	// Find config file
-	configPath := cfgFile
-	if configPath == "" {
-		configPath = "stackgen.yaml"
+// configFilePath returns the --config path or the default stackgen.yaml
+func configFilePath() string {
+	if cfgFile != "" {
+		return cfgFile
 	}
+	return "stackgen.yaml"
+}
 
-	// Check if file exists
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return fmt.Errorf("config file not found: %s\nRun 'stackgen init' to create a new configuration", configPath)
+// stdinConfig is the --config value that reads the project from stdin
+const stdinConfig = "-"
+
+// stdoutCompose is the --compose-out value that writes the compose file to
+// stdout and nothing to disk
+const stdoutCompose = "-"
+
+// composeToStdout reports whether generate writes only the compose YAML,
+// to stdout, via --stdout or --compose-out -
+func composeToStdout() bool {
+	return generateStdout || composeOut == stdoutCompose
+}
+
+// loadProject reads and parses a stackgen.yaml, or stdin for "-"
+func loadProject(configPath string) (*models.Project, error) {
+	var data []byte
+	var err error
+	if configPath == stdinConfig {
+		data, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+		}
+	} else {
+		// Check if file exists
+		if _, err := os.Stat(configPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("config file not found: %s\nRun 'stackgen init' to create a new configuration", configPath)
+		}
+
+		// Read config
+		data, err = os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
 	}
 
-	// Read config
-	data, err := os.ReadFile(configPath)
+	project, _, err := migrate.Load(data)
 	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	return project, nil
+}
 
-	var project models.Project
-	if err := yaml.Unmarshal(data, &project); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+func runGenerate(cmd *cobra.Command, args []string) error {
+	if workspaceFile != "" {
+		return runGenerateWorkspace(workspaceFile)
+	}
+	configPath := configFilePath()
+
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+
+	if checkNames {
+		return runCheckNames(project, configPath)
+	}
+	if errs := models.ValidateNames(project); len(errs) > 0 {
+		return fmt.Errorf("%w\nRun 'stackgen generate --check-names --fix' to sanitize them", errors.Join(errs...))
+	}
+
+	if mergeWith != "" && splitOut {
+		return fmt.Errorf("--compose-merge-with cannot be combined with --split")
 	}
 
-	color.Cyan("🔧 Generating from %s...\n", configPath)
+	if !composeToStdout() && !generateExplain {
+		color.Cyan("🔧 Generating from %s...\n", configPath)
+	}
 
 	// Generate
-	gen := generator.New(&project)
+	p := startProgress("Generating services...")
+	gen := newGenerator(project)
 	output, err := gen.Generate()
+	p.Stop()
 	if err != nil {
 		return fmt.Errorf("failed to generate configuration: %w", err)
 	}
+	if validateCompose {
+		if err := validateOutput(output); err != nil {
+			return err
+		}
+	}
 
-	// Output
-	if dryRun {
-		color.Yellow("\n📋 Dry run - previewing generated files:\n")
-		output.Print()
+	var merge *generator.ComposeMerge
+	if mergeWith != "" && !generateExplain {
+		if merge, err = mergeCompose(output, outputDirOf(project), mergeWith); err != nil {
+			return err
+		}
+	}
+
+	if composeToStdout() {
+		fmt.Print(output.ComposeYAML)
+		return nil
+	}
+
+	if generateExplain {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(output.Provenance); err != nil {
+			return fmt.Errorf("failed to encode explanation: %w", err)
+		}
 		return nil
 	}
 
@@ -83,31 +202,177 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 	absOutput, _ := filepath.Abs(outputDir)
 
-	// Check for existing files and prompt if --force not set
-	if !forceWrite {
-		composeFileName := "docker-compose.yml"
-		if composeOut != "" {
-			composeFileName = filepath.Base(composeOut)
-		}
-		composePath := filepath.Join(absOutput, composeFileName)
-		if _, err := os.Stat(composePath); err == nil {
-			prompt := promptui.Prompt{
-				Label:     fmt.Sprintf("File %s exists. Overwrite", composePath),
-				IsConfirm: true,
-			}
-			_, err := prompt.Run()
-			if err != nil {
-				color.Yellow("Cancelled.")
-				return nil
-			}
-		}
-	}
-	
-	if err := output.WriteToDir(absOutput); err != nil {
+	composeFileName := output.ComposeFileName
+	composePath := filepath.Join(absOutput, composeFileName)
+	if merge == nil {
+		warnComposeFileName(absOutput, composeFileName)
+	}
+
+	// Old containers make regenerated settings look ineffective
+	if stale, err := containerConflicts(absOutput, output); err == nil {
+		for _, c := range stale {
+			color.Yellow("⚠ %s\n", c)
+		}
+	}
+
+	preHooks, postHooks := projectHooks(project)
+	hasHooks := len(preHooks)+len(postHooks) > 0
+
+	if dryRun {
+		if hasHooks {
+			color.Yellow("\n📋 Hooks are not run in a dry run\n")
+		}
+		if err := previewOutput(output, absOutput); err != nil {
+			return err
+		}
+		if merge != nil {
+			printMerge(composeFileName, merge)
+		}
+		return nil
+	}
+	if hasHooks && !allowHooks {
+		color.Yellow("⚠ Skipping %d hook(s) from %s; pass --allow-hooks to run them\n", len(preHooks)+len(postHooks), configPath)
+	}
+
+	// Warn when existing files came from a different stackgen version
+	if existing, err := os.ReadFile(composePath); err == nil {
+		if prev := generator.ParseVersionStamp(string(existing)); prev != "" && prev != version {
+			color.Yellow("⚠️  %s was generated by stackgen %s; regenerating with %s may change its structure.\n", composeFileName, prev, version)
+		}
+	}
+
+	// Check for existing files and prompt unless --force or --yes is set.
+	// A merge keeps what the file has beyond the generated entries.
+	if merge == nil {
+		ok, err := confirmOverwrite(composePath)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			color.Yellow("Cancelled.")
+			return nil
+		}
+	}
+
+	if allowHooks {
+		if err := runHooks("pre_generate", preHooks, absOutput); err != nil {
+			return fmt.Errorf("%w; no files were written", err)
+		}
+	}
+
+	if err := writeOutput(output, absOutput); err != nil {
 		return fmt.Errorf("failed to write files: %w", err)
 	}
 
+	if allowHooks {
+		if err := runHooks("post_generate", postHooks, absOutput); err != nil {
+			return err
+		}
+	}
+
+	if merge != nil {
+		printMerge(composeFileName, merge)
+	}
 	color.Green("\n✅ Configuration regenerated successfully!\n")
-	
+
+	return nil
+}
+
+// mergeCompose layers the generated compose file onto the existing compose
+// file at path, which is then written instead of a new one
+func mergeCompose(output *generator.GeneratedOutput, outputDir, path string) (*generator.ComposeMerge, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the compose file to merge with: %w", err)
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+	absPath, _ := filepath.Abs(path)
+	name, err := filepath.Rel(absOutput, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate %s from the output directory: %w", path, err)
+	}
+	return output.MergeCompose(name, existing)
+}
+
+// printMerge reports what a merge added to the compose file and kept of it
+func printMerge(name string, merge *generator.ComposeMerge) {
+	color.Cyan("\n🔀 Merged into %s:\n", name)
+	for _, entry := range merge.Added {
+		fmt.Printf("  added    %s\n", entry)
+	}
+	for _, entry := range merge.Updated {
+		fmt.Printf("  updated  %s\n", entry)
+	}
+	for _, entry := range merge.Kept {
+		fmt.Printf("  kept     %s\n", entry)
+	}
+}
+
+// warnComposeFileName warns when docker compose will not load the compose
+// file about to be written to dir without -f: its name is not one compose
+// looks for, or another compose file there takes precedence
+func warnComposeFileName(dir, name string) {
+	if !slices.Contains(generator.ComposeFileNames, name) {
+		color.Yellow("⚠ docker compose does not look for %s by itself; pass -f %s or use --compose-filename compose.yaml\n", name, name)
+		return
+	}
+	for _, other := range generator.ComposeFileNames {
+		if other == name {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(dir, other)); err == nil {
+			color.Yellow("⚠ %s also exists and docker compose loads it instead of %s; remove one of them\n", other, name)
+			return
+		}
+	}
+}
+
+// validateOutput checks the generated compose files against the Compose
+// Specification
+func validateOutput(output *generator.GeneratedOutput) error {
+	files := map[string]string{output.ComposeFileName: output.ComposeYAML}
+	for name, content := range output.ComposeFiles {
+		files[name] = content
+	}
+	if err := composespec.Validate(files); err != nil {
+		return fmt.Errorf("generated compose files do not match the Compose Specification:\n%w", err)
+	}
+	return nil
+}
+
+// runCheckNames reports service and container names docker would reject
+// and, with --fix, sanitizes them in the config file
+func runCheckNames(project *models.Project, configPath string) error {
+	errs := models.ValidateNames(project)
+	if len(errs) == 0 {
+		color.Green("✅ All service and container names are valid")
+		return nil
+	}
+	for _, err := range errs {
+		color.Yellow("⚠ %v", err)
+	}
+	if !checkNamesFix {
+		return fmt.Errorf("%d invalid name(s); rerun with --fix to sanitize them", len(errs))
+	}
+	if configPath == stdinConfig {
+		return fmt.Errorf("--fix rewrites the config file and cannot be combined with --config -")
+	}
+
+	renames := models.SanitizeNames(project)
+	if errs := models.ValidateNames(project); len(errs) > 0 {
+		return fmt.Errorf("%w\nSanitizing cannot fix these; rename the services in %s", errors.Join(errs...), configPath)
+	}
+	if err := saveProject(project, configPath); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(renames))
+	for name := range renames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %q → %s\n", name, renames[name])
+	}
+	color.Green("✅ Fixed %d name(s) in %s; run 'stackgen generate' to regenerate", len(renames), configPath)
 	return nil
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/fatih/color"
+)
+
+// projectHooks returns the pre_generate and post_generate commands of the
+// project, either of which may be empty
+func projectHooks(project *models.Project) (pre, post []string) {
+	if project.Hooks == nil {
+		return nil, nil
+	}
+	return project.Hooks.PreGenerate, project.Hooks.PostGenerate
+}
+
+// runHooks runs each command with sh -c in dir, printing its combined
+// output, and stops at the first command that fails
+func runHooks(stage string, commands []string, dir string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, command := range commands {
+		color.Cyan("🪝 %s: %s\n", stage, command)
+		c := exec.Command("sh", "-c", command)
+		c.Dir = dir
+		out, err := c.CombinedOutput()
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			if line != "" {
+				fmt.Printf("   %s\n", line)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", stage, command, err)
+		}
+	}
+	return nil
+}
@@ -6,8 +6,9 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/importer"
 	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/ports"
 	"github.com/stackgen-cli/stackgen/internal/profiles"
 	"github.com/fatih/color"
 	"github.com/manifoldco/promptui"
@@ -18,7 +19,10 @@ var (
 	projectName string
 	outputDir   string
 	profileName string
-	skipPrompts bool
+	timezone    string
+	initSentry  bool
+	initFrom    string
+	initEnv     string
 )
 
 var initCmd = &cobra.Command{
@@ -34,7 +38,15 @@ Examples:
   stackgen init                    # Interactive mode
   stackgen init --name myproject   # Specify project name
   stackgen init --profile web-app  # Use a preset profile
-  stackgen init --dry-run          # Preview without writing files`,
+  stackgen init --profile api --env staging  # Use the profile's staging tags
+  stackgen init --timezone Europe/Berlin  # Set TZ on all services
+  stackgen init --profile api --external-network shared  # Join an existing network
+  stackgen init --from docker-compose.yml # Adopt an existing compose file
+  stackgen init --dry-run          # Preview without writing files
+
+Confirmation flags:
+  --yes, -y    accept all prompts: default project name, overwrite files
+  --force, -f  only overwrite existing files without asking`,
 	RunE: runInit,
 }
 
@@ -45,7 +57,12 @@ func init() {
 	initCmd.Flags().StringVar(&projectName, "stack-name", "", "project name (alias for --name)")
 	initCmd.Flags().StringVarP(&outputDir, "output", "o", ".", "output directory")
 	initCmd.Flags().StringVarP(&profileName, "profile", "p", "", "use a preset profile (web-app, api, ml, fullstack, etc.)")
-	initCmd.Flags().BoolVarP(&skipPrompts, "yes", "y", false, "skip confirmation prompts")
+	initCmd.Flags().StringVar(&initEnv, "env", "", "target environment whose datastore tags the profile applies (e.g. ci, staging)")
+	initCmd.Flags().BoolVar(&initSentry, "sentry", false, "add a SENTRY_DSN placeholder to .env for error tracking")
+	initCmd.Flags().StringVar(&initFrom, "from", "", "build the configuration from an existing compose file")
+	initCmd.Flags().StringVar(&externalNetwork, "external-network", "", "attach services to this existing docker network (external: true) instead of creating one")
+	initCmd.Flags().StringVar(&pullPolicy, "pull-policy", "", "pull_policy of datastore services: never (use only local images), missing or always")
+	initCmd.Flags().StringVar(&timezone, "timezone", "", "time zone for all services, e.g. Europe/Berlin (default: container default)")
 }
 
 func runInit(cmd *cobra.Command, args []string) error {
@@ -54,7 +71,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 		cwd, _ := os.Getwd()
 		projectName = filepath.Base(cwd)
 
-		if !skipPrompts {
+		if !assumeYes && isInteractive() {
 			prompt := promptui.Prompt{
 				Label:   "Project name",
 				Default: projectName,
@@ -72,54 +89,140 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	var project *models.Project
 
-	// Check if using a profile
-	if profileName != "" {
+	if initEnv != "" && profileName == "" {
+		return fmt.Errorf("--env selects a profile's environment and requires --profile")
+	}
+	if err := models.ValidatePullPolicy(pullPolicy); err != nil {
+		return fmt.Errorf("--pull-policy: %w", err)
+	}
+
+	// Check if adopting a compose file or using a profile
+	if initFrom != "" {
+		if profileName != "" {
+			return fmt.Errorf("--from and --profile cannot be combined")
+		}
+		result, err := importer.FromComposeFile(initFrom, projectName, outputDir)
+		if err != nil {
+			return err
+		}
+		project = result.Project
+		color.Green("✓ Imported %d datastore(s) and %d runtime(s) from %s\n", len(project.Datastores), len(project.Runtimes), initFrom)
+		for _, name := range result.Unmapped {
+			color.Yellow("  ⚠ %s is not a service stackgen manages; it will not be in the generated docker-compose.yml", name)
+		}
+		fmt.Println()
+	} else if profileName != "" {
 		profile := profiles.GetProfile(profileName)
 		if profile == nil {
 			return fmt.Errorf("unknown profile: %s. Run 'stackgen list profiles' to see available profiles", profileName)
 		}
-		project = profiles.BuildProjectFromProfile(profile, projectName, outputDir)
+		if initEnv != "" {
+			envProfile, ok := profile.ForEnvironment(initEnv)
+			if !ok {
+				color.Yellow("⚠ profile %s defines no %s environment; using default tags\n", profile.Name, initEnv)
+			}
+			profile = &envProfile
+		}
+		var err error
+		if project, err = profiles.BuildProjectFromProfile(profile, projectName, outputDir); err != nil {
+			return err
+		}
 		color.Green("✓ Using profile: %s\n", profile.Name)
 		fmt.Printf("  %s\n\n", profile.Description)
 	} else {
+		if err := requireInteractive("use --profile <name> to initialize without prompts"); err != nil {
+			return err
+		}
+
 		// Interactive selection
 		var err error
 		project, err = interactiveInit(projectName, outputDir)
 		if err != nil {
 			return err
 		}
+		if !dryRun {
+			ok, err := confirmReview(project)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				color.Yellow("Cancelled.")
+				return nil
+			}
+		}
 	}
 
+	project.Timezone = timezone
+	project.Sentry = initSentry
+	project.ExternalNetwork = externalNetwork
+	project.PullPolicy = pullPolicy
+
 	// Generate configuration
-	gen := generator.New(project)
+	gen := newGenerator(project)
 	output, err := gen.Generate()
 	if err != nil {
 		return fmt.Errorf("failed to generate configuration: %w", err)
 	}
 
-	// Output
+	absOutput, _ := filepath.Abs(outputDir)
 	if dryRun {
-		color.Yellow("\n📋 Dry run - previewing generated files:\n")
-		output.Print()
-		return nil
+		return previewOutput(output, absOutput)
 	}
 
 	// Write files
-	absOutput, _ := filepath.Abs(outputDir)
-	if err := output.WriteToDir(absOutput); err != nil {
+	ok, err := confirmOverwrite(filepath.Join(absOutput, output.ComposeFileName))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		color.Yellow("Cancelled.")
+		return nil
+	}
+	for dir := range output.Dockerfiles {
+		ok, err := confirmOverwrite(filepath.Join(absOutput, dir, "Dockerfile"))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			color.Yellow("Cancelled.")
+			return nil
+		}
+	}
+	if err := writeOutput(output, absOutput); err != nil {
 		return fmt.Errorf("failed to write files: %w", err)
 	}
+	if initFrom != "" {
+		configPath := configFilePath()
+		if configPath == stdinConfig {
+			return fmt.Errorf("--from writes the config file and cannot be combined with --config -")
+		}
+		if err := saveProject(project, configPath); err != nil {
+			return err
+		}
+	}
 
 	// Success message
 	color.Green("\n✅ stackgen configuration generated successfully!\n\n")
 	fmt.Println("Generated files:")
-	fmt.Printf("  • %s\n", color.CyanString("docker-compose.yml"))
+	fmt.Printf("  • %s\n", color.CyanString(output.ComposeFileName))
+	for name := range output.ComposeFiles {
+		fmt.Printf("  • %s\n", color.CyanString(name))
+	}
 	fmt.Printf("  • %s\n", color.CyanString(".env"))
 	fmt.Printf("  • %s\n", color.CyanString(".env.example"))
 	fmt.Printf("  • %s\n", color.CyanString(".gitignore"))
+	if initFrom != "" {
+		fmt.Printf("  • %s\n", color.CyanString(configFilePath()))
+	}
 	for name := range output.Dockerfiles {
 		fmt.Printf("  • %s\n", color.CyanString(name+"/Dockerfile"))
 	}
+	for name := range output.ConfigFiles {
+		fmt.Printf("  • %s\n", color.CyanString(name))
+	}
+	for name := range output.CIFiles {
+		fmt.Printf("  • %s\n", color.CyanString(name))
+	}
 
 	fmt.Println("\nNext steps:")
 	color.Yellow("  1. Review the generated .env file and adjust values as needed")
@@ -140,6 +243,17 @@ func interactiveInit(name, outDir string) (*models.Project, error) {
 		OutputDir: outDir,
 	}
 
+	// Keep framework defaults from an existing config when re-initializing
+	// (stdin is needed for prompts, so a piped config is not consulted)
+	if configPath := configFilePath(); configPath != stdinConfig {
+		if existing, err := loadProject(configPath); err == nil {
+			if err := models.ValidateDefaultFrameworks(existing.DefaultFrameworks); err != nil {
+				return nil, err
+			}
+			project.DefaultFrameworks = existing.DefaultFrameworks
+		}
+	}
+
 	// Select datastores
 	color.Cyan("Select datastores:\n")
 	selectedDatastores, err := selectDatastores()
@@ -147,18 +261,29 @@ func interactiveInit(name, outDir string) (*models.Project, error) {
 		return nil, err
 	}
 
-	// Configure selected datastores
-	portOffset := 0
+	// Configure selected datastores, moving past ports taken by earlier
+	// ones (Redis and Redis Stack both default to 6379)
 	for _, dsType := range selectedDatastores {
 		info := models.GetDatastoreInfo(dsType)
 		ds := models.Datastore{
 			Type:         dsType,
 			Name:         string(dsType),
-			Port:         info.DefaultPort + portOffset,
+			Port:         info.DefaultPort,
 			InternalPort: info.DefaultPort,
 			Tag:          getDefaultTag(dsType),
 		}
+		if ds.Port > 0 {
+			port, err := ports.NextFree(project, &ds)
+			if err != nil {
+				return nil, err
+			}
+			ds.Port = port
+		}
 		project.Datastores = append(project.Datastores, ds)
+		if !ds.HasService() {
+			fmt.Printf("  ✓ %s (./data)\n", info.DisplayName)
+			continue
+		}
 		fmt.Printf("  ✓ %s (port %d)\n", info.DisplayName, ds.Port)
 	}
 
@@ -173,16 +298,21 @@ func interactiveInit(name, outDir string) (*models.Project, error) {
 	// Configure selected runtimes
 	var dependsOn []string
 	for _, ds := range project.Datastores {
-		dependsOn = append(dependsOn, ds.Name)
+		if ds.HasService() {
+			dependsOn = append(dependsOn, ds.Name)
+		}
 	}
 
 	runtimePortOffset := 0
 	for _, rtType := range selectedRuntimes {
 		info := models.GetRuntimeInfo(rtType)
-		
+
 		// Select framework
-		framework := selectFramework(rtType, info.Frameworks)
-		
+		framework, ok := project.DefaultFrameworks[rtType]
+		if !ok {
+			framework = selectFramework(rtType, info.Frameworks)
+		}
+
 		rt := models.Runtime{
 			Type:         rtType,
 			Name:         string(rtType) + "-app",
@@ -201,6 +331,41 @@ func interactiveInit(name, outDir string) (*models.Project, error) {
 	return project, nil
 }
 
+// confirmReview summarizes an interactively selected stack and asks before
+// anything is written. --yes accepts it.
+func confirmReview(project *models.Project) (bool, error) {
+	color.Cyan("\nReview:\n")
+	if len(project.Datastores) == 0 && len(project.Runtimes) == 0 {
+		fmt.Println("  (no services selected)")
+	}
+	for _, ds := range project.Datastores {
+		info := models.GetDatastoreInfo(ds.Type)
+		if !ds.HasService() {
+			fmt.Printf("  • %s %s (./data)\n", info.DisplayName, ds.Tag)
+			continue
+		}
+		fmt.Printf("  • %s %s on port %d\n", info.DisplayName, ds.Tag, ds.Port)
+	}
+	for _, rt := range project.Runtimes {
+		info := models.GetRuntimeInfo(rt.Type)
+		fmt.Printf("  • %s [%s] on port %d\n", info.DisplayName, rt.Framework, rt.Port)
+	}
+	fmt.Printf("  → %s\n\n", project.OutputDir)
+
+	if assumeYes {
+		return true, nil
+	}
+	prompt := promptui.Prompt{
+		Label:     "Write these files",
+		IsConfirm: true,
+		Default:   "y",
+	}
+	if _, err := prompt.Run(); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
 func selectDatastores() ([]models.DatastoreType, error) {
 	items := []struct {
 		Name        string
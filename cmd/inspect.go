@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var inspectFormat string
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Print the project as stackgen resolves it",
+	Long: `Print the project stackgen generates from: stackgen.yaml after schema
+migrations, with flag overrides such as --env-prefix and --external-network
+applied, friendly versions resolved to image tags and defaults filled in.
+
+Use it to see why the generated files look the way they do; --explain on
+generate shows where each compose key came from.
+
+Examples:
+  stackgen inspect                          # Print as YAML
+  stackgen inspect --format json | jq .     # Print as JSON
+  stackgen inspect --env-prefix APP_        # See the effect of a flag`,
+	SilenceUsage: true,
+	RunE:         runInspect,
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	inspectCmd.Flags().StringVar(&inspectFormat, "format", "yaml", "output format (yaml, json)")
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	if inspectFormat != "yaml" && inspectFormat != "json" {
+		return fmt.Errorf("unknown format %q (use yaml or json)", inspectFormat)
+	}
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return err
+	}
+	resolved, err := newGenerator(project).Resolve()
+	if err != nil {
+		return fmt.Errorf("failed to resolve project: %w", err)
+	}
+
+	data, err := yaml.Marshal(resolved)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %w", err)
+	}
+	if inspectFormat == "yaml" {
+		fmt.Print(string(data))
+		return nil
+	}
+
+	// Round-trip through YAML so JSON keys match stackgen.yaml
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to convert project: %w", err)
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal project: %w", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
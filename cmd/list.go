@@ -18,22 +18,37 @@ Examples:
   stackgen list datastores  # Show all available datastores
   stackgen list runtimes    # Show all available runtimes
   stackgen list profiles    # Show all preset profiles
+  stackgen list profiles --with redis --with-runtime go  # Profiles including both
   stackgen list             # Show everything`,
 	RunE: runList,
 }
 
+var (
+	listWith        []string
+	listWithRuntime []string
+)
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringSliceVar(&listWith, "with", nil, "only list profiles that include these datastores (repeatable or comma-separated)")
+	listCmd.Flags().StringSliceVar(&listWithRuntime, "with-runtime", nil, "only list profiles that include these runtimes (repeatable or comma-separated)")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
+	// Component filters only apply to profiles, so they select that category
+	if len(listWith) > 0 || len(listWithRuntime) > 0 {
+		if len(args) > 0 && !isProfileCategory(args[0]) {
+			return fmt.Errorf("--with and --with-runtime only apply to profiles")
+		}
+		return listProfiles()
+	}
+
 	if len(args) == 0 {
 		listDatastores()
 		fmt.Println()
 		listRuntimes()
 		fmt.Println()
-		listProfiles()
-		return nil
+		return listProfiles()
 	}
 
 	switch args[0] {
@@ -42,7 +57,7 @@ func runList(cmd *cobra.Command, args []string) error {
 	case "runtimes", "runtime", "rt":
 		listRuntimes()
 	case "profiles", "profile", "p":
-		listProfiles()
+		return listProfiles()
 	default:
 		return fmt.Errorf("unknown category: %s. Use: datastores, runtimes, or profiles", args[0])
 	}
@@ -97,7 +112,39 @@ func listRuntimes() {
 	}
 }
 
-func listProfiles() {
+func isProfileCategory(arg string) bool {
+	return arg == "profiles" || arg == "profile" || arg == "p"
+}
+
+// profileFilter converts the --with and --with-runtime values to types,
+// rejecting names stackgen does not know
+func profileFilter() ([]models.DatastoreType, []models.RuntimeType, error) {
+	var datastores []models.DatastoreType
+	for _, name := range listWith {
+		dsType := models.DatastoreType(name)
+		if models.GetDatastoreInfo(dsType).Type == "" {
+			return nil, nil, fmt.Errorf("unknown datastore %q. Run 'stackgen list datastores'", name)
+		}
+		datastores = append(datastores, dsType)
+	}
+	var runtimes []models.RuntimeType
+	for _, name := range listWithRuntime {
+		rtType := models.RuntimeType(name)
+		if models.GetRuntimeInfo(rtType).Type == "" {
+			return nil, nil, fmt.Errorf("unknown runtime %q. Run 'stackgen list runtimes'", name)
+		}
+		runtimes = append(runtimes, rtType)
+	}
+	return datastores, runtimes, nil
+}
+
+func listProfiles() error {
+	datastores, runtimes, err := profileFilter()
+	if err != nil {
+		return err
+	}
+	matched := profiles.Filter(datastores, runtimes)
+
 	color.Cyan("🎯 Available Profiles:\n\n")
 	
 	fmt.Printf("  %-18s %s\n",
@@ -105,7 +152,19 @@ func listProfiles() {
 		color.HiWhiteString("DESCRIPTION"))
 	fmt.Println("  " + color.HiBlackString("─────────────────────────────────────────────────────────────────────────"))
 
-	for _, profile := range profiles.AvailableProfiles() {
+	if len(matched) == 0 {
+		fmt.Println("  No profiles include all of the requested components")
+	}
+	printProfiles(matched)
+	
+	fmt.Println()
+	color.HiBlackString("  Use: stackgen init --profile <name>")
+	return nil
+}
+
+// printProfiles prints one row per profile with its components below
+func printProfiles(matched []profiles.Profile) {
+	for _, profile := range matched {
 		fmt.Printf("  %-18s %s\n",
 			color.YellowString(profile.Name),
 			profile.Description)
@@ -113,16 +172,16 @@ func listProfiles() {
 		// Show components
 		var components []string
 		for _, ds := range profile.Datastores {
-			components = append(components, string(ds))
+			components = append(components, string(ds.Type))
 		}
 		for _, rt := range profile.Runtimes {
 			components = append(components, string(rt.Type))
 		}
+		if profile.Jaeger {
+			components = append(components, "jaeger")
+		}
 		fmt.Printf("  %-18s %s\n", "", color.HiBlackString("→ "+joinComponents(components)))
 	}
-	
-	fmt.Println()
-	color.HiBlackString("  Use: stackgen init --profile <name>")
 }
 
 func joinComponents(components []string) string {
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/stackgen-cli/stackgen/internal/migrate"
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/yamledit"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var migrateConfigCmd = &cobra.Command{
+	Use:   "migrate-config",
+	Short: "Rewrite stackgen.yaml in the current config schema",
+	Long: `Upgrade a stackgen.yaml written by an older stackgen to the current schema.
+
+Older configs are upgraded in memory whenever they are loaded, so this is
+only needed to record the upgrade in the file: the upgrade steps (filling
+defaults older schemas left out, renaming changed fields) are applied and
+schema_version is set. Comments are kept.
+
+Examples:
+  stackgen migrate-config            # Upgrade ./stackgen.yaml in place
+  stackgen migrate-config --dry-run  # Print the upgraded config`,
+	SilenceUsage: true,
+	RunE:         runMigrateConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateConfigCmd)
+}
+
+func runMigrateConfig(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return fmt.Errorf("migrate-config rewrites the config file and cannot read it from stdin")
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	project, from, err := migrate.Load(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if from == models.SchemaVersion {
+		color.Green("✅ %s already uses schema %d", configPath, from)
+		return nil
+	}
+
+	if dryRun {
+		upgraded, err := yamledit.Marshal(project, data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		fmt.Print(string(upgraded))
+		return nil
+	}
+	if err := saveProject(project, configPath); err != nil {
+		return err
+	}
+	color.Green("✅ Migrated %s from schema %d to %d", configPath, from, models.SchemaVersion)
+	return nil
+}
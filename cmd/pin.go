@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/registry"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var pinRemove bool
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [datastore...]",
+	Short: "Pin datastore images to their current digests",
+	Long: `Resolve each datastore's image tag to the digest it currently points
+at and record it in stackgen.yaml. Generated services then use
+image: <name>@sha256:... for reproducible pulls.
+
+Without arguments every datastore is pinned. Datastores without a digest
+keep using their tag.
+
+Examples:
+  stackgen pin                  # Pin all datastores
+  stackgen pin postgres         # Pin one datastore
+  stackgen pin --remove         # Go back to tags`,
+	RunE: runPin,
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+	pinCmd.Flags().BoolVar(&pinRemove, "remove", false, "clear pinned digests and use tags again")
+}
+
+func runPin(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return fmt.Errorf("pin updates the config file and cannot read it from stdin")
+	}
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+
+	selected := make(map[string]bool, len(args))
+	for _, name := range args {
+		selected[name] = true
+	}
+
+	client := registry.New()
+	found := 0
+	for i := range project.Datastores {
+		ds := &project.Datastores[i]
+		if len(selected) > 0 && !selected[ds.Name] {
+			continue
+		}
+		found++
+		if !ds.HasService() {
+			continue
+		}
+
+		if pinRemove {
+			ds.Digest = ""
+			fmt.Printf("  %s: unpinned\n", ds.Name)
+			continue
+		}
+
+		repo, tag := generator.DatastoreImageRef(*ds)
+		digest, err := client.ResolveDigest(repo, tag)
+		if err != nil {
+			return fmt.Errorf("failed to pin %s: %w", ds.Name, err)
+		}
+		ds.Digest = digest
+		fmt.Printf("  %s: %s:%s → %s\n", ds.Name, repo, tag, digest)
+	}
+	if found < len(selected) {
+		return fmt.Errorf("datastore not found in config: %v", args)
+	}
+
+	if dryRun {
+		color.Yellow("\n--dry-run: stackgen.yaml not updated")
+		return nil
+	}
+	if err := saveAndRegenerate(project, configPath); err != nil {
+		return err
+	}
+
+	color.Green("\n✅ Updated %s", configPath)
+	return nil
+}
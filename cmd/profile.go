@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/stackgen-cli/stackgen/internal/profiles"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// maxProfilesSize caps a fetched profiles file
+const maxProfilesSize = 1 << 20
+
+var profileListRemote bool
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage shared profiles",
+	Long: `Manage profiles shared by a team in addition to the built-in ones.
+
+Imported profiles are kept in <user config dir>/stackgen/profiles.yaml and
+can be used with 'stackgen init --profile <name>' like the built-in ones.`,
+}
+
+var profileFetchCmd = &cobra.Command{
+	Use:   "fetch <url|git-repo>",
+	Short: "Import profiles from a URL or git repository",
+	Long: `Fetch a profiles file and import its profiles into the user profiles
+file, replacing imported profiles of the same name.
+
+The source is either an http(s) URL of a YAML file or a git repository
+(ending in .git, or git@host:...) whose root contains profiles.yaml:
+
+  profiles:
+    - name: team-api
+      description: Team API stack (Go + Postgres)
+      datastores:
+        - type: postgres
+          tag: 16-alpine
+      runtimes:
+        - type: go
+          framework: gin
+
+Only these profile fields are accepted. Every profile is checked for known
+datastores, runtimes and frameworks before anything is saved, and names of
+built-in profiles cannot be overridden.
+
+Examples:
+  stackgen profile fetch https://example.com/profiles.yaml --list-remote  # Preview
+  stackgen profile fetch git@github.com:acme/stackgen-profiles.git       # Import`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE:         runProfileFetch,
+}
+
+func init() {
+	rootCmd.AddCommand(profileCmd)
+	profileCmd.AddCommand(profileFetchCmd)
+	profileFetchCmd.Flags().BoolVar(&profileListRemote, "list-remote", false, "list the fetched profiles without importing them")
+}
+
+func runProfileFetch(cmd *cobra.Command, args []string) error {
+	source := args[0]
+	data, err := fetchProfiles(source)
+	if err != nil {
+		return err
+	}
+	fetched, err := profiles.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if len(fetched) == 0 {
+		return fmt.Errorf("%s defines no profiles", source)
+	}
+
+	color.Cyan("🎯 Profiles in %s:\n\n", source)
+	printProfiles(fetched)
+	if profileListRemote || dryRun {
+		fmt.Println()
+		color.HiBlack("  Run without --list-remote to import them")
+		return nil
+	}
+
+	path, err := profiles.SaveUser(fetched)
+	if err != nil {
+		return fmt.Errorf("failed to save profiles: %w", err)
+	}
+	color.Green("\n✅ Imported %d profile(s) into %s", len(fetched), path)
+	return nil
+}
+
+// isGitSource reports whether a profile source is a git repository rather
+// than a plain file URL
+func isGitSource(source string) bool {
+	return strings.HasSuffix(source, ".git") || strings.HasPrefix(source, "git@") || strings.HasPrefix(source, "ssh://")
+}
+
+// fetchProfiles downloads a profiles file over http(s), or clones a git
+// repository and reads its profiles.yaml
+func fetchProfiles(source string) ([]byte, error) {
+	if isGitSource(source) {
+		return fetchGitProfiles(source)
+	}
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return nil, fmt.Errorf("unsupported profile source %q: use an http(s) URL or a git repository", source)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", source, resp.Status)
+	}
+	return readLimited(resp.Body, source)
+}
+
+// fetchGitProfiles shallow-clones a repository into a temporary directory
+// and reads profiles.yaml from its root
+func fetchGitProfiles(repo string) ([]byte, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found in PATH; it is needed to fetch %s", repo)
+	}
+	dir, err := os.MkdirTemp("", "stackgen-profiles-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	clone := exec.Command("git", "clone", "--quiet", "--depth", "1", "--", repo, dir)
+	if out, err := clone.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to clone %s: %s", repo, strings.TrimSpace(string(out)))
+	}
+	f, err := os.Open(filepath.Join(dir, profiles.UserFileName))
+	if err != nil {
+		return nil, fmt.Errorf("%s has no %s at its root", repo, profiles.UserFileName)
+	}
+	defer f.Close()
+	return readLimited(f, repo)
+}
+
+// readLimited reads a profiles file, refusing ones over maxProfilesSize
+func readLimited(r io.Reader, source string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxProfilesSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	if len(data) > maxProfilesSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", source, maxProfilesSize)
+	}
+	return data, nil
+}
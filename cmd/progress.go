@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/mattn/go-isatty"
+)
+
+// progress draws a spinner with a status label on stderr while a slow step
+// runs. It does nothing under --quiet or when stderr is not a terminal, so
+// piped output and CI logs stay clean.
+type progress struct {
+	mu    sync.Mutex
+	label string
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// startProgress starts a spinner showing label; Stop must be called
+func startProgress(label string) *progress {
+	p := &progress{label: label}
+	fd := os.Stderr.Fd()
+	if quiet || !(isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)) {
+		return p
+	}
+
+	p.done = make(chan struct{})
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(spinner.Dot.FPS)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			p.mu.Lock()
+			fmt.Fprintf(os.Stderr, "\r\033[K%s%s", spinner.Dot.Frames[frame%len(spinner.Dot.Frames)], p.label)
+			p.mu.Unlock()
+			select {
+			case <-p.done:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return p
+}
+
+// Update replaces the spinner label
+func (p *progress) Update(label string) {
+	p.mu.Lock()
+	p.label = label
+	p.mu.Unlock()
+}
+
+// Stop clears the spinner line
+func (p *progress) Stop() {
+	if p.done == nil {
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+	p.done = nil
+}
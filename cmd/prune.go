@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove generated Dockerfiles of runtimes no longer in the config",
+	Long: `Remove Dockerfiles that stackgen generated for runtimes that are no longer
+in stackgen.yaml. Regenerating only writes files, so a removed runtime's
+Dockerfile stays behind until pruned.
+
+Only generated Dockerfiles (with stackgen's header or version stamp) at
+<runtime>/Dockerfile in the output directory are considered, or under
+--dockerfile-dir when given, so nested projects are left alone. Their
+directory is removed too when nothing else is left in it, so application
+sources in a build context are never deleted.
+
+Examples:
+  stackgen prune            # List stale Dockerfiles and confirm removal
+  stackgen prune --dry-run  # Only list them
+  stackgen prune --yes      # Remove without asking
+  stackgen prune --dockerfile-dir docker  # Dockerfiles generated under docker/`,
+	SilenceUsage: true,
+	RunE:         runPrune,
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return err
+	}
+	output, err := newGenerator(project).Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate configuration: %w", err)
+	}
+
+	outputDir := project.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+
+	stale, err := generator.StaleDockerfiles(absOutput, dockerDir, output)
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", outputDir, err)
+	}
+	if len(stale) == 0 {
+		color.Green("✅ Nothing to prune")
+		return nil
+	}
+
+	color.Yellow("Stale generated Dockerfiles:")
+	for _, path := range stale {
+		fmt.Printf("  %s\n", filepath.Join(outputDir, path))
+	}
+	if dryRun {
+		return nil
+	}
+
+	if !forceWrite && !assumeYes {
+		if err := requireInteractive("use --yes to remove them"); err != nil {
+			return err
+		}
+		prompt := promptui.Prompt{Label: fmt.Sprintf("Remove %d file(s)", len(stale)), IsConfirm: true}
+		if _, err := prompt.Run(); err != nil {
+			color.Yellow("Cancelled.")
+			return nil
+		}
+	}
+
+	for _, path := range stale {
+		full := filepath.Join(absOutput, path)
+		if err := os.Remove(full); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		// Fails, as intended, when the directory holds anything else
+		_ = os.Remove(filepath.Dir(full))
+	}
+	color.Green("✅ Removed %d stale Dockerfile(s)", len(stale))
+	return nil
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove [datastore|runtime|tracing] [name]",
+	Short: "Remove a datastore, runtime or tracing backend from the configuration",
+	Long: `Remove a datastore, runtime or tracing backend from stackgen.yaml and
+regenerate. Runtimes no longer depend on a removed datastore or runtime.
+
+Datastores and runtimes are addressed by name; a datastore can also be
+given by type when only one of that type is configured. Files generated for
+the component, such as a runtime's Dockerfile, are left on disk. A removed
+datastore's volumes are kept too, so reset data with 'stackgen rm-volume'
+before removing the datastore. With --dry-run the regenerated files are
+previewed and stackgen.yaml is left unchanged.
+
+Examples:
+  stackgen remove datastore postgres        # Remove PostgreSQL
+  stackgen remove runtime go-app            # Remove a runtime by name
+  stackgen remove runtime go-app --dry-run  # Preview the removal
+  stackgen remove tracing jaeger            # Remove the Jaeger backend
+  stackgen remove                           # Interactive mode`,
+	SilenceUsage: true,
+	RunE:         runRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(removeCmd)
+}
+
+func runRemove(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return fmt.Errorf("remove cannot update a config read from stdin; pass a file with --config")
+	}
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+
+	if len(args) < 2 {
+		if err := requireInteractive("use 'stackgen remove <datastore|runtime|tracing> <name>'"); err != nil {
+			return err
+		}
+		return interactiveRemove(project, configPath)
+	}
+
+	category := addCategory(strings.ToLower(args[0]))
+	name := args[1]
+
+	switch category {
+	case "datastore":
+		return removeDatastore(project, configPath, name)
+	case "runtime":
+		return removeRuntime(project, configPath, name)
+	case "tracing":
+		return removeTracing(project, configPath, name)
+	default:
+		return fmt.Errorf("unknown category: %s. Use: datastore, runtime or tracing", args[0])
+	}
+}
+
+func interactiveRemove(project *models.Project, configPath string) error {
+	components := configuredComponents(project)
+	if len(components) == 0 {
+		color.Yellow("Nothing to remove; %s has no datastores, runtimes or tracing", configPath)
+		return nil
+	}
+	items := make([]string, len(components))
+	for i, c := range components {
+		items[i] = c.category + " " + c.typeName
+	}
+	prompt := promptui.Select{
+		Label: "Select component to remove",
+		Items: items,
+	}
+	idx, _, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+
+	c := components[idx]
+	switch c.category {
+	case "datastore":
+		return removeDatastore(project, configPath, c.typeName)
+	case "runtime":
+		return removeRuntime(project, configPath, c.typeName)
+	default:
+		return removeTracing(project, configPath, c.typeName)
+	}
+}
+
+// configuredComponents lists the project's components by category and
+// name, in config order
+func configuredComponents(project *models.Project) []addSpec {
+	var components []addSpec
+	for _, ds := range project.Datastores {
+		components = append(components, addSpec{category: "datastore", typeName: ds.Name})
+	}
+	for _, rt := range project.Runtimes {
+		components = append(components, addSpec{category: "runtime", typeName: rt.Name})
+	}
+	if project.Jaeger {
+		components = append(components, addSpec{category: "tracing", typeName: "jaeger"})
+	}
+	return components
+}
+
+// notConfigured reports a missing component together with what the
+// configuration does contain
+func notConfigured(project *models.Project, category, name string) error {
+	var names []string
+	for _, c := range configuredComponents(project) {
+		names = append(names, c.category+" "+c.typeName)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%s %q is not in the configuration, which has no components", category, name)
+	}
+	return fmt.Errorf("%s %q is not in the configuration. Configured: %s", category, name, strings.Join(names, ", "))
+}
+
+func removeDatastore(project *models.Project, configPath, name string) error {
+	idx := slices.IndexFunc(project.Datastores, func(ds models.Datastore) bool { return ds.Name == name })
+	if idx < 0 {
+		// Fall back to the type when it is unambiguous
+		for i, ds := range project.Datastores {
+			if string(ds.Type) != name {
+				continue
+			}
+			if idx >= 0 {
+				return fmt.Errorf("several %s datastores are configured; remove one by name", name)
+			}
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return notConfigured(project, "datastore", name)
+	}
+
+	ds := project.Datastores[idx]
+	project.Datastores = slices.Delete(project.Datastores, idx, idx+1)
+	removeDependency(project, ds.Name)
+
+	if err := applyRemoval(project, configPath); err != nil || dryRun {
+		return err
+	}
+
+	absOutput, _ := filepath.Abs(outputDirOf(project))
+	volumes := dockerVolumeNames(composeProjectName(absOutput), ds)
+	if len(volumes) == 0 {
+		color.Green("✅ Removed %s\n", ds.Name)
+		return nil
+	}
+	color.Green("✅ Removed %s (data kept; delete it with 'docker volume rm %s')\n", ds.Name, strings.Join(volumes, " "))
+	return nil
+}
+
+func removeRuntime(project *models.Project, configPath, name string) error {
+	idx := slices.IndexFunc(project.Runtimes, func(rt models.Runtime) bool { return rt.Name == name })
+	if idx < 0 {
+		return notConfigured(project, "runtime", name)
+	}
+
+	rt := project.Runtimes[idx]
+	project.Runtimes = slices.Delete(project.Runtimes, idx, idx+1)
+	removeDependency(project, rt.Name)
+
+	if err := applyRemoval(project, configPath); err != nil || dryRun {
+		return err
+	}
+
+	dir := rt.BuildContext
+	if dir == "" {
+		dir = rt.Name
+	}
+	color.Green("✅ Removed %s (%s is left on disk)\n", rt.Name, filepath.ToSlash(filepath.Join(dir, "Dockerfile")))
+	return nil
+}
+
+func removeTracing(project *models.Project, configPath, backend string) error {
+	if backend != "jaeger" || !project.Jaeger {
+		return notConfigured(project, "tracing", backend)
+	}
+	project.Jaeger = false
+
+	if err := applyRemoval(project, configPath); err != nil || dryRun {
+		return err
+	}
+
+	color.Green("✅ Removed Jaeger\n")
+	return nil
+}
+
+// applyRemoval saves the project and regenerates, or with --dry-run
+// previews the regenerated files and leaves the config unchanged
+func applyRemoval(project *models.Project, configPath string) error {
+	if !dryRun {
+		return saveAndRegenerate(project, configPath)
+	}
+	output, err := newGenerator(project).Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate configuration: %w", err)
+	}
+	absOutput, _ := filepath.Abs(outputDirOf(project))
+	if err := previewOutput(output, absOutput); err != nil {
+		return err
+	}
+	color.Yellow("\n--dry-run: %s not updated", configPath)
+	return nil
+}
+
+// removeDependency drops name from the depends_on of every runtime
+func removeDependency(project *models.Project, name string) {
+	for i := range project.Runtimes {
+		rt := &project.Runtimes[i]
+		rt.DependsOn = slices.DeleteFunc(rt.DependsOn, func(dep string) bool { return dep == name })
+	}
+}
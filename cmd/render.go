@@ -13,10 +13,12 @@ This is an alias for the 'generate' command.
 Examples:
   stackgen render                    # Render from ./stackgen.yaml
   stackgen render --config my.yaml   # Use custom config file
-  stackgen render --dry-run          # Preview without writing files`,
+  stackgen render --dry-run          # Preview without writing files
+  stackgen render --config - --stdout  # Read stdin, write compose to stdout`,
 	RunE: runGenerate, // Reuse the same function as generate
 }
 
 func init() {
 	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().BoolVar(&generateStdout, "stdout", false, "write only docker-compose.yml to stdout, without other output")
 }
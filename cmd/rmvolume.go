@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var rmVolumeCmd = &cobra.Command{
+	Use:   "rm-volume [datastore...]",
+	Short: "Reset datastores by removing their docker volumes",
+	Long: `Remove the named volumes stackgen declares for datastores, so the next
+'docker compose up' starts them with empty data.
+
+The datastore's containers, including postgres replicas, are stopped and
+removed first with 'docker compose rm', since Docker refuses to remove a
+volume a container still uses. Other services keep running.
+
+Without arguments the managed volumes are listed and a datastore can be
+picked interactively.
+
+Examples:
+  stackgen rm-volume                 # List volumes and pick a datastore
+  stackgen rm-volume postgres        # Reset postgres
+  stackgen rm-volume --all           # Reset every datastore
+  stackgen rm-volume redis --dry-run # Show what would be removed`,
+	SilenceUsage: true,
+	RunE:         runRmVolume,
+}
+
+var rmVolumeAll bool
+
+func init() {
+	rootCmd.AddCommand(rmVolumeCmd)
+	rmVolumeCmd.Flags().BoolVar(&rmVolumeAll, "all", false, "remove the volumes of every datastore")
+}
+
+func runRmVolume(cmd *cobra.Command, args []string) error {
+	if rmVolumeAll && len(args) > 0 {
+		return fmt.Errorf("--all cannot be combined with datastore names")
+	}
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return err
+	}
+
+	var managed []models.Datastore
+	for _, ds := range project.Datastores {
+		if len(generator.DatastoreVolumes(ds)) > 0 {
+			managed = append(managed, ds)
+		}
+	}
+	if len(managed) == 0 {
+		color.Yellow("No datastore in %s keeps data in a volume", configFilePath())
+		return nil
+	}
+
+	outputDir := outputDirOf(project)
+	absOutput, _ := filepath.Abs(outputDir)
+	composeProject := composeProjectName(absOutput)
+
+	var selected []models.Datastore
+	switch {
+	case rmVolumeAll:
+		selected = managed
+	case len(args) > 0:
+		for _, name := range args {
+			ds, ok := findDatastore(managed, name)
+			if !ok {
+				return fmt.Errorf("datastore %q not found in config or has no volume", name)
+			}
+			selected = append(selected, ds)
+		}
+	default:
+		color.Cyan("Volumes managed by stackgen:")
+		for _, ds := range managed {
+			fmt.Printf("  %-12s %s\n", ds.Name, strings.Join(dockerVolumeNames(composeProject, ds), ", "))
+		}
+		if dryRun {
+			return nil
+		}
+		if err := requireInteractive("name the datastores to reset or use --all"); err != nil {
+			return err
+		}
+		items := make([]string, len(managed))
+		for i, ds := range managed {
+			items[i] = ds.Name
+		}
+		prompt := promptui.Select{Label: "Reset datastore", Items: items}
+		idx, _, err := prompt.Run()
+		if err != nil {
+			color.Yellow("Cancelled.")
+			return nil
+		}
+		selected = []models.Datastore{managed[idx]}
+	}
+
+	var services, volumes []string
+	for _, ds := range selected {
+		services = append(services, ds.Name)
+		for i := 1; i <= ds.Replicas; i++ {
+			services = append(services, fmt.Sprintf("%s-replica-%d", ds.Name, i))
+		}
+		volumes = append(volumes, dockerVolumeNames(composeProject, ds)...)
+	}
+
+	color.Yellow("Removes the containers of %s and these volumes:", strings.Join(services, ", "))
+	for _, volume := range volumes {
+		fmt.Printf("  %s\n", volume)
+	}
+	if dryRun {
+		return nil
+	}
+	if !forceWrite && !assumeYes {
+		if err := requireInteractive("use --yes to remove the volumes"); err != nil {
+			return err
+		}
+		prompt := promptui.Prompt{Label: fmt.Sprintf("Remove %d volume(s)", len(volumes)), IsConfirm: true}
+		if _, err := prompt.Run(); err != nil {
+			color.Yellow("Cancelled.")
+			return nil
+		}
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker not found in PATH; rm-volume removes docker volumes")
+	}
+	composePath := filepath.Join(absOutput, composeFileName(absOutput))
+	if _, err := os.Stat(composePath); err != nil {
+		return fmt.Errorf("compose file not found: %s\nRun 'stackgen generate' first", composePath)
+	}
+	if err := dockerRun(append([]string{"compose", "-f", composePath, "rm", "--stop", "--force"}, services...)...); err != nil {
+		return err
+	}
+
+	existing, err := dockerVolumes()
+	if err != nil {
+		return err
+	}
+	removed := 0
+	for _, volume := range volumes {
+		if !existing[volume] {
+			fmt.Printf("  %s does not exist, skipped\n", volume)
+			continue
+		}
+		if err := dockerRun("volume", "rm", volume); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	color.Green("✅ Removed %d volume(s); 'docker compose up -d' recreates them empty", removed)
+	return nil
+}
+
+func findDatastore(datastores []models.Datastore, name string) (models.Datastore, bool) {
+	for _, ds := range datastores {
+		if ds.Name == name {
+			return ds, true
+		}
+	}
+	return models.Datastore{}, false
+}
+
+// dockerVolumeNames returns the names docker gives a datastore's volumes,
+// which compose prefixes with the project name
+func dockerVolumeNames(composeProject string, ds models.Datastore) []string {
+	volumes := generator.DatastoreVolumes(ds)
+	names := make([]string, len(volumes))
+	for i, volume := range volumes {
+		names[i] = composeProject + "_" + volume
+	}
+	return names
+}
+
+var composeProjectInvalid = regexp.MustCompile(`[^-_a-z0-9]`)
+
+// composeProjectName returns the project name docker compose uses for a
+// compose file in dir: COMPOSE_PROJECT_NAME, else the directory name
+// normalized the way compose does it
+func composeProjectName(dir string) string {
+	if name := os.Getenv("COMPOSE_PROJECT_NAME"); name != "" {
+		return name
+	}
+	name := composeProjectInvalid.ReplaceAllString(strings.ToLower(filepath.Base(dir)), "")
+	return strings.TrimLeft(name, "-_")
+}
+
+// dockerVolumes returns the names of all docker volumes
+func dockerVolumes() (map[string]bool, error) {
+	out, err := exec.Command("docker", "volume", "ls", "--format", "{{.Name}}").Output()
+	if err != nil {
+		return nil, fmt.Errorf("docker volume ls failed (is the Docker daemon running?): %w", err)
+	}
+	volumes := make(map[string]bool)
+	for _, name := range strings.Fields(string(out)) {
+		volumes[name] = true
+	}
+	return volumes, nil
+}
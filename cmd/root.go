@@ -3,17 +3,40 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/gitmeta"
+	"github.com/stackgen-cli/stackgen/internal/models"
 	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
 var (
-	version    = "1.0.0"
-	cfgFile    string
-	dryRun     bool
-	forceWrite bool
-	composeOut string
+	version     = "1.0.0"
+	cfgFile     string
+	dryRun      bool
+	forceWrite  bool
+	assumeYes   bool
+	composeOut  string
+	minimal     bool
+	splitOut    bool
+	interactive bool
+	baseCompose string
+	envPrefix   string
+	ciProvider  string
+	watchSync   bool
+	inlineEnv   bool
+	dockerDir   string
+	quiet       bool
+	gitLabels   bool
+	yamlIndent  int
+
+	composeFilename string
 )
 
 var rootCmd = &cobra.Command{
@@ -39,8 +62,219 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./stackgen.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./stackgen.yaml, - reads stdin)")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "output to stdout without writing files")
 	rootCmd.PersistentFlags().BoolVarP(&forceWrite, "force", "f", false, "overwrite existing files without prompting")
-	rootCmd.PersistentFlags().StringVar(&composeOut, "compose-out", "", "output path for docker-compose.yml (default: current directory)")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "skip all confirmation prompts (implies --force) and use defaults")
+	rootCmd.PersistentFlags().StringVar(&composeOut, "compose-out", "", "output path for the compose file (default: current directory; - writes it to stdout and nothing to disk)")
+	rootCmd.PersistentFlags().StringVar(&composeFilename, "compose-filename", "", "name of the generated compose file, e.g. compose.yaml (default: the existing compose file, else docker-compose.yml)")
+	rootCmd.PersistentFlags().BoolVar(&minimal, "minimal", false, "omit container_name, restart and healthcheck from generated services")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "hide progress output")
+	rootCmd.PersistentFlags().BoolVar(&interactive, "interactive", true, "allow interactive prompts (disabled automatically without a TTY)")
+	rootCmd.PersistentFlags().StringVar(&baseCompose, "base-compose", "", "shared compose file (relative to output dir) that datastores extend")
+	rootCmd.PersistentFlags().StringVar(&envPrefix, "env-prefix", "", "prefix for every generated env var key, e.g. MYAPP_ (overrides env_prefix in config)")
+	rootCmd.PersistentFlags().StringVar(&ciProvider, "ci", "", "also generate a CI workflow that runs the stack and its tests (github)")
+	rootCmd.PersistentFlags().BoolVar(&watchSync, "watch-sync", false, "add develop.watch rules to runtimes for 'docker compose watch' instead of bind-mounting sources")
+	rootCmd.PersistentFlags().BoolVar(&inlineEnv, "inline-env", false, "write non-secret env vars into runtime services instead of env_file: [.env]")
+	rootCmd.PersistentFlags().StringVar(&dockerDir, "dockerfile-dir", "", "write generated Dockerfiles to <dir>/<runtime>/Dockerfile instead of each build context")
+	rootCmd.PersistentFlags().BoolVar(&gitLabels, "git-labels", false, "label runtime services and images with the git remote and commit (OCI source/revision)")
+	rootCmd.PersistentFlags().IntVar(&yamlIndent, "indent", generator.DefaultIndent, "spaces per indentation level in generated compose files (2-9)")
+	rootCmd.PersistentFlags().BoolVar(&splitOut, "split", false, "write datastores and runtimes to separate compose files included from the main compose file")
+}
+
+// isInteractive reports whether prompts can be shown: --interactive is set
+// and stdin is a terminal
+func isInteractive() bool {
+	if !interactive {
+		return false
+	}
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+// requireInteractive returns a clear error for prompts that cannot run
+// without a TTY, pointing at the flags that replace them
+func requireInteractive(hint string) error {
+	if isInteractive() {
+		return nil
+	}
+	return fmt.Errorf("this command requires an interactive terminal; %s", hint)
+}
+
+// confirmOverwrite asks before replacing an existing file. --force and
+// --yes both answer yes; without a TTY the caller gets an error instead.
+func confirmOverwrite(path string) (bool, error) {
+	if forceWrite || assumeYes {
+		return true, nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return true, nil
+	}
+	if err := requireInteractive(fmt.Sprintf("%s exists, use --force or --yes to overwrite", path)); err != nil {
+		return false, err
+	}
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("File %s exists. Overwrite", path),
+		IsConfirm: true,
+	}
+	if _, err := prompt.Run(); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// writeOutput writes generated files to dir, reporting each file on the
+// progress spinner
+func writeOutput(output *generator.GeneratedOutput, dir string) error {
+	p := startProgress("Writing files...")
+	defer p.Stop()
+	written := 0
+	output.OnWrite = func(name string) {
+		written++
+		p.Update(fmt.Sprintf("Wrote %s (%d files)", name, written))
+	}
+	actions, err := output.WriteToDir(dir, false)
+	p.Stop()
+	for _, a := range actions {
+		if a.Action == generator.ActionKeep {
+			color.Yellow("⚠ Kept %s, which stackgen did not generate\n", a.Path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if output.BaseCompose != nil {
+		return validateExtends(output, dir)
+	}
+	return nil
+}
+
+// validateExtends runs docker compose config over the written compose
+// file, so a base file whose services don't fit the generated ones is
+// caught at generate time rather than on the next up
+func validateExtends(output *generator.GeneratedOutput, dir string) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		color.Yellow("⚠ docker not found in PATH; skipped checking %s with docker compose config\n", output.BaseComposePath)
+		return nil
+	}
+	c := exec.Command("docker", "compose", "-f", filepath.Join(dir, output.ComposeFileName), "config", "-q")
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("docker compose config rejected the services extending %s: %w", output.BaseComposePath, err)
+	}
+	return nil
+}
+
+// previewOutput prints the generated files for --dry-run, followed by what
+// writing them to dir would create or overwrite
+func previewOutput(output *generator.GeneratedOutput, dir string) error {
+	color.Yellow("\n📋 Dry run - previewing generated files:\n")
+	output.Print()
+	actions, err := output.WriteToDir(dir, true)
+	if err != nil {
+		return err
+	}
+	color.Yellow("\n📋 Files that would be written to %s:\n", dir)
+	for _, a := range actions {
+		fmt.Printf("  %-9s %s\n", a.Action, a.Path)
+	}
+	return nil
+}
+
+// newGenerator creates a generator configured from the global flags
+func newGenerator(project *models.Project) *generator.Generator {
+	var labels map[string]string
+	if gitLabels {
+		dir := project.OutputDir
+		if dir == "" {
+			dir = "."
+		}
+		var err error
+		if labels, err = gitmeta.Labels(dir); err != nil {
+			// stderr keeps generate --stdout output clean
+			fmt.Fprintln(os.Stderr, color.YellowString("⚠ --git-labels: %v; generating without labels", err))
+		}
+	}
+	var ciWorkDir string
+	if ciProvider != "" {
+		ciWorkDir = repoRelative(outputDirOf(project))
+	}
+	var existingBase []byte
+	if baseCompose != "" {
+		// A missing base file is created on write
+		existingBase, _ = os.ReadFile(filepath.Join(outputDirOf(project), baseCompose))
+	}
+	return generator.New(project).WithOptions(generator.Options{
+		Minimal:       minimal,
+		Version:       version,
+		Split:         splitOut,
+		BaseCompose:   baseCompose,
+		EnvPrefix:     envPrefix,
+		CI:            ciProvider,
+		WatchSync:     watchSync,
+		InlineEnv:     inlineEnv,
+		DockerfileDir: dockerDir,
+		Labels:        labels,
+		DatastoreOnly: datastoreOnly,
+		RuntimeOnly:   runtimeOnly,
+
+		ExternalNetwork: externalNetwork,
+		Indent:          yamlIndent,
+		ComposeFileName: composeFileName(outputDirOf(project)),
+		PullPolicy:      pullPolicy,
+
+		ExistingBaseCompose: existingBase,
+		CIWorkDir:           ciWorkDir,
+	})
+}
+
+// repoRelative returns dir relative to the root of its git repository, so
+// CI files land in the repository's .github. Outside a repository dir is
+// treated as the root.
+func repoRelative(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	root, err := gitmeta.Root(abs)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return rel
+}
+
+// outputDirOf returns the directory generated files are written to: the
+// directory of --compose-out, else the project's output_dir
+func outputDirOf(project *models.Project) string {
+	if composeOut != "" && composeOut != stdoutCompose {
+		return filepath.Dir(composeOut)
+	}
+	if project.OutputDir == "" {
+		return "."
+	}
+	return project.OutputDir
+}
+
+// composeFileName returns the main compose file name for an output
+// directory: the name given to --compose-filename or --compose-out, else
+// the compose file already there, so regenerating updates it instead of
+// adding a second one, else docker-compose.yml
+func composeFileName(dir string) string {
+	switch {
+	case composeFilename != "":
+		return composeFilename
+	case composeOut != "" && composeOut != stdoutCompose:
+		return filepath.Base(composeOut)
+	}
+	if name := generator.FindComposeFile(dir); name != "" {
+		return name
+	}
+	return generator.DefaultComposeFileName
 }
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/templates"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -26,15 +32,37 @@ stackgen test provides a TUI for creating:
 
 Examples:
   stackgen test              # Launch TUI
-  stackgen test --runtime go # Generate Go test container`,
+  stackgen test --runtime go # Generate Go test container
+  stackgen test --runtime go --test-type unit --output ./api  # Scriptable unit scaffold
+  stackgen test --runtime go --toolchain-version 1.23  # Match a newer Go
+  stackgen test --all        # One test container per configured runtime
+  stackgen test --all --coverage-out ./coverage  # Collect coverage reports on the host`,
 	RunE: runTest,
 }
 
-var testRuntime string
+var (
+	testRuntime string
+	testVersion string
+	testAll     bool
+
+	// testCoverOut is --coverage-out, the host directory for reports
+	testCoverOut string
+
+	testType   string
+	testOutDir string
+)
+
+// testTypes are the scaffolds 'stackgen test' generates
+var testTypes = []string{"unit", "integration", "e2e"}
 
 func init() {
 	rootCmd.AddCommand(testCmd)
 	testCmd.Flags().StringVarP(&testRuntime, "runtime", "r", "", "runtime for test container (go, node, python, java, rust, csharp)")
+	testCmd.Flags().BoolVar(&testAll, "all", false, "generate test scaffolding for every runtime in stackgen.yaml")
+	testCmd.Flags().StringVar(&testCoverOut, "coverage-out", "", "host directory the test container writes coverage reports to (go, node, python)")
+	testCmd.Flags().StringVar(&testType, "test-type", "integration", "test scaffold without the TUI: unit, integration or e2e")
+	testCmd.Flags().StringVarP(&testOutDir, "output", "o", ".", "directory the test-container directory is written to without the TUI")
+	testCmd.Flags().StringVar(&testVersion, "toolchain-version", "", "toolchain version for the test image, e.g. 1.23 for Go (default: stackgen's pinned version)")
 }
 
 // TUI Model
@@ -51,12 +79,20 @@ type testModel struct {
 }
 
 type testOutput struct {
+	Service       string
 	Dockerfile    string
 	ComposeAdd    string
 	TestFile      string
 	TestFileName  string
+
+	// Files are further files written to test-container, keyed by name
+	Files map[string]string
 }
 
+// testEnvFileName is the env file integration tests run with, written to
+// test-container when the project's env vars are known
+const testEnvFileName = ".env.test"
+
 type item struct {
 	title, desc string
 }
@@ -134,7 +170,7 @@ func (m testModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					items := []list.Item{
 						item{title: "unit", desc: "Unit tests for isolated functions"},
 						item{title: "integration", desc: "Integration tests with services"},
-						item{title: "e2e", desc: "End-to-end tests"},
+						item{title: "e2e", desc: "Playwright browser tests against the running app"},
 					}
 					m.list.SetItems(items)
 					m.list.Title = "Select test type"
@@ -149,7 +185,11 @@ func (m testModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.outputDir == "" {
 					m.outputDir = "."
 				}
-				m.generated = generateTestOutput(m.runtime, m.testType, m.outputDir)
+				settings := loadTestSettings(m.runtime)
+				settings.Context = testContext(m.outputDir)
+				if m.err = models.ValidateToolchainVersion(settings.Version); m.err == nil {
+					m.generated = generateTestOutput(m.runtime, m.testType, m.outputDir, settings)
+				}
 				m.done = true
 				return m, tea.Quit
 			}
@@ -192,16 +232,46 @@ func (m testModel) View() string {
 }
 
 func runTest(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(testTypes, testType) {
+		return fmt.Errorf("unknown test type %q (use unit, integration or e2e)", testType)
+	}
+	if testType == "e2e" && testCoverOut != "" {
+		return fmt.Errorf("--coverage-out measures the test runner, not the app, and is not supported for e2e tests")
+	}
+	if err := models.ValidateToolchainVersion(testVersion); err != nil {
+		return fmt.Errorf("--toolchain-version: %w", err)
+	}
+	if testAll {
+		if testRuntime != "" {
+			return fmt.Errorf("--all and --runtime cannot be combined")
+		}
+		if cmd.Flags().Changed("output") {
+			return fmt.Errorf("--all writes into each runtime's build context and cannot be combined with --output")
+		}
+		return runTestAll()
+	}
+
 	// Non-interactive mode
 	if testRuntime != "" {
-		output := generateTestOutput(testRuntime, "integration", ".")
+		if testCoverOut != "" && !coverageRuntimes[testRuntime] {
+			return fmt.Errorf("--coverage-out is not supported for %s (use go, node or python)", testRuntime)
+		}
+		settings := loadTestSettings(testRuntime)
+		if err := models.ValidateToolchainVersion(settings.Version); err != nil {
+			return err
+		}
+		settings.Context = testContext(testOutDir)
+		output := generateTestOutput(testRuntime, testType, testOutDir, settings)
 		if output == nil {
 			return fmt.Errorf("unsupported runtime: %s", testRuntime)
 		}
-		return writeTestOutput(output, ".")
+		return writeTestOutput(output, testOutDir, composeFileName("."))
 	}
 
 	// TUI mode
+	if err := requireInteractive("use --runtime <name> to generate without the TUI"); err != nil {
+		return err
+	}
 	p := tea.NewProgram(initialTestModel())
 	finalModel, err := p.Run()
 	if err != nil {
@@ -209,45 +279,141 @@ func runTest(cmd *cobra.Command, args []string) error {
 	}
 
 	m, ok := finalModel.(testModel)
+	if ok && m.err != nil {
+		return m.err
+	}
 	if !ok || m.generated == nil {
 		return nil
 	}
+	if testCoverOut != "" && !coverageRuntimes[m.runtime] {
+		color.Yellow("⚠ --coverage-out is not supported for %s; generating without coverage", m.runtime)
+	}
+
+	return writeTestOutput(m.generated, m.outputDir, composeFileName("."))
+}
+
+// runTestAll writes test scaffolding for every configured runtime into a
+// test-container directory inside the runtime's build context
+func runTestAll() error {
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return fmt.Errorf("--all needs the runtimes from a config file, not stdin")
+	}
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+	if len(project.Runtimes) == 0 {
+		return fmt.Errorf("no runtimes in %s", configPath)
+	}
+
+	// Build contexts are relative to the compose file's directory, which
+	// is also the compose project directory the test services run in
+	outDir := outputDirOf(project)
+	composeFile := filepath.Join(outDir, composeFileName(outDir))
+	for _, rt := range project.Runtimes {
+		dir := rt.BuildContext
+		if dir == "" {
+			dir = rt.Name
+		}
+		settings := loadTestSettings(string(rt.Type))
+		settings.EnvFile = ".env"
+		if testVersion == "" {
+			settings.Version = rt.Version
+		}
+		if err := models.ValidateToolchainVersion(settings.Version); err != nil {
+			return fmt.Errorf("runtime %s: %w", rt.Name, err)
+		}
+		settings.PackageManager = rt.PackageManager
+		settings.DepTool = rt.DepTool
+		setTestApp(&settings, rt)
+		settings.Service = rt.Name + "-test"
+		settings.Context = testContext(dir)
+		if testCoverOut != "" {
+			if coverageRuntimes[string(rt.Type)] {
+				// One directory per runtime so reports do not overwrite each other
+				settings.Coverage = coveragePath(filepath.Join(testCoverOut, rt.Name))
+			} else {
+				color.Yellow("⚠ --coverage-out is not supported for %s; generating %s without coverage", rt.Type, rt.Name)
+			}
+		}
+
+		output := generateTestOutput(string(rt.Type), testType, dir, settings)
+		if output == nil {
+			return fmt.Errorf("unsupported runtime: %s", rt.Type)
+		}
+		writeDir := dir
+		if !filepath.IsAbs(dir) {
+			writeDir = filepath.Join(outDir, dir)
+		}
+		if err := writeTestOutput(output, writeDir, composeFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return writeTestOutput(m.generated, m.outputDir)
+// testContext returns the test service's build context and source mount
+// for scaffolding written to dir, relative to the project directory
+func testContext(dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.ToSlash(dir)
+	}
+	if dir = filepath.Clean(dir); dir == "." {
+		return "."
+	}
+	return "./" + filepath.ToSlash(dir)
 }
 
-func generateTestOutput(runtime, testType, outputDir string) *testOutput {
-	output := &testOutput{}
+func generateTestOutput(runtime, testType, outputDir string, settings testSettings) *testOutput {
+	output := &testOutput{Service: settings.Service}
+	if testType == "e2e" {
+		// Browser tests drive the running app, whatever it is written in
+		if models.GetRuntimeInfo(models.RuntimeType(runtime)).Type == "" {
+			return nil
+		}
+		return e2eTestOutput(runtime, settings)
+	}
+	if testType == "integration" && settings.TestEnv != "" {
+		settings.EnvFile = settings.Context + "/test-container/" + testEnvFileName
+		output.Files = map[string]string{testEnvFileName: settings.TestEnv}
+	}
+	toolchain := func(def string) string {
+		if settings.Version != "" {
+			return settings.Version
+		}
+		return def
+	}
 
 	switch runtime {
 	case "go":
-		output.Dockerfile = goTestDockerfile()
-		output.ComposeAdd = goTestCompose(testType)
+		output.Dockerfile = goTestDockerfile(toolchain(templates.DefaultGoVersion))
+		output.ComposeAdd = goTestCompose(testType, settings)
 		output.TestFile = goTestFile(testType)
 		output.TestFileName = "main_test.go"
 	case "node":
-		output.Dockerfile = nodeTestDockerfile()
-		output.ComposeAdd = nodeTestCompose(testType)
+		output.Dockerfile = nodeTestDockerfile(toolchain(templates.DefaultNodeVersion), settings.PackageManager)
+		output.ComposeAdd = nodeTestCompose(testType, settings)
 		output.TestFile = nodeTestFile(testType)
 		output.TestFileName = "test/app.test.js"
 	case "python":
-		output.Dockerfile = pythonTestDockerfile()
-		output.ComposeAdd = pythonTestCompose(testType)
+		output.Dockerfile = pythonTestDockerfile(toolchain(templates.DefaultPythonVersion), settings.DepTool)
+		output.ComposeAdd = pythonTestCompose(testType, settings)
 		output.TestFile = pythonTestFile(testType)
 		output.TestFileName = "tests/test_app.py"
 	case "java":
-		output.Dockerfile = javaTestDockerfile()
-		output.ComposeAdd = javaTestCompose(testType)
+		output.Dockerfile = javaTestDockerfile(toolchain(templates.DefaultJavaVersion))
+		output.ComposeAdd = javaTestCompose(testType, settings)
 		output.TestFile = javaTestFile(testType)
 		output.TestFileName = "src/test/java/AppTest.java"
 	case "rust":
-		output.Dockerfile = rustTestDockerfile()
-		output.ComposeAdd = rustTestCompose(testType)
+		output.Dockerfile = rustTestDockerfile(toolchain(templates.DefaultRustVersion))
+		output.ComposeAdd = rustTestCompose(testType, settings)
 		output.TestFile = rustTestFile(testType)
 		output.TestFileName = "tests/integration_test.rs"
 	case "csharp":
-		output.Dockerfile = csharpTestDockerfile()
-		output.ComposeAdd = csharpTestCompose(testType)
+		output.Dockerfile = csharpTestDockerfile(toolchain(templates.DefaultDotnetVersion))
+		output.ComposeAdd = csharpTestCompose(testType, settings)
 		output.TestFile = csharpTestFile(testType)
 		output.TestFileName = "Tests/AppTests.cs"
 	default:
@@ -257,7 +423,162 @@ func generateTestOutput(runtime, testType, outputDir string) *testOutput {
 	return output
 }
 
-func writeTestOutput(output *testOutput, outputDir string) error {
+// testSettings carries project-specific choices into the test templates
+type testSettings struct {
+	Version   string   // toolchain version, the template default when empty
+	DependsOn []string // services the integration test service waits for
+	EnvFile   string   // generated .env, relative to the project directory
+	Service   string   // name of the test service
+	Context   string   // build context and source mount, relative to the project directory
+	Coverage  string   // host directory mounted at /coverage for reports, none when empty
+
+	// PackageManager is the node runtime's package manager, npm when empty
+	PackageManager string
+	// DepTool is the python runtime's dependency tool, pip when empty
+	DepTool string
+
+	// TestEnv is the content of .env.test, the project's env vars with a
+	// separate test database; integration tests use it instead of .env.
+	// Empty without a stackgen.yaml.
+	TestEnv string
+
+	// App is the runtime service e2e tests drive, on AppPort inside the
+	// compose network; AppHealthCheck is its healthcheck path, set when
+	// the service has a healthcheck e2e can wait for
+	App            string
+	AppPort        int
+	AppHealthCheck string
+}
+
+// coverageRuntimes are the test templates that can write coverage reports
+// to /coverage
+var coverageRuntimes = map[string]bool{"go": true, "node": true, "python": true}
+
+// coveragePath returns --coverage-out as a compose bind mount source,
+// relative to the project directory
+func coveragePath(dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.ToSlash(dir)
+	}
+	return "./" + filepath.ToSlash(filepath.Clean(dir))
+}
+
+// coverageVolume returns the volumes entry mounting the coverage directory
+func coverageVolume(settings testSettings) string {
+	if settings.Coverage == "" {
+		return ""
+	}
+	return "      - " + settings.Coverage + ":/coverage\n"
+}
+
+// coverageCommand returns a command override running the tests with args,
+// which write their coverage report to /coverage
+func coverageCommand(settings testSettings, args ...string) string {
+	if settings.Coverage == "" {
+		return ""
+	}
+	command, _ := json.Marshal(args)
+	return "    command: " + string(command) + "\n"
+}
+
+// defaultTestDependsOn returns the datastores assumed when there is no
+// stackgen.yaml to read
+func defaultTestDependsOn(runtime string) []string {
+	if runtime == "csharp" {
+		return []string{"mssql"}
+	}
+	return []string{"postgres", "redis"}
+}
+
+// loadTestSettings matches the test container to the project: the
+// runtime's toolchain version and the configured datastores, when a
+// stackgen.yaml is present. --toolchain-version takes precedence.
+func loadTestSettings(runtime string) testSettings {
+	settings := testSettings{
+		Version:   testVersion,
+		DependsOn: defaultTestDependsOn(runtime),
+		EnvFile:   ".env",
+		Service:   "test",
+		Context:   ".",
+		App:       "app",
+		AppPort:   models.GetRuntimeInfo(models.RuntimeType(runtime)).DefaultPort,
+	}
+	if testCoverOut != "" && coverageRuntimes[runtime] {
+		settings.Coverage = coveragePath(testCoverOut)
+	}
+
+	configPath := configFilePath()
+	if configPath == stdinConfig {
+		return settings
+	}
+	project, err := loadProject(configPath)
+	if err != nil {
+		return settings
+	}
+
+	if project.OutputDir != "" {
+		settings.EnvFile = filepath.ToSlash(filepath.Join(project.OutputDir, ".env"))
+	}
+	if output, err := newGenerator(project).Generate(); err == nil {
+		settings.TestEnv = generator.TestEnvFile(project, output.EnvVars)
+	}
+	settings.DependsOn = nil
+	for _, ds := range project.Datastores {
+		if ds.HasService() {
+			settings.DependsOn = append(settings.DependsOn, ds.Name)
+		}
+	}
+	if settings.Version == "" {
+		for _, rt := range project.Runtimes {
+			if string(rt.Type) == runtime && rt.Version != "" {
+				settings.Version = rt.Version
+				break
+			}
+		}
+	}
+	for _, rt := range project.Runtimes {
+		if string(rt.Type) == runtime {
+			settings.PackageManager = rt.PackageManager
+			settings.DepTool = rt.DepTool
+			setTestApp(&settings, rt)
+			break
+		}
+	}
+	return settings
+}
+
+// setTestApp points e2e tests at a runtime's service
+func setTestApp(settings *testSettings, rt models.Runtime) {
+	settings.App = rt.Name
+	settings.AppPort = rt.InternalPort
+	settings.AppHealthCheck = ""
+	if hc := rt.HealthCheck; hc != nil {
+		settings.AppHealthCheck = "/"
+		if hc.Cmd == "" {
+			settings.AppHealthCheck = hc.Path
+			if settings.AppHealthCheck == "" {
+				settings.AppHealthCheck = "/health"
+			}
+		}
+	}
+}
+
+// integrationCompose returns the test service's depends_on and env_file
+func integrationCompose(dependsOn []string, envFile string) string {
+	var b strings.Builder
+	if len(dependsOn) > 0 {
+		b.WriteString("    depends_on:\n")
+		for _, name := range dependsOn {
+			b.WriteString("      - " + name + "\n")
+		}
+	}
+	b.WriteString("    env_file:\n      - " + envFile + "\n")
+	return b.String()
+}
+
+// writeTestOutput writes the test scaffolding to outputDir/test-container
+// and prints how to run it alongside composeFile
+func writeTestOutput(output *testOutput, outputDir, composeFile string) error {
 	absDir, _ := filepath.Abs(outputDir)
 
 	// Create test directory
@@ -283,25 +604,46 @@ func writeTestOutput(output *testOutput, outputDir string) error {
 	if err := os.WriteFile(testFilePath, []byte(output.TestFile), 0644); err != nil {
 		return err
 	}
+	names := make([]string, 0, len(output.Files))
+	for name, content := range output.Files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
 
+	rel := filepath.ToSlash(filepath.Join(outputDir, "test-container"))
 	color.Green("\n✅ Test scaffolding generated!\n\n")
 	fmt.Println("Generated files:")
-	fmt.Printf("  • %s\n", color.CyanString("test-container/Dockerfile.test"))
-	fmt.Printf("  • %s\n", color.CyanString("test-container/docker-compose.test.yml"))
-	fmt.Printf("  • %s\n", color.CyanString("test-container/"+filepath.Base(output.TestFileName)))
+	fmt.Printf("  • %s\n", color.CyanString(rel+"/Dockerfile.test"))
+	fmt.Printf("  • %s\n", color.CyanString(rel+"/docker-compose.test.yml"))
+	fmt.Printf("  • %s\n", color.CyanString(rel+"/"+filepath.Base(output.TestFileName)))
+	for _, name := range names {
+		fmt.Printf("  • %s\n", color.CyanString(rel+"/"+name))
+	}
 
 	fmt.Println("\nUsage:")
 	color.Yellow("  # Run tests in container")
-	color.Yellow("  docker compose -f docker-compose.yml -f test-container/docker-compose.test.yml run --rm test")
+	if _, ok := output.Files[testEnvFileName]; ok {
+		// A separate compose project keeps the test datastores' volumes
+		// apart from the development ones
+		projectDir, _ := filepath.Abs(filepath.Dir(composeFile))
+		color.Yellow("  docker compose -p %s-test --env-file %s/%s -f %s -f %s/docker-compose.test.yml run --rm %s",
+			composeProjectName(projectDir), rel, testEnvFileName, filepath.ToSlash(composeFile), rel, output.Service)
+		fmt.Println()
+		return nil
+	}
+	color.Yellow("  docker compose -f %s -f %s/docker-compose.test.yml run --rm %s", filepath.ToSlash(composeFile), rel, output.Service)
 	fmt.Println()
 
 	return nil
 }
 
 // Go test templates
-func goTestDockerfile() string {
+func goTestDockerfile(version string) string {
 	return `# Go Test Container - Generated by stackgen
-FROM golang:1.22-alpine
+FROM golang:` + version + `-alpine
 
 WORKDIR /app
 
@@ -320,27 +662,22 @@ CMD ["go", "test", "-v", "-race", "-coverprofile=coverage.out", "./..."]
 `
 }
 
-func goTestCompose(testType string) string {
+func goTestCompose(testType string, settings testSettings) string {
 	compose := `# Go Test Service - Generated by stackgen
 # Add to your docker-compose.yml or use with -f flag
 
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
-    environment:
+      - ` + settings.Context + `:/app
+` + coverageVolume(settings) + `    environment:
       - CGO_ENABLED=1
-`
+` + coverageCommand(settings, "go", "test", "-v", "-race", "-coverprofile=/coverage/coverage.out", "./...")
 	if testType == "integration" {
-		compose += `    depends_on:
-      - postgres
-      - redis
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	return compose
 }
@@ -408,44 +745,40 @@ func TestAnotherExample(t *testing.T) {
 }
 
 // Node test templates
-func nodeTestDockerfile() string {
+func nodeTestDockerfile(version, packageManager string) string {
+	pm := templates.NodeManager(packageManager)
 	return `# Node.js Test Container - Generated by stackgen
-FROM node:20-alpine
+FROM node:` + version + `-alpine
 
 WORKDIR /app
-
+` + pm.Setup() + `
 # Copy package files
-COPY package*.json ./
-RUN npm ci
+COPY ` + pm.CopyFiles() + ` ./
+RUN ` + pm.Install + `
 
 # Copy source
 COPY . .
 
 # Run tests
-CMD ["npm", "test"]
+CMD ` + templates.ExecForm([]string{pm.Name, "test"}) + `
 `
 }
 
-func nodeTestCompose(testType string) string {
+func nodeTestCompose(testType string, settings testSettings) string {
 	compose := `# Node.js Test Service - Generated by stackgen
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
+      - ` + settings.Context + `:/app
       - /app/node_modules
-    environment:
+` + coverageVolume(settings) + `    environment:
       - NODE_ENV=test
-`
+` + coverageCommand(settings, templates.NodeManager(settings.PackageManager).Script("test", "--coverage", "--coverageDirectory=/coverage")...)
 	if testType == "integration" {
-		compose += `    depends_on:
-      - postgres
-      - redis
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	return compose
 }
@@ -496,16 +829,18 @@ describe('Example Tests', () => {
 }
 
 // Python test templates
-func pythonTestDockerfile() string {
+func pythonTestDockerfile(version, depTool string) string {
+	deps := "COPY requirements*.txt ./\nRUN pip install --no-cache-dir -r requirements.txt || true\n"
+	if tool := templates.DepTool(depTool); tool.Name != "pip" {
+		deps = "COPY " + tool.Files + " ./\nRUN " + tool.InstallDev + "\n"
+	}
 	return `# Python Test Container - Generated by stackgen
-FROM python:3.12-slim
+FROM python:` + version + `-slim
 
 WORKDIR /app
 
 # Install test dependencies
-COPY requirements*.txt ./
-RUN pip install --no-cache-dir -r requirements.txt || true
-RUN pip install pytest pytest-cov pytest-asyncio
+` + deps + `RUN pip install pytest pytest-cov pytest-asyncio
 
 # Copy source
 COPY . .
@@ -515,25 +850,20 @@ CMD ["pytest", "-v", "--cov=.", "--cov-report=term-missing"]
 `
 }
 
-func pythonTestCompose(testType string) string {
+func pythonTestCompose(testType string, settings testSettings) string {
 	compose := `# Python Test Service - Generated by stackgen
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
-    environment:
+      - ` + settings.Context + `:/app
+` + coverageVolume(settings) + `    environment:
       - PYTHONPATH=/app
-`
+` + coverageCommand(settings, "pytest", "-v", "--cov=.", "--cov-report=term-missing", "--cov-report=xml:/coverage/coverage.xml")
 	if testType == "integration" {
-		compose += `    depends_on:
-      - postgres
-      - redis
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	return compose
 }
@@ -593,9 +923,9 @@ async def test_async_example():
 }
 
 // Java test templates
-func javaTestDockerfile() string {
+func javaTestDockerfile(version string) string {
 	return `# Java Test Container - Generated by stackgen
-FROM eclipse-temurin:21-jdk-alpine
+FROM eclipse-temurin:` + version + `-jdk-alpine
 
 WORKDIR /app
 
@@ -617,24 +947,19 @@ CMD ["sh", "-c", "if [ -f mvnw ]; then ./mvnw test; elif [ -f gradlew ]; then ./
 `
 }
 
-func javaTestCompose(testType string) string {
+func javaTestCompose(testType string, settings testSettings) string {
 	compose := `# Java Test Service - Generated by stackgen
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
+      - ` + settings.Context + `:/app
       - maven-cache:/root/.m2
 `
 	if testType == "integration" {
-		compose += `    depends_on:
-      - postgres
-      - redis
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	compose += `
 volumes:
@@ -719,9 +1044,9 @@ class AppTest {
 }
 
 // Rust test templates
-func rustTestDockerfile() string {
+func rustTestDockerfile(version string) string {
 	return `# Rust Test Container - Generated by stackgen
-FROM rust:1.75-alpine
+FROM rust:` + version + `-alpine
 
 WORKDIR /app
 
@@ -744,24 +1069,19 @@ CMD ["cargo", "test", "--", "--nocapture"]
 `
 }
 
-func rustTestCompose(testType string) string {
+func rustTestCompose(testType string, settings testSettings) string {
 	compose := `# Rust Test Service - Generated by stackgen
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
+      - ` + settings.Context + `:/app
       - cargo-cache:/usr/local/cargo/registry
 `
 	if testType == "integration" {
-		compose += `    depends_on:
-      - postgres
-      - redis
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	compose += `
 volumes:
@@ -823,9 +1143,9 @@ mod tests {
 }
 
 // C# test templates
-func csharpTestDockerfile() string {
+func csharpTestDockerfile(version string) string {
 	return `# C# Test Container - Generated by stackgen
-FROM mcr.microsoft.com/dotnet/sdk:8.0-alpine
+FROM mcr.microsoft.com/dotnet/sdk:` + version + `-alpine
 
 WORKDIR /app
 
@@ -841,22 +1161,18 @@ CMD ["dotnet", "test", "--verbosity", "normal"]
 `
 }
 
-func csharpTestCompose(testType string) string {
+func csharpTestCompose(testType string, settings testSettings) string {
 	compose := `# C# Test Service - Generated by stackgen
 services:
-  test:
+  ` + settings.Service + `:
     build:
-      context: .
+      context: ` + settings.Context + `
       dockerfile: test-container/Dockerfile.test
     volumes:
-      - .:/app
+      - ` + settings.Context + `:/app
 `
 	if testType == "integration" {
-		compose += `    depends_on:
-      - mssql
-    env_file:
-      - .env
-`
+		compose += integrationCompose(settings.DependsOn, settings.EnvFile)
 	}
 	return compose
 }
@@ -929,3 +1245,131 @@ public class AppTests
 }
 `
 }
+
+// E2E test templates
+
+// playwrightVersion pins the test runner to the browsers in its image
+const playwrightVersion = "1.48.2"
+
+// e2eTestOutput returns Playwright scaffolding that runs against the app
+// service once it is up
+func e2eTestOutput(runtime string, settings testSettings) *testOutput {
+	return &testOutput{
+		Service:      settings.Service,
+		Dockerfile:   e2eTestDockerfile(),
+		ComposeAdd:   e2eTestCompose(settings),
+		TestFile:     e2eTestFile(runtime, settings),
+		TestFileName: "app.spec.ts",
+		Files: map[string]string{
+			"package.json":         e2ePackageJSON(),
+			"playwright.config.ts": e2ePlaywrightConfig(),
+		},
+	}
+}
+
+func e2eTestDockerfile() string {
+	return `# E2E Test Container - Generated by stackgen
+# The image ships the browsers matching @playwright/test ` + playwrightVersion + `
+FROM mcr.microsoft.com/playwright:v` + playwrightVersion + `-jammy
+
+WORKDIR /e2e
+
+# Install the test runner
+COPY test-container/package.json ./
+RUN npm install
+
+# Copy specs and config
+COPY test-container/ ./
+
+# Run tests
+CMD ["npx", "playwright", "test"]
+`
+}
+
+func e2eTestCompose(settings testSettings) string {
+	condition := "service_healthy"
+	note := ""
+	if settings.AppHealthCheck == "" {
+		condition = "service_started"
+		note = "    # " + settings.App + " has no healthcheck, so tests may start before it is ready;\n" +
+			"    # add one with --healthcheck-path and switch to service_healthy\n"
+	}
+	return `# E2E Test Service - Generated by stackgen
+# Runs Playwright against ` + settings.App + ` inside the compose network
+services:
+  ` + settings.Service + `:
+    build:
+      context: ` + settings.Context + `
+      dockerfile: test-container/Dockerfile.test
+    volumes:
+      - ` + settings.Context + `/test-container:/e2e
+      - /e2e/node_modules
+    environment:
+      - BASE_URL=http://` + settings.App + `:` + strconv.Itoa(settings.AppPort) + `
+      - CI=true
+    # Chromium needs more shared memory than Docker's default
+    ipc: host
+` + note + `    depends_on:
+      ` + settings.App + `:
+        condition: ` + condition + `
+`
+}
+
+func e2ePackageJSON() string {
+	return `{
+  "name": "e2e",
+  "private": true,
+  "scripts": {
+    "test": "playwright test"
+  },
+  "devDependencies": {
+    "@playwright/test": "` + playwrightVersion + `"
+  }
+}
+`
+}
+
+func e2ePlaywrightConfig() string {
+	return `// Playwright config - Generated by stackgen
+import { defineConfig, devices } from '@playwright/test';
+
+export default defineConfig({
+  testDir: '.',
+  testMatch: '*.spec.ts',
+  retries: process.env.CI ? 2 : 0,
+  // The HTML report lands in test-container/playwright-report on the host
+  reporter: [['list'], ['html', { open: 'never' }]],
+  use: {
+    baseURL: process.env.BASE_URL ?? 'http://localhost:3000',
+    trace: 'on-first-retry',
+  },
+  projects: [
+    { name: 'chromium', use: { ...devices['Desktop Chrome'] } },
+  ],
+});
+`
+}
+
+func e2eTestFile(runtime string, settings testSettings) string {
+	path := settings.AppHealthCheck
+	if path == "" {
+		path = "/"
+	}
+	spec := `// E2E tests - Generated by stackgen
+import { test, expect } from '@playwright/test';
+
+test('app responds', async ({ request }) => {
+  const response = await request.get('` + path + `');
+  expect(response.ok()).toBeTruthy();
+});
+`
+	if runtime == "node" {
+		spec += `
+test('home page renders', async ({ page }) => {
+  await page.goto('/');
+  await expect(page.locator('body')).toBeVisible();
+});
+`
+	}
+	return spec
+}
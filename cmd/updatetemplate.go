@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/textdiff"
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var updateTemplateCmd = &cobra.Command{
+	Use:   "update-template",
+	Short: "Review and apply changes this stackgen version makes to generated files",
+	Long: `Compare what this version of stackgen generates with the files on disk,
+which may come from an older version, and apply the changes file by file.
+
+For each changed file a diff is shown, headed with the stackgen version
+stamped in the file on disk, followed by a prompt to apply it. .env is
+skipped because it holds generated passwords that are new on every run.
+
+Examples:
+  stackgen update-template            # Review and apply file by file
+  stackgen update-template --dry-run  # Only show the diffs
+  stackgen update-template --yes      # Apply every change`,
+	SilenceUsage: true,
+	RunE:         runUpdateTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(updateTemplateCmd)
+}
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+func runUpdateTemplate(cmd *cobra.Command, args []string) error {
+	project, err := loadProject(configFilePath())
+	if err != nil {
+		return err
+	}
+	output, err := newGenerator(project).Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate configuration: %w", err)
+	}
+
+	outputDir := project.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+
+	files := output.Files()
+	// New passwords on every run would lock out existing data volumes
+	delete(files, ".env")
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed, applied := 0, 0
+	for _, name := range names {
+		path := filepath.Join(absOutput, name)
+		var current string
+		if data, err := os.ReadFile(path); err == nil {
+			current = string(data)
+		}
+		hunks := textdiff.Hunks(current, files[name], diffContext)
+		if len(hunks) == 0 {
+			continue
+		}
+		changed++
+		printFileDiff(name, current, hunks)
+
+		if dryRun {
+			continue
+		}
+		ok, err := confirmApply(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		applied++
+	}
+
+	switch {
+	case changed == 0:
+		color.Green("✅ Generated files are up to date with stackgen %s", version)
+	case dryRun:
+		color.Yellow("%d file(s) differ from what stackgen %s generates", changed, version)
+	default:
+		color.Green("✅ Applied %d of %d changed file(s)", applied, changed)
+	}
+	return nil
+}
+
+// printFileDiff prints a colored unified diff of one generated file
+func printFileDiff(name, current string, hunks [][]string) {
+	from := "missing"
+	if current != "" {
+		from = "unstamped"
+		if stamp := generator.ParseVersionStamp(current); stamp != "" {
+			from = "stackgen " + stamp
+		}
+	}
+	color.New(color.Bold).Printf("\n--- %s (%s)\n+++ %s (stackgen %s)\n", name, from, name, version)
+	for _, hunk := range hunks {
+		color.Cyan(hunk[0])
+		for _, line := range hunk[1:] {
+			switch line[0] {
+			case textdiff.Delete:
+				color.Red(line)
+			case textdiff.Insert:
+				color.Green(line)
+			default:
+				fmt.Println(line)
+			}
+		}
+	}
+}
+
+// confirmApply asks whether to write one changed file; --yes and --force
+// apply all
+func confirmApply(name string) (bool, error) {
+	if forceWrite || assumeYes {
+		return true, nil
+	}
+	if err := requireInteractive("use --yes to apply all changes or --dry-run to only review them"); err != nil {
+		return false, err
+	}
+	prompt := promptui.Prompt{
+		Label:     fmt.Sprintf("Apply changes to %s", name),
+		IsConfirm: true,
+	}
+	if _, err := prompt.Run(); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
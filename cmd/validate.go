@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/generator"
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/ports"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check stackgen.yaml for errors without generating",
+	Long: `Check stackgen.yaml without generating or writing anything, for failing
+fast in CI.
+
+The checks: datastore and runtime types are known, runtime frameworks are
+ones the runtime supports, service names are valid compose service names
+and unique, and no host port is published by two services. Each problem is
+printed with the config field it comes from, and the command exits non-zero
+when there is any.
+
+Examples:
+  stackgen validate                    # Check ./stackgen.yaml
+  stackgen validate --config dev.yaml  # Check another config`,
+	SilenceUsage: true,
+	RunE:         runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	configPath := configFilePath()
+	project, err := loadProject(configPath)
+	if err != nil {
+		return err
+	}
+
+	problems := validateProject(project)
+	if len(problems) == 0 {
+		color.Green("✅ %s: configuration valid", configPath)
+		return nil
+	}
+	for _, problem := range problems {
+		color.Yellow("⚠ %s", problem)
+	}
+	return fmt.Errorf("%d problem(s) in %s", len(problems), configPath)
+}
+
+// validateProject runs the semantic checks of 'stackgen validate' and
+// returns one problem per failed check, prefixed with its config field
+func validateProject(project *models.Project) []string {
+	var problems []string
+	report := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if err := models.ValidateProjectName(project.Name); err != nil {
+		report("name", "%v", err)
+	}
+
+	// Service names, with the field that first claimed each
+	claimed := make(map[string]string)
+	claim := func(field, kind, name string) {
+		if err := models.ValidateServiceName(kind, name); err != nil {
+			report(field, "%v", err)
+			return
+		}
+		if first, ok := claimed[name]; ok {
+			report(field, "service name %q is already used by %s", name, first)
+			return
+		}
+		claimed[name] = field
+	}
+	if project.Jaeger {
+		claimed[generator.JaegerServiceName] = "jaeger"
+	}
+
+	for i, ds := range project.Datastores {
+		field := fmt.Sprintf("datastores[%d]", i)
+		if models.GetDatastoreInfo(ds.Type).Type == "" {
+			report(field+".type", "unknown datastore type %q (available: %s)", ds.Type, joinTypes(models.AvailableDatastores()))
+		}
+		if ds.HasService() {
+			claim(field+".name", "datastore", ds.Name)
+		}
+	}
+	for i, rt := range project.Runtimes {
+		field := fmt.Sprintf("runtimes[%d]", i)
+		info := models.GetRuntimeInfo(rt.Type)
+		switch {
+		case info.Type == "":
+			report(field+".type", "unknown runtime type %q (available: %s)", rt.Type, joinTypes(models.AvailableRuntimes()))
+		case rt.Framework != "" && !slices.Contains(info.Frameworks, rt.Framework):
+			report(field+".framework", "%s does not support framework %q (available: %s)", info.DisplayName, rt.Framework, strings.Join(info.Frameworks, ", "))
+		}
+		claim(field+".name", "runtime", rt.Name)
+	}
+
+	// The service listed last is the one to move
+	for _, c := range ports.Check(project, nil) {
+		report(portField(project, c.Services[len(c.Services)-1]), "host port %d is published by %s", c.Port, strings.Join(c.Services, ", "))
+	}
+	return problems
+}
+
+// portField returns the config field holding a service's host port, or
+// the service name for services stackgen adds itself
+func portField(project *models.Project, service string) string {
+	for i, ds := range project.Datastores {
+		if ds.Name == service {
+			return fmt.Sprintf("datastores[%d].port", i)
+		}
+	}
+	for i, rt := range project.Runtimes {
+		if rt.Name == service {
+			return fmt.Sprintf("runtimes[%d].port", i)
+		}
+	}
+	return service
+}
+
+// joinTypes lists datastore or runtime types separated by commas
+func joinTypes[T ~string](types []T) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ", ")
+}
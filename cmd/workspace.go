@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/ports"
+	"github.com/stackgen-cli/stackgen/internal/workspace"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage a workspace of several stackgen projects",
+	Long: `A workspace groups stackgen projects kept in subdirectories. The
+stackgen.workspace.yaml file lists the member directories:
+
+  members:
+    - api
+    - billing
+
+'stackgen generate --workspace' then generates every member, allocating
+host ports that are unique across the whole workspace.`,
+}
+
+var workspaceInitDepth int
+
+var workspaceInitCmd = &cobra.Command{
+	Use:   "init [member-dir...]",
+	Short: "Create stackgen.workspace.yaml",
+	Long: `Create stackgen.workspace.yaml in the current directory. Without
+arguments, subdirectories containing a stackgen.yaml become the members.
+
+Examples:
+  stackgen workspace init              # Discover members
+  stackgen workspace init api billing  # List members explicitly`,
+	SilenceUsage: true,
+	RunE:         runWorkspaceInit,
+}
+
+func init() {
+	rootCmd.AddCommand(workspaceCmd)
+	workspaceCmd.AddCommand(workspaceInitCmd)
+	workspaceInitCmd.Flags().IntVar(&workspaceInitDepth, "depth", 2, "how many directory levels to search for member projects")
+}
+
+func runWorkspaceInit(cmd *cobra.Command, args []string) error {
+	members := args
+	if len(members) == 0 {
+		var err error
+		if members, err = workspace.Discover(".", workspaceInitDepth); err != nil {
+			return fmt.Errorf("failed to search for projects: %w", err)
+		}
+		if len(members) == 0 {
+			return fmt.Errorf("no subdirectory contains a %s; run 'stackgen init' in each project first", workspace.ConfigName)
+		}
+	}
+	for _, member := range members {
+		if _, err := os.Stat(filepath.Join(member, workspace.ConfigName)); err != nil {
+			return fmt.Errorf("member %s has no %s", member, workspace.ConfigName)
+		}
+	}
+
+	data, err := (&workspace.Workspace{Members: members}).Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace: %w", err)
+	}
+	if dryRun {
+		fmt.Print(string(data))
+		return nil
+	}
+	ok, err := confirmOverwrite(workspace.FileName)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		color.Yellow("Cancelled.")
+		return nil
+	}
+	if err := os.WriteFile(workspace.FileName, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", workspace.FileName, err)
+	}
+	color.Green("✅ Created %s with %d member(s)", workspace.FileName, len(members))
+	return nil
+}
+
+// runGenerateWorkspace generates every member of a workspace, first moving
+// services whose host ports collide with another member's
+func runGenerateWorkspace(path string) error {
+	switch {
+	case cfgFile != "", composeOut != "":
+		return fmt.Errorf("--workspace reads each member's stackgen.yaml and cannot be combined with --config or --compose-out")
+	case generateStdout, generateExplain, checkNames:
+		return fmt.Errorf("--workspace cannot be combined with --stdout, --explain or --check-names")
+	}
+
+	ws, err := workspace.Load(path)
+	if err != nil {
+		return err
+	}
+
+	dirs := make([]string, len(ws.Members))
+	projects := make([]*models.Project, len(ws.Members))
+	byName := make(map[string]string, len(ws.Members))
+	for i, member := range ws.Members {
+		dirs[i], _ = filepath.Abs(ws.MemberDir(member))
+		project, err := loadProject(filepath.Join(dirs[i], workspace.ConfigName))
+		if err != nil {
+			return fmt.Errorf("%s: %w", member, err)
+		}
+		// Container names are <project>-<service>, so they must differ
+		if other, ok := byName[project.Name]; ok {
+			return fmt.Errorf("members %s and %s are both named %q; rename one so their containers do not clash", other, member, project.Name)
+		}
+		byName[project.Name] = member
+		projects[i] = project
+	}
+
+	moved := make(map[string]bool)
+	moves, err := ports.FixAll(projects, nil)
+	if err != nil {
+		return err
+	}
+	if len(moves) > 0 {
+		color.Cyan("🔌 Reassigning host ports that collide across the workspace:\n")
+		for _, m := range moves {
+			fmt.Printf("  %s/%s: %d → %d\n", m.Project, m.Service, m.From, m.To)
+			moved[m.Project] = true
+		}
+	}
+	for _, c := range ports.CheckAll(projects, nil) {
+		color.Yellow("⚠ Port %d is still claimed by %v (fixed ports cannot be moved)", c.Port, c.Services)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	defer os.Chdir(cwd)
+	for i, member := range ws.Members {
+		color.Cyan("\n🔧 Generating %s...\n", member)
+		// Output dirs and build contexts are relative to the member
+		if err := os.Chdir(dirs[i]); err != nil {
+			return fmt.Errorf("%s: %w", member, err)
+		}
+		if err := generateMember(projects[i], moved[projects[i].Name]); err != nil {
+			return fmt.Errorf("%s: %w", member, err)
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+	color.Green("\n✅ Generated %d workspace member(s)\n", len(ws.Members))
+	return nil
+}
+
+// generateMember writes one workspace member from the current directory,
+// saving its stackgen.yaml first when its ports were reassigned
+func generateMember(project *models.Project, moved bool) error {
+	if errs := models.ValidateNames(project); len(errs) > 0 {
+		return fmt.Errorf("%w\nRun 'stackgen generate --check-names --fix' in the member to sanitize them", errors.Join(errs...))
+	}
+
+	output, err := newGenerator(project).Generate()
+	if err != nil {
+		return fmt.Errorf("failed to generate configuration: %w", err)
+	}
+	outputDir := project.OutputDir
+	if outputDir == "" {
+		outputDir = "."
+	}
+	absOutput, _ := filepath.Abs(outputDir)
+	if dryRun {
+		return previewOutput(output, absOutput)
+	}
+
+	ok, err := confirmOverwrite(filepath.Join(absOutput, output.ComposeFileName))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		color.Yellow("Skipped.")
+		return nil
+	}
+	if moved {
+		if err := saveProject(project, workspace.ConfigName); err != nil {
+			return err
+		}
+	}
+
+	preHooks, postHooks := projectHooks(project)
+	if allowHooks {
+		if err := runHooks("pre_generate", preHooks, absOutput); err != nil {
+			return fmt.Errorf("%w; no files were written", err)
+		}
+	} else if len(preHooks)+len(postHooks) > 0 {
+		color.Yellow("⚠ Skipping %d hook(s); pass --allow-hooks to run them\n", len(preHooks)+len(postHooks))
+	}
+	if err := writeOutput(output, absOutput); err != nil {
+		return fmt.Errorf("failed to write files: %w", err)
+	}
+	if allowHooks {
+		return runHooks("post_generate", postHooks, absOutput)
+	}
+	return nil
+}
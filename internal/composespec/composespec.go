@@ -0,0 +1,380 @@
+// Package composespec checks compose files against the Compose
+// Specification (https://github.com/compose-spec/compose-spec). The schema
+// is a bundled subset of compose-spec.json: every top-level and service key
+// is known, and the keys stackgen writes have their types, enums and
+// formats checked, so generator regressions fail before docker compose
+// sees the file.
+package composespec
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// node is one schema element. An object with props and no values is
+// closed: other keys than the listed ones and x- extensions are errors.
+type node struct {
+	types    []string // allowed kinds; empty allows any
+	props    map[string]*node
+	values   *node // schema of every entry of a map
+	required []string
+	items    *node
+	enum     []string
+	pattern  *regexp.Regexp
+	format   string   // name of pattern in error messages
+	first    []string // allowed values of an array's first item
+}
+
+func kinds(types ...string) *node { return &node{types: types} }
+
+var (
+	anyValue = &node{}
+	str      = kinds("string")
+	boolean  = kinds("boolean")
+	integer  = kinds("integer")
+	strList  = &node{types: []string{"array"}, items: str}
+
+	// Interpolated values are only known once compose reads .env
+	interpolation = regexp.MustCompile(`\$\{?[A-Za-z_]`)
+
+	duration = &node{types: []string{"string"}, pattern: regexp.MustCompile(`^([0-9]+(\.[0-9]+)?(ns|us|ms|s|m|h))+$`), format: "duration"}
+	byteSize = &node{types: []string{"string", "integer"}, pattern: regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([kKmMgG]?[bB]?)$`), format: "byte size"}
+
+	// stringOrList is a command, tmpfs or env_file entry
+	stringOrList = &node{types: []string{"string", "array"}, items: str}
+
+	// listOrDict is environment, labels and similar: a list of KEY=VALUE
+	// strings or a map of scalars
+	listOrDict = &node{
+		types:  []string{"object", "array"},
+		values: kinds("string", "number", "integer", "boolean", "null"),
+		items:  str,
+	}
+)
+
+// object returns a closed object schema
+func object(props map[string]*node, required ...string) *node {
+	return &node{types: []string{"object"}, props: props, required: required}
+}
+
+// open returns the keys of a closed schema that are not checked further
+func open(keys ...string) map[string]*node {
+	props := make(map[string]*node, len(keys))
+	for _, key := range keys {
+		props[key] = anyValue
+	}
+	return props
+}
+
+// with adds checked keys to open ones
+func with(props map[string]*node, checked map[string]*node) map[string]*node {
+	for key, n := range checked {
+		props[key] = n
+	}
+	return props
+}
+
+var portPattern = regexp.MustCompile(`^((\[[0-9a-fA-F:.]+\]|[0-9a-zA-Z.-]*):)?([0-9]+(-[0-9]+)?:)?[0-9]+(-[0-9]+)?(/(tcp|udp|sctp))?$`)
+
+var healthcheck = object(with(open("start_interval"), map[string]*node{
+	"test":         {types: []string{"string", "array"}, items: str, first: []string{"NONE", "CMD", "CMD-SHELL"}},
+	"interval":     duration,
+	"timeout":      duration,
+	"start_period": duration,
+	"retries":      integer,
+	"disable":      boolean,
+}))
+
+var service = object(with(open(
+	"annotations", "attach", "blkio_config", "cgroup", "cgroup_parent", "configs",
+	"cpu_count", "cpu_percent", "cpu_shares", "cpu_quota", "cpu_period",
+	"cpu_rt_period", "cpu_rt_runtime", "cpus", "cpuset", "credential_spec",
+	"device_cgroup_rules", "devices", "dns", "dns_opt", "dns_search",
+	"domainname", "entrypoint", "external_links", "extra_hosts", "gpus",
+	"group_add", "hostname", "ipc", "isolation", "label_file", "links",
+	"logging", "mac_address", "mem_limit", "mem_reservation", "memswap_limit",
+	"models", "network_mode", "oom_score_adj", "pid", "pids_limit", "platform",
+	"post_start", "pre_stop", "privileged", "profiles", "provider",
+	"runtime", "scale", "secrets", "stdin_open", "stop_signal",
+	"storage_opt", "sysctls", "tty", "userns_mode", "uts", "volumes_from",
+	"working_dir",
+), map[string]*node{
+	"image": str,
+	"build": {
+		types: []string{"string", "object"},
+		props: with(open(
+			"dockerfile_inline", "args", "ssh", "cache_from", "cache_to", "no_cache",
+			"additional_contexts", "network", "pull", "target", "shm_size",
+			"extra_hosts", "isolation", "privileged", "secrets", "tags", "ulimits",
+			"platforms", "entitlements", "provenance", "sbom",
+		), map[string]*node{"context": str, "dockerfile": str, "labels": listOrDict}),
+	},
+	"extends":        object(map[string]*node{"service": str, "file": str}, "service"),
+	"container_name": str,
+	"ports": {types: []string{"array"}, items: &node{
+		types: []string{"string", "integer", "object"}, pattern: portPattern, format: "port mapping",
+	}},
+	"expose":      {types: []string{"array"}, items: kinds("string", "integer")},
+	"volumes":     {types: []string{"array"}, items: kinds("string", "object")},
+	"environment": listOrDict,
+	"env_file":    {types: []string{"string", "array"}, items: kinds("string", "object")},
+	"depends_on": {
+		types:  []string{"array", "object"},
+		items:  str,
+		values: object(map[string]*node{"condition": {types: []string{"string"}, enum: []string{"service_started", "service_healthy", "service_completed_successfully"}}, "restart": boolean, "required": boolean}, "condition"),
+	},
+	"networks":          {types: []string{"array", "object"}, items: str, values: kinds("object", "null")},
+	"healthcheck":       healthcheck,
+	"restart":           {types: []string{"string"}, pattern: regexp.MustCompile(`^(no|always|unless-stopped|on-failure(:[0-9]+)?)$`), format: "restart policy"},
+	"pull_policy":       {types: []string{"string"}, pattern: regexp.MustCompile(`^(always|never|missing|build|if_not_present|refresh|daily|weekly|every_[0-9]+[smhdw])$`), format: "pull policy"},
+	"command":           stringOrList,
+	"user":              str,
+	"stop_grace_period": duration,
+	"init":              boolean,
+	"read_only":         boolean,
+	"tmpfs":             stringOrList,
+	"ulimits":           {types: []string{"object"}, values: kinds("integer", "object")},
+	"cap_add":           strList,
+	"cap_drop":          strList,
+	"security_opt":      strList,
+	"shm_size":          byteSize,
+	"mem_swappiness":    integer,
+	"oom_kill_disable":  boolean,
+	"labels":            listOrDict,
+	"deploy":            {types: []string{"object"}, props: with(open("mode", "endpoint_mode", "labels", "rollback_config", "update_config", "resources", "restart_policy", "placement"), map[string]*node{"replicas": integer})},
+	"develop": object(map[string]*node{"watch": {types: []string{"array"}, items: object(with(open("include", "exec", "initial_sync"), map[string]*node{
+		"path":   str,
+		"action": {types: []string{"string"}, enum: []string{"rebuild", "sync", "restart", "sync+restart", "sync+exec"}},
+		"target": str,
+		"ignore": strList,
+	}), "path", "action")}}),
+}))
+
+var network = &node{types: []string{"object", "null"}, props: open(
+	"driver", "driver_opts", "ipam", "external", "internal", "enable_ipv4",
+	"enable_ipv6", "attachable", "labels", "name",
+)}
+
+var volume = &node{types: []string{"object", "null"}, props: open(
+	"driver", "driver_opts", "external", "labels", "name",
+)}
+
+var topLevel = object(with(open("name", "secrets", "configs", "models"), map[string]*node{
+	"version":  str,
+	"include":  {types: []string{"array"}, items: kinds("string", "object")},
+	"services": {types: []string{"object"}, values: service},
+	"networks": {types: []string{"object"}, values: network},
+	"volumes":  {types: []string{"object"}, values: volume},
+}))
+
+// Validate checks the compose files of one project, keyed by file name,
+// and returns every problem found, joined, or nil when they are valid.
+// Services, networks and volumes may be referenced across the files, as
+// they are once compose merges an include.
+func Validate(files map[string]string) error {
+	v := &validator{}
+	defined := map[string]map[string]interface{}{"services": {}, "networks": {}, "volumes": {}}
+	docs := make(map[string]map[string]interface{}, len(files))
+	for name, data := range files {
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+			v.errs = append(v.errs, fmt.Sprintf("%s: not valid YAML: %v", name, err))
+			continue
+		}
+		v.file = name
+		v.check("", doc, topLevel)
+		root, ok := doc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		docs[name] = root
+		for section, entries := range defined {
+			m, _ := root[section].(map[string]interface{})
+			for key, value := range m {
+				entries[key] = value
+			}
+		}
+	}
+	for name, root := range docs {
+		v.file = name
+		v.references(root, defined)
+	}
+	sort.Strings(v.errs)
+	errs := make([]error, len(v.errs))
+	for i, msg := range v.errs {
+		errs[i] = errors.New(msg)
+	}
+	return errors.Join(errs...)
+}
+
+type validator struct {
+	file string
+	errs []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "(root)"
+	}
+	v.errs = append(v.errs, v.file+": "+path+": "+fmt.Sprintf(format, args...))
+}
+
+// kindOf names a decoded YAML value the way the schema does
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (v *validator) check(path string, value interface{}, n *node) {
+	kind := kindOf(value)
+	if len(n.types) > 0 && !typeAllowed(kind, n.types) {
+		v.fail(path, "must be %s, got %s", strings.Join(n.types, " or "), kind)
+		return
+	}
+
+	switch val := value.(type) {
+	case string:
+		if interpolation.MatchString(val) {
+			return
+		}
+		if len(n.enum) > 0 && !contains(n.enum, val) {
+			v.fail(path, "%q is not one of %s", val, strings.Join(n.enum, ", "))
+		}
+		if n.pattern != nil && !n.pattern.MatchString(val) {
+			v.fail(path, "%q is not a valid %s", val, n.format)
+		}
+	case []interface{}:
+		if len(n.first) > 0 {
+			if len(val) == 0 {
+				v.fail(path, "must not be empty")
+			} else if s, ok := val[0].(string); !ok || !contains(n.first, s) {
+				v.fail(path+"[0]", "must be one of %s", strings.Join(n.first, ", "))
+			}
+		}
+		if n.items != nil {
+			for i, item := range val {
+				v.check(fmt.Sprintf("%s[%d]", path, i), item, n.items)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range n.required {
+			if _, ok := val[key]; !ok {
+				v.fail(path, "missing required key %q", key)
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			switch {
+			case n.values != nil:
+				v.check(join(path, key), val[key], n.values)
+			case n.props == nil || strings.HasPrefix(key, "x-"):
+			case n.props[key] == nil:
+				v.fail(path, "unknown key %q", key)
+			default:
+				v.check(join(path, key), val[key], n.props[key])
+			}
+		}
+	}
+}
+
+// typeAllowed reports whether a kind satisfies a schema's types; integers
+// are numbers too
+func typeAllowed(kind string, types []string) bool {
+	return contains(types, kind) || (kind == "integer" && contains(types, "number"))
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// references checks that a file's services only name services, networks
+// and volumes some file defines, as docker compose does on load
+func (v *validator) references(root map[string]interface{}, defined map[string]map[string]interface{}) {
+	services, networks, volumes := defined["services"], defined["networks"], defined["volumes"]
+	own, _ := root["services"].(map[string]interface{})
+	for name, raw := range own {
+		svc, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := "services." + name
+		for _, dep := range names(svc["depends_on"]) {
+			if _, ok := services[dep]; !ok {
+				v.fail(path+".depends_on", "undefined service %q", dep)
+			}
+		}
+		for _, net := range names(svc["networks"]) {
+			if _, ok := networks[net]; !ok && net != "default" {
+				v.fail(path+".networks", "undefined network %q", net)
+			}
+		}
+		mounts, _ := svc["volumes"].([]interface{})
+		for _, mount := range mounts {
+			spec, ok := mount.(string)
+			if !ok {
+				continue
+			}
+			source, _, bind := strings.Cut(spec, ":")
+			if !bind || strings.ContainsAny(source[:min(1, len(source))], "./~$") {
+				continue
+			}
+			if _, ok := volumes[source]; !ok {
+				v.fail(path+".volumes", "undefined volume %q", source)
+			}
+		}
+	}
+}
+
+// names returns the entries of a list or the keys of a map
+func names(value interface{}) []string {
+	var out []string
+	switch val := value.(type) {
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	case map[string]interface{}:
+		for key := range val {
+			out = append(out, key)
+		}
+	}
+	return out
+}
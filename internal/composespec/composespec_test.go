@@ -0,0 +1,98 @@
+package composespec
+
+import (
+	"strings"
+	"testing"
+)
+
+const validCompose = `name: shop
+services:
+  postgres:
+    image: postgres:16-alpine
+    ports:
+      - "${POSTGRES_PORT:-5432}:5432"
+      - 127.0.0.1:6000-6010:6000-6010/udp
+    volumes:
+      - postgres-data:/var/lib/postgresql/data
+      - ./init:/docker-entrypoint-initdb.d:ro
+    environment:
+      POSTGRES_PASSWORD: ${POSTGRES_PASSWORD}
+    healthcheck:
+      test: ["CMD-SHELL", "pg_isready -U postgres"]
+      interval: 10s
+      timeout: 1m30s
+      retries: 5
+    restart: unless-stopped
+    networks: [shop-network]
+    x-stackgen: kept
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile
+    depends_on:
+      postgres:
+        condition: service_healthy
+    shm_size: 256m
+    pull_policy: always
+volumes:
+  postgres-data:
+networks:
+  shop-network:
+    driver: bridge
+`
+
+func TestValidate(t *testing.T) {
+	if err := Validate(map[string]string{"docker-compose.yml": validCompose}); err != nil {
+		t.Fatalf("Valid compose file rejected: %v", err)
+	}
+
+	invalid := `services:
+  api:
+    image: api
+    ports: ["8080:http"]
+    healthcheck:
+      test: ["curl", "-f", "http://localhost"]
+      interval: 10
+    restart: sometimes
+    pull_policy: offline
+    depends_on: [db]
+    networks: [missing]
+    volumes: [cache:/cache]
+    develop:
+      watch:
+        - path: ./src
+          action: copy
+    entrypoin: ./run
+`
+	err := Validate(map[string]string{"docker-compose.yml": invalid})
+	if err == nil {
+		t.Fatal("Invalid compose file accepted")
+	}
+	for _, want := range []string{
+		`services.api.ports[0]: "8080:http" is not a valid port mapping`,
+		"services.api.healthcheck.test[0]: must be one of NONE, CMD, CMD-SHELL",
+		"services.api.healthcheck.interval: must be string, got integer",
+		`services.api.restart: "sometimes" is not a valid restart policy`,
+		`services.api.pull_policy: "offline" is not a valid pull policy`,
+		`services.api.depends_on: undefined service "db"`,
+		`services.api.networks: undefined network "missing"`,
+		`services.api.volumes: undefined volume "cache"`,
+		`services.api.develop.watch[0].action: "copy" is not one of`,
+		`services.api: unknown key "entrypoin"`,
+	} {
+		if !strings.Contains(err.Error(), "docker-compose.yml: "+want) {
+			t.Errorf("Missing error %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestValidateAcrossIncludes(t *testing.T) {
+	files := map[string]string{
+		"docker-compose.yml":            "include:\n  - docker-compose.datastores.yml\n  - docker-compose.runtimes.yml\n",
+		"docker-compose.datastores.yml": "services:\n  db:\n    image: postgres\n    networks: [app]\nnetworks:\n  app:\n",
+		"docker-compose.runtimes.yml":   "services:\n  api:\n    image: api\n    depends_on: [db]\n    networks: [app]\n",
+	}
+	if err := Validate(files); err != nil {
+		t.Errorf("References across included files should resolve: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+// Match is a runtime detected in a project directory
+type Match struct {
+	Type      models.RuntimeType
+	Framework string
+}
+
+// marker ties a runtime to the files that identify it and the dependency
+// names that identify each framework, checked in order
+type marker struct {
+	runtime    models.RuntimeType
+	files      []string
+	frameworks [][2]string // {framework, dependency substring}
+}
+
+var markers = []marker{
+	{
+		runtime: models.RuntimeGo,
+		files:   []string{"go.mod"},
+		frameworks: [][2]string{
+			{"gin", "github.com/gin-gonic/gin"},
+			{"fiber", "github.com/gofiber/fiber"},
+			{"echo", "github.com/labstack/echo"},
+		},
+	},
+	{
+		runtime: models.RuntimeNode,
+		files:   []string{"package.json"},
+		frameworks: [][2]string{
+			{"nextjs", `"next"`},
+			{"nestjs", `"@nestjs/core"`},
+			{"fastify", `"fastify"`},
+			{"express", `"express"`},
+		},
+	},
+	{
+		runtime: models.RuntimePython,
+		files:   []string{"requirements.txt", "pyproject.toml", "Pipfile"},
+		frameworks: [][2]string{
+			{"fastapi", "fastapi"},
+			{"django", "django"},
+			{"flask", "flask"},
+		},
+	},
+	{
+		runtime: models.RuntimeJava,
+		files:   []string{"pom.xml", "build.gradle", "build.gradle.kts"},
+		frameworks: [][2]string{
+			{"spring-boot", "spring-boot"},
+			{"quarkus", "quarkus"},
+			{"micronaut", "micronaut"},
+		},
+	},
+	{
+		runtime: models.RuntimeRust,
+		files:   []string{"Cargo.toml"},
+		frameworks: [][2]string{
+			{"actix-web", "actix-web"},
+			{"axum", "axum"},
+			{"rocket", "rocket"},
+		},
+	},
+	{
+		runtime: models.RuntimeCSharp,
+		files:   []string{"*.csproj"},
+		frameworks: [][2]string{
+			{"aspnetcore", "Microsoft.NET.Sdk.Web"},
+		},
+	},
+}
+
+// Runtimes inspects a directory for language manifests and returns every
+// runtime found. More than one match means the directory is ambiguous.
+// When no known dependency names a framework, the runtime's first
+// framework is used.
+func Runtimes(dir string) ([]Match, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	var matches []Match
+	for _, m := range markers {
+		content, found := readManifests(dir, m.files)
+		if !found {
+			continue
+		}
+
+		framework := models.GetRuntimeInfo(m.runtime).Frameworks[0]
+		lower := strings.ToLower(content)
+		for _, fw := range m.frameworks {
+			if strings.Contains(lower, strings.ToLower(fw[1])) {
+				framework = fw[0]
+				break
+			}
+		}
+		matches = append(matches, Match{Type: m.runtime, Framework: framework})
+	}
+	return matches, nil
+}
+
+// readManifests concatenates the manifest files matching the patterns
+func readManifests(dir string, patterns []string) (string, bool) {
+	var b strings.Builder
+	found := false
+	for _, pattern := range patterns {
+		paths, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			found = true
+			b.Write(data)
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), found
+}
@@ -0,0 +1,78 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectNextjs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"dependencies": {"next": "14.0.0", "react": "18.0.0"}}`)
+
+	matches, err := Runtimes(dir)
+	if err != nil {
+		t.Fatalf("Runtimes failed: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 match, got %d", len(matches))
+	}
+	if matches[0].Type != models.RuntimeNode || matches[0].Framework != "nextjs" {
+		t.Errorf("Expected node/nextjs, got %s/%s", matches[0].Type, matches[0].Framework)
+	}
+}
+
+func TestDetectGoDefaultFramework(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "module example.com/svc\n\ngo 1.22\n")
+
+	matches, err := Runtimes(dir)
+	if err != nil {
+		t.Fatalf("Runtimes failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Type != models.RuntimeGo || matches[0].Framework != "stdlib" {
+		t.Errorf("Expected go/stdlib, got %+v", matches)
+	}
+}
+
+func TestDetectCSharpGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "Api.csproj", `<Project Sdk="Microsoft.NET.Sdk.Web"></Project>`)
+
+	matches, err := Runtimes(dir)
+	if err != nil {
+		t.Fatalf("Runtimes failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Type != models.RuntimeCSharp {
+		t.Errorf("Expected csharp, got %+v", matches)
+	}
+}
+
+func TestDetectAmbiguous(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "package.json", `{"dependencies": {"express": "4.0.0"}}`)
+	writeFile(t, dir, "requirements.txt", "flask==3.0\n")
+
+	matches, err := Runtimes(dir)
+	if err != nil {
+		t.Fatalf("Runtimes failed: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("Expected 2 matches, got %d", len(matches))
+	}
+}
+
+func TestDetectMissingDir(t *testing.T) {
+	if _, err := Runtimes(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Missing directory should return an error")
+	}
+}
@@ -4,10 +4,19 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/stackgen-cli/stackgen/internal/models"
@@ -15,12 +24,99 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Options controls optional output transforms
+type Options struct {
+	// Minimal strips container_name, restart and healthcheck from services
+	Minimal bool
+	// Version is the stackgen version stamped into generated file headers
+	Version string
+	// Split writes datastores and runtimes to separate compose files
+	// included from the main compose file
+	Split bool
+	// BaseCompose is a shared compose file, relative to the output dir,
+	// that datastores extend for their image, healthcheck and restart policy
+	BaseCompose string
+	// ExistingBaseCompose is the current content of the BaseCompose file,
+	// if any; datastores only extend base services whose settings match
+	ExistingBaseCompose []byte
+	// EnvPrefix overrides the project's env_prefix when set
+	EnvPrefix string
+	// CI adds a workflow that starts the stack and runs the test
+	// containers ("github")
+	CI string
+	// CIWorkDir is the output directory relative to the repository root.
+	// The workflow is written to the root's .github/workflows and runs its
+	// steps from CIWorkDir.
+	CIWorkDir string
+	// WatchSync adds develop.watch rules to runtimes for docker compose
+	// watch, replacing the source bind mount
+	WatchSync bool
+	// InlineEnv writes non-secret env vars into each runtime's environment
+	// instead of referencing .env through env_file
+	InlineEnv bool
+	// DockerfileDir, when set, writes generated Dockerfiles to
+	// <DockerfileDir>/<runtime>/Dockerfile instead of the build context
+	DockerfileDir string
+	// Labels are added to runtime services and their built images, e.g.
+	// OCI source and revision labels
+	Labels map[string]string
+	// DatastoreOnly and RuntimeOnly leave the other half of the stack out
+	// of the compose file
+	DatastoreOnly bool
+	RuntimeOnly   bool
+	// ExternalNetwork overrides the project's external_network
+	ExternalNetwork string
+	// Indent is the number of spaces per level in generated compose
+	// files; zero means DefaultIndent
+	Indent int
+
+	// ComposeFileName is the name of the main compose file; empty means
+	// DefaultComposeFileName
+	ComposeFileName string
+	// PullPolicy overrides the project's pull_policy
+	PullPolicy string
+}
+
+// DefaultIndent is the compose file indentation used unless
+// Options.Indent is set, matching the compose convention
+const DefaultIndent = 2
+
+// DefaultComposeFileName is the main compose file written unless
+// Options.ComposeFileName is set
+const DefaultComposeFileName = "docker-compose.yml"
+
+// ComposeFileNames are the files docker compose loads without -f, in its
+// order of preference
+var ComposeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// FindComposeFile returns the compose file docker compose would load from
+// dir, or "" when there is none
+func FindComposeFile(dir string) string {
+	for _, name := range ComposeFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// ValidateIndent checks an indentation width accepted by the encoder
+func ValidateIndent(indent int) error {
+	if indent < 2 || indent > 9 {
+		return fmt.Errorf("indent must be between 2 and 9 spaces, got %d", indent)
+	}
+	return nil
+}
+
 // Generator handles the generation of Docker Compose configurations
 type Generator struct {
 	project    *models.Project
+	opts       Options
 	compose    *models.ComposeFile
 	envVars    []models.EnvVar
 	dockerfiles map[string]string
+	configFiles map[string]string
+	provenance  []Provenance
 }
 
 // New creates a new Generator
@@ -30,31 +126,128 @@ func New(project *models.Project) *Generator {
 		compose:     &models.ComposeFile{Services: make(map[string]models.ComposeService)},
 		envVars:     []models.EnvVar{},
 		dockerfiles: make(map[string]string),
+		configFiles: make(map[string]string),
 	}
 }
 
+// WithOptions sets output options and returns the generator for chaining
+func (g *Generator) WithOptions(opts Options) *Generator {
+	g.opts = opts
+	return g
+}
+
 // Generate creates all configuration files
 func (g *Generator) Generate() (*GeneratedOutput, error) {
+	if errs := models.ValidateNames(g.project); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if g.opts.Indent != 0 {
+		if err := ValidateIndent(g.opts.Indent); err != nil {
+			return nil, err
+		}
+	}
+	if name := g.opts.ComposeFileName; name != "" && (name != filepath.Base(name) || name == "." || name == "..") {
+		return nil, fmt.Errorf("compose file name %q must not contain a directory", name)
+	}
+
 	// Initialize networks
+	networkName := g.project.Name + "-network"
 	g.compose.Networks = map[string]interface{}{
-		g.project.Name + "-network": map[string]string{"driver": "bridge"},
+		networkName: map[string]string{"driver": "bridge"},
+	}
+	if external := g.externalNetwork(); external != "" {
+		if err := models.ValidateNetworkName(external); err != nil {
+			return nil, err
+		}
+		// Compose attaches to the existing network instead of creating it
+		networkName = external
+		g.compose.Networks = map[string]interface{}{
+			networkName: map[string]bool{"external": true},
+		}
 	}
 	g.compose.Volumes = make(map[string]interface{})
+	if err := models.ValidatePullPolicy(g.pullPolicy()); err != nil {
+		return nil, err
+	}
 
-	networkName := g.project.Name + "-network"
+	internalNetwork := g.project.Name + "-internal"
+	if g.usesInternalNetwork() {
+		g.compose.Networks[internalNetwork] = map[string]interface{}{"driver": "bridge", "internal": true}
+	}
 
 	// Process datastores
+	sqliteURL := "DATABASE_URL"
 	for _, ds := range g.project.Datastores {
-		service, envs, err := g.generateDatastoreService(ds, networkName)
+		if ds.Type == models.DatastorePostgres {
+			sqliteURL = ""
+		}
+	}
+	for _, ds := range g.project.Datastores {
+		if !ds.HasService() {
+			g.addSQLite(ds, sqliteURL)
+			sqliteURL = ""
+			continue
+		}
+		network := networkName
+		if ds.InternalNetwork {
+			network = internalNetwork
+		}
+		service, envs, err := g.generateDatastoreService(ds, network)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate datastore %s: %w", ds.Name, err)
 		}
+		service.StopGracePeriod = ds.StopGracePeriod
+		service.Init = ds.Init
+		if service.Ports, err = appendPortRanges(service.Ports, ds.PortRanges); err != nil {
+			return nil, fmt.Errorf("failed to generate datastore %s: %w", ds.Name, err)
+		}
+		if !ds.IsExposed() {
+			service.Expose = containerPorts(service.Ports)
+			service.Ports = nil
+		}
 		g.compose.Services[ds.Name] = service
 		g.envVars = append(g.envVars, envs...)
-		
-		// Add volume
-		volumeName := ds.Name + "-data"
-		g.compose.Volumes[volumeName] = map[string]interface{}{}
+		g.explainDatastore(ds)
+
+		if ds.MetricsPort > 0 {
+			networks := []string{network}
+			if network != networkName {
+				// Internal networks cannot publish the metrics port
+				networks = append(networks, networkName)
+			}
+			if err := g.addMetricsExporter(ds, networks); err != nil {
+				return nil, fmt.Errorf("failed to generate datastore %s: %w", ds.Name, err)
+			}
+		}
+
+		// Add volume, unless the data only lives in memory
+		if ds.Persistence != models.PersistenceNone {
+			volumeName := ds.Name + "-data"
+			g.compose.Volumes[volumeName] = map[string]interface{}{}
+		}
+	}
+
+	// Process tracing backend
+	if g.project.Jaeger {
+		g.compose.Services[JaegerServiceName] = g.generateJaegerService(networkName)
+		for _, key := range []string{"image", "container_name", "ports", "environment", "networks", "restart"} {
+			g.explain(JaegerServiceName, key, "jaeger", "stackgen's all-in-one tracing defaults")
+		}
+		g.envVars = append(g.envVars, models.EnvVar{
+			Key:         "OTEL_EXPORTER_OTLP_ENDPOINT",
+			Value:       fmt.Sprintf("http://%s:4318", JaegerServiceName),
+			Description: "OpenTelemetry OTLP endpoint (Jaeger)",
+		})
+	}
+
+	// Error tracking placeholder, shared by runtimes through .env
+	if g.project.Sentry && len(g.project.Runtimes) > 0 {
+		g.envVars = append(g.envVars, models.EnvVar{
+			Key:         "SENTRY_DSN",
+			Value:       "",
+			Description: "Sentry DSN for error tracking (leave empty to disable)",
+			Secret:      true,
+		})
 	}
 
 	// Process runtimes
@@ -63,29 +256,391 @@ func (g *Generator) Generate() (*GeneratedOutput, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate runtime %s: %w", rt.Name, err)
 		}
-		g.compose.Services[rt.Name] = service
+		// Runtimes bridge the default and internal networks
+		if g.usesInternalNetwork() {
+			service.Networks = append(service.Networks, internalNetwork)
+		}
+		if rt.Replicas > 1 {
+			if rt.PortMode != "" && rt.PortMode != models.PortModeHost {
+				return nil, fmt.Errorf("runtime %s: replicas behind a proxy cannot use port mode %s", rt.Name, rt.PortMode)
+			}
+			// The proxy only publishes the runtime's port; replicas
+			// cannot publish fixed host ports themselves
+			if len(rt.PortRanges) > 0 {
+				return nil, fmt.Errorf("runtime %s: replicas behind a proxy cannot publish port_ranges", rt.Name)
+			}
+			g.addProxy(rt, &service, networkName)
+		}
 		g.envVars = append(g.envVars, envs...)
-		if dockerfile != "" {
-			g.dockerfiles[rt.Name] = dockerfile
+		// A custom dockerfile path is supplied by the user, not generated
+		if dockerfile != "" && (rt.Dockerfile == "" || rt.Dockerfile == "Dockerfile") {
+			// Written into the build context, which compose builds from
+			dir := rt.BuildContext
+			if dir == "" {
+				dir = rt.Name
+			}
+			if g.opts.DockerfileDir != "" {
+				dir = g.dockerfileDir(rt, &service)
+			}
+			g.dockerfiles[dir] = dockerfile
+		}
+		g.compose.Services[rt.Name] = service
+		g.explainRuntime(rt)
+	}
+
+	if policy := g.pullPolicy(); policy != "" {
+		g.applyPullPolicy(policy)
+	}
+
+	if g.project.Timezone != "" {
+		g.applyTimezone(g.project.Timezone)
+		for name := range g.compose.Services {
+			g.explain(name, "environment", "timezone", "TZ="+g.project.Timezone)
+		}
+		for _, ds := range g.project.Datastores {
+			switch ds.Type {
+			case models.DatastorePostgres:
+				if _, ok := ds.Tuning["timezone"]; ok {
+					continue
+				}
+				g.explain(ds.Name, "command", "timezone", "postgres -c timezone sets the server time zone")
+			case models.DatastoreMySQL:
+				g.explain(ds.Name, "command", "timezone", "mysqld --default-time-zone sets the server time zone")
+			}
 		}
 	}
 
+	if err := g.validatePorts(); err != nil {
+		return nil, err
+	}
+
 	return g.buildOutput()
 }
 
+// dockerfileDir returns the directory for a runtime's Dockerfile under
+// Options.DockerfileDir and points the service's build at it. The build
+// context stays on the sources, so build.dockerfile is made relative to it.
+func (g *Generator) dockerfileDir(rt models.Runtime, service *models.ComposeService) string {
+	dir := filepath.ToSlash(filepath.Join(g.opts.DockerfileDir, rt.Name))
+	context := service.Build.Context
+	if context == "" {
+		context = "."
+	}
+	rel, err := filepath.Rel(context, filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		rel = filepath.Join(dir, "Dockerfile")
+	}
+	service.Build.Dockerfile = filepath.ToSlash(rel)
+	return dir
+}
+
+// bindSource returns a host path for a bind mount. Compose reads a bare
+// name as a named volume, so relative paths get a ./ prefix.
+func bindSource(path string) string {
+	if path == "." || strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || filepath.IsAbs(path) {
+		return path
+	}
+	return "./" + path
+}
+
+// ContainerName returns the container_name generated for a service
+func ContainerName(project *models.Project, service string) string {
+	return project.Name + "-" + service
+}
+
+// DatastoreVolumes returns the named volumes the compose file declares for
+// a datastore: its data volume, neo4j's logs and one per postgres replica
+func DatastoreVolumes(ds models.Datastore) []string {
+	if !ds.HasService() {
+		return nil
+	}
+	var volumes []string
+	if ds.Persistence != models.PersistenceNone {
+		volumes = append(volumes, ds.Name+"-data")
+	}
+	if ds.Type == models.DatastoreNeo4j {
+		volumes = append(volumes, ds.Name+"-logs")
+	}
+	if ds.Type == models.DatastorePostgres {
+		for i := 1; i <= ds.Replicas; i++ {
+			volumes = append(volumes, fmt.Sprintf("%s-replica-%d-data", ds.Name, i))
+		}
+	}
+	return volumes
+}
+
+// applyTimezone sets TZ on every service and the server time zone on
+// datastores that keep their own
+func (g *Generator) applyTimezone(tz string) {
+	for name, service := range g.compose.Services {
+		env := make(map[string]string, len(service.Environment)+1)
+		for k, v := range service.Environment {
+			env[k] = v
+		}
+		env["TZ"] = tz
+		service.Environment = env
+		g.compose.Services[name] = service
+	}
+
+	for _, ds := range g.project.Datastores {
+		if !ds.HasService() {
+			continue
+		}
+		service := g.compose.Services[ds.Name]
+		switch ds.Type {
+		case models.DatastorePostgres:
+			// PGTZ only applies to clients such as psql in the container;
+			// the server takes its zone from -c, unless tuning sets one
+			service.Environment["PGTZ"] = tz
+			if _, ok := ds.Tuning["timezone"]; !ok {
+				if service.Command == "" {
+					service.Command = "postgres"
+				}
+				service.Command += " -c timezone=" + tz
+			}
+		case models.DatastoreMySQL:
+			if service.Command == "" {
+				service.Command = "--default-time-zone=" + tz
+			} else if !strings.Contains(service.Command, "--default-time-zone") {
+				service.Command += " --default-time-zone=" + tz
+			}
+		}
+		g.compose.Services[ds.Name] = service
+	}
+}
+
+// sqliteMount bind-mounts the SQLite data directory into runtimes
+const sqliteMount = "./data:/data"
+
+// usesSQLite reports whether the project has a SQLite datastore
+func (g *Generator) usesSQLite() bool {
+	for _, ds := range g.project.Datastores {
+		if ds.Type == models.DatastoreSQLite {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyImage is the reverse proxy placed in front of scaled runtimes
+const proxyImage = "nginx:1.27-alpine"
+
+// proxyName returns the name of the proxy service for a runtime
+func proxyName(runtime string) string {
+	return models.ProxyServiceName(runtime)
+}
+
+// addProxy scales a runtime to rt.Replicas behind an nginx service that
+// publishes the runtime's port. Compose DNS resolves the service name to
+// every replica, so nginx balances across them; it resolves once at
+// startup, so restart the proxy after changing the replica count.
+func (g *Generator) addProxy(rt models.Runtime, service *models.ComposeService, network string) {
+	// Scaled services cannot have fixed names or host ports
+	service.Deploy = &models.ComposeDeploy{Replicas: rt.Replicas}
+	service.ContainerName = ""
+	service.Ports = nil
+
+	name := proxyName(rt.Name)
+	confPath := name + "/nginx.conf"
+	g.configFiles[confPath] = fmt.Sprintf(nginxProxyConf, rt.Name, rt.Name, rt.Name, rt.InternalPort, rt.Name)
+	g.compose.Services[name] = models.ComposeService{
+		Image:         proxyImage,
+		ContainerName: ContainerName(g.project, name),
+		Ports:         []string{fmt.Sprintf("%d:80", rt.Port)},
+		Volumes:       []string{fmt.Sprintf("./%s:/etc/nginx/conf.d/default.conf:ro", confPath)},
+		DependsOn:     models.DependsOnStarted(rt.Name),
+		Networks:      []string{network},
+		Restart:       "unless-stopped",
+	}
+	g.explain(name, "image", fmt.Sprintf("runtimes[%s].replicas", rt.Name), "nginx load balancer for the replicas")
+	g.explain(name, "ports", fmt.Sprintf("runtimes[%s].port", rt.Name), "the runtime's host port, published by the proxy")
+	g.explain(name, "volumes", "default", "generated "+confPath)
+}
+
+// nginxProxyConf balances across a runtime's replicas via its service name
+const nginxProxyConf = `# Generated by stackgen - load balances across the %s replicas
+upstream %s {
+    server %s:%d;
+}
+
+server {
+    listen 80;
+
+    location / {
+        proxy_pass http://%s;
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto $scheme;
+    }
+}
+`
+
+// metricsExporter is the Prometheus exporter sidecar of a datastore type
+type metricsExporter struct {
+	image string
+	port  int
+}
+
+// metricsExporters lists the exporter for each datastore type that has one
+var metricsExporters = map[models.DatastoreType]metricsExporter{
+	models.DatastorePostgres: {image: "quay.io/prometheuscommunity/postgres-exporter:v0.15.0", port: 9187},
+	models.DatastoreMySQL:    {image: "prom/mysqld-exporter:v0.15.1", port: 9104},
+	models.DatastoreRedis:    {image: "oliver006/redis_exporter:v1.62.0", port: 9121},
+}
+
+// MetricsExporterPort returns the metrics port of a datastore type's
+// exporter, or false when stackgen has no exporter for the type
+func MetricsExporterPort(t models.DatastoreType) (int, bool) {
+	exporter, ok := metricsExporters[t]
+	return exporter.port, ok
+}
+
+// exporterName returns the name of a datastore's exporter service
+func exporterName(datastore string) string {
+	return datastore + "-exporter"
+}
+
+// addMetricsExporter adds a Prometheus exporter sidecar publishing the
+// datastore's metrics on its metrics_port. It connects with the same
+// ${VAR} credentials the datastore reads from .env.
+func (g *Generator) addMetricsExporter(ds models.Datastore, networks []string) error {
+	exporter, ok := metricsExporters[ds.Type]
+	if !ok {
+		return fmt.Errorf("metrics exporters are only available for postgres, mysql and redis")
+	}
+
+	name := exporterName(ds.Name)
+	service := models.ComposeService{
+		Image:         exporter.image,
+		ContainerName: ContainerName(g.project, name),
+		Ports:         []string{fmt.Sprintf("%d:%d", ds.MetricsPort, exporter.port)},
+		DependsOn:     models.DependsOnStarted(ds.Name),
+		Networks:      networks,
+		Restart:       "unless-stopped",
+	}
+	switch ds.Type {
+	case models.DatastorePostgres:
+		credentials := "${POSTGRES_USER:-postgres}:${POSTGRES_PASSWORD}"
+		if ds.NoPassword {
+			credentials = "${POSTGRES_USER:-postgres}"
+		}
+		service.Environment = map[string]string{
+			"DATA_SOURCE_NAME": fmt.Sprintf("postgresql://%s@%s:5432/${POSTGRES_DB:-%s}?sslmode=disable", credentials, ds.Name, g.project.Name),
+		}
+	case models.DatastoreMySQL:
+		service.Command = fmt.Sprintf("--mysqld.address=%s:3306 --mysqld.username=root", ds.Name)
+		if !ds.NoPassword {
+			service.Environment = map[string]string{"MYSQLD_EXPORTER_PASSWORD": "${MYSQL_ROOT_PASSWORD}"}
+		}
+	case models.DatastoreRedis:
+		service.Environment = map[string]string{"REDIS_ADDR": fmt.Sprintf("redis://%s:6379", ds.Name)}
+		if !ds.NoPassword {
+			service.Environment["REDIS_PASSWORD"] = "${REDIS_PASSWORD}"
+		}
+	}
+	g.compose.Services[name] = service
+
+	field := fmt.Sprintf("datastores[%s].metrics_port", ds.Name)
+	g.explain(name, "image", field, "Prometheus exporter for "+string(ds.Type))
+	g.explain(name, "ports", field, "metrics published on the host")
+	g.explain(name, "environment", "datastores["+ds.Name+"]", "the datastore's credentials from .env")
+	return nil
+}
+
+// usesInternalNetwork reports whether any datastore is on the internal
+// network
+func (g *Generator) usesInternalNetwork() bool {
+	for _, ds := range g.project.Datastores {
+		if ds.InternalNetwork {
+			return true
+		}
+	}
+	return false
+}
+
+// addSQLite sets up a SQLite datastore. It has no service: runtimes mount
+// ./data (see sqliteMount) and find the file through key, or through
+// <NAME>_DATABASE_URL and data/<name>.db when key is empty because
+// postgres or another SQLite datastore already uses DATABASE_URL.
+func (g *Generator) addSQLite(ds models.Datastore, key string) {
+	// Creating ./data up front keeps Docker from creating it root-owned
+	g.configFiles["data/.gitkeep"] = ""
+	file := "app.db"
+	if key == "" {
+		key = databaseEnvKey(ds.Name) + "_DATABASE_URL"
+		file = ds.Name + ".db"
+	}
+	g.envVars = append(g.envVars, models.EnvVar{
+		Key:         key,
+		Value:       "sqlite:///data/" + file,
+		Description: "SQLite database file (mounted from ./data)",
+	})
+}
+
+// datastoreRepos maps datastore types to their image repositories
+var datastoreRepos = map[models.DatastoreType]string{
+	models.DatastorePostgres:   "postgres",
+	models.DatastoreMySQL:      "mysql",
+	models.DatastoreMSSQL:      "mcr.microsoft.com/mssql/server",
+	models.DatastoreNeo4j:      "neo4j",
+	models.DatastoreRedis:      "redis",
+	models.DatastoreRedisStack: "redis/redis-stack",
+}
+
+// DatastoreImageRef returns the image repository and tag generated for a
+// datastore
+func DatastoreImageRef(ds models.Datastore) (repo, tag string) {
+	tag = ds.Tag
+	if ds.Version != "" {
+		// Unknown versions are reported by Generate
+		if resolved, err := models.ResolveVersion(ds.Type, ds.Version); err == nil {
+			tag = resolved
+		}
+	}
+	if ds.Type == models.DatastoreNeo4j {
+		tag += "-community"
+	}
+	return datastoreRepos[ds.Type], tag
+}
+
+// datastoreImage returns the image reference for a datastore, preferring
+// the pinned digest over the tag
+func datastoreImage(ds models.Datastore) string {
+	repo, tag := DatastoreImageRef(ds)
+	if ds.Digest != "" {
+		return repo + "@" + ds.Digest
+	}
+	return repo + ":" + tag
+}
+
 func (g *Generator) generateDatastoreService(ds models.Datastore, network string) (models.ComposeService, []models.EnvVar, error) {
 	var service models.ComposeService
 	var envs []models.EnvVar
 
+	if ds.Version != "" {
+		if _, err := models.ResolveVersion(ds.Type, ds.Version); err != nil {
+			return service, nil, err
+		}
+	}
+
+	persistence, err := persistenceArgs(ds)
+	if err != nil {
+		return service, nil, err
+	}
+	charset, err := charsetArgs(ds)
+	if err != nil {
+		return service, nil, err
+	}
+
 	volumeName := ds.Name + "-data"
 	password := generatePassword(16)
 
 	switch ds.Type {
 	case models.DatastorePostgres:
 		service = models.ComposeService{
-			Image:         "postgres:" + ds.Tag,
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:5432", ds.Port)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 5432)},
 			Volumes:       []string{fmt.Sprintf("%s:/var/lib/postgresql/data", volumeName)},
 			Environment: map[string]string{
 				"POSTGRES_USER":     "${POSTGRES_USER:-postgres}",
@@ -102,6 +657,12 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 				StartPeriod: "10s",
 			},
 		}
+		if len(ds.Tuning) > 0 {
+			confPath := ds.Name + "/postgresql.conf"
+			g.configFiles[confPath] = postgresConf(ds.Tuning)
+			service.Volumes = append(service.Volumes, fmt.Sprintf("./%s:%s:ro", confPath, postgresConfTarget))
+			service.Command = "postgres -c config_file=" + postgresConfTarget
+		}
 		envs = []models.EnvVar{
 			{Key: "POSTGRES_USER", Value: "postgres", Description: "PostgreSQL username"},
 			{Key: "POSTGRES_PASSWORD", Value: password, Description: "PostgreSQL password", Secret: true},
@@ -111,9 +672,9 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 
 	case models.DatastoreMySQL:
 		service = models.ComposeService{
-			Image:         "mysql:" + ds.Tag,
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:3306", ds.Port)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 3306)},
 			Volumes:       []string{fmt.Sprintf("%s:/var/lib/mysql", volumeName)},
 			Environment: map[string]string{
 				"MYSQL_ROOT_PASSWORD": "${MYSQL_ROOT_PASSWORD}",
@@ -130,6 +691,7 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 				Retries:     5,
 				StartPeriod: "30s",
 			},
+			Command: charset,
 		}
 		rootPassword := generatePassword(16)
 		envs = []models.EnvVar{
@@ -142,9 +704,9 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 
 	case models.DatastoreMSSQL:
 		service = models.ComposeService{
-			Image:         "mcr.microsoft.com/mssql/server:" + ds.Tag,
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:1433", ds.Port)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 1433)},
 			Volumes:       []string{fmt.Sprintf("%s:/var/opt/mssql", volumeName)},
 			Environment: map[string]string{
 				"ACCEPT_EULA":       "Y",
@@ -170,9 +732,9 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 
 	case models.DatastoreNeo4j:
 		service = models.ComposeService{
-			Image:         "neo4j:" + ds.Tag + "-community",
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:7474", ds.Port), fmt.Sprintf("%d:7687", ds.Port+213)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 7474), publish(ds.Port, 213, 7687)},
 			Volumes: []string{
 				fmt.Sprintf("%s:/data", volumeName),
 				fmt.Sprintf("%s-logs:/logs", ds.Name),
@@ -198,11 +760,11 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 
 	case models.DatastoreRedis:
 		service = models.ComposeService{
-			Image:         "redis:" + ds.Tag,
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:6379", ds.Port)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 6379)},
 			Volumes:       []string{fmt.Sprintf("%s:/data", volumeName)},
-			Command:       "redis-server --appendonly yes --requirepass ${REDIS_PASSWORD}",
+			Command:       "redis-server " + persistence + " --requirepass ${REDIS_PASSWORD}",
 			Networks:      []string{network},
 			Restart:       "unless-stopped",
 			HealthCheck: &models.ComposeHealth{
@@ -220,9 +782,9 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 
 	case models.DatastoreRedisStack:
 		service = models.ComposeService{
-			Image:         "redis/redis-stack:" + ds.Tag,
-			ContainerName: g.project.Name + "-" + ds.Name,
-			Ports:         []string{fmt.Sprintf("%d:6379", ds.Port), fmt.Sprintf("%d:8001", ds.Port+1622)},
+			Image:         datastoreImage(ds),
+			ContainerName: ContainerName(g.project, ds.Name),
+			Ports:         []string{publish(ds.Port, 0, 6379), publish(ds.Port, 1622, 8001)},
 			Volumes:       []string{fmt.Sprintf("%s:/data", volumeName)},
 			Environment: map[string]string{
 				"REDIS_ARGS": "--requirepass ${REDIS_STACK_PASSWORD}",
@@ -243,176 +805,2430 @@ func (g *Generator) generateDatastoreService(ds models.Datastore, network string
 		}
 	}
 
-	return service, envs, nil
-}
-
-func (g *Generator) generateRuntimeService(rt models.Runtime, network string) (models.ComposeService, []models.EnvVar, string, error) {
-	service := models.ComposeService{
-		Build: &models.ComposeBuild{
-			Context:    rt.BuildContext,
-			Dockerfile: rt.Dockerfile,
-		},
-		ContainerName: g.project.Name + "-" + rt.Name,
-		Ports:         []string{fmt.Sprintf("%d:%d", rt.Port, rt.InternalPort)},
-		Volumes:       []string{fmt.Sprintf("./%s:/app", rt.BuildContext)},
-		EnvFile:       []string{".env"},
-		Networks:      []string{network},
-		Restart:       "unless-stopped",
-		DependsOn:     rt.DependsOn,
+	if ds.NoPassword {
+		var err error
+		if envs, err = g.disableAuth(ds, &service); err != nil {
+			return service, nil, err
+		}
 	}
 
-	var envs []models.EnvVar
-	var dockerfile string
-
-	switch rt.Type {
-	case models.RuntimeGo:
-		dockerfile = templates.GoDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "GO_ENV", Value: "development", Description: "Go environment"},
-			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+	if ds.Type == models.DatastoreRedisStack && persistence != "" {
+		// The image splits REDIS_ARGS on spaces without unquoting, so
+		// save "" would reach redis as a literal; an included config file
+		// is parsed by redis itself
+		confPath := ds.Name + "/persistence.conf"
+		g.configFiles[confPath] = redisConf(persistence)
+		service.Volumes = append(service.Volumes, fmt.Sprintf("./%s:%s:ro", confPath, redisStackConfTarget))
+		if service.Environment == nil {
+			service.Environment = make(map[string]string)
 		}
+		service.Environment["REDIS_ARGS"] = strings.TrimSpace(service.Environment["REDIS_ARGS"] + " --include " + redisStackConfTarget)
+	}
+	if ds.Persistence == models.PersistenceNone {
+		// Keep nothing across restarts, e.g. for a throwaway cache
+		service.Volumes = slices.DeleteFunc(service.Volumes, func(v string) bool { return strings.HasSuffix(v, ":/data") })
+		service.Tmpfs = append(service.Tmpfs, "/data")
+	}
 
-	case models.RuntimeNode:
-		dockerfile = templates.NodeDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "NODE_ENV", Value: "development", Description: "Node environment"},
-			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+	if len(ds.Databases) > 0 {
+		dbEnvs, err := g.postgresDatabases(ds, &service, password)
+		if err != nil {
+			return service, nil, err
 		}
+		envs = append(envs, dbEnvs...)
+	}
 
-	case models.RuntimePython:
-		dockerfile = templates.PythonDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "PYTHON_ENV", Value: "development", Description: "Python environment"},
-			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
-		}
+	ulimits, err := datastoreUlimits(ds)
+	if err != nil {
+		return service, nil, err
+	}
+	service.Ulimits = ulimits
+	service.CapAdd = ds.CapAdd
+	service.CapDrop = ds.CapDrop
+	service.SecurityOpt = ds.SecurityOpt
+	if service.ShmSize, err = datastoreShmSize(ds); err != nil {
+		return service, nil, err
+	}
+	if err := models.ValidateMemSwappiness(ds.MemSwappiness); err != nil {
+		return service, nil, fmt.Errorf("%s: %w", ds.Name, err)
+	}
+	service.MemSwappiness = ds.MemSwappiness
+	service.OOMKillDisable = ds.OOMKillDisable
 
-	case models.RuntimeJava:
-		dockerfile = templates.JavaDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "JAVA_ENV", Value: "development", Description: "Java environment"},
-			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+	if ds.SeedFile != "" {
+		mount, err := seedMount(ds)
+		if err != nil {
+			return service, nil, err
 		}
+		service.Volumes = append(service.Volumes, mount)
+	}
 
-	case models.RuntimeRust:
-		dockerfile = templates.RustDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "RUST_ENV", Value: "development", Description: "Rust environment"},
-			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+	if ds.ReadOnlyRootFS {
+		if err := readOnlyRoot(ds, &service); err != nil {
+			return service, nil, err
 		}
+	}
 
-	case models.RuntimeCSharp:
-		dockerfile = templates.CSharpDockerfile(rt.Framework)
-		envs = []models.EnvVar{
-			{Key: "ASPNETCORE_ENVIRONMENT", Value: "Development", Description: ".NET environment"},
-			{Key: "ASPNETCORE_URLS", Value: fmt.Sprintf("http://+:%d", rt.InternalPort), Description: "ASP.NET Core URLs"},
+	if ds.Replicas > 0 {
+		if ds.Type != models.DatastorePostgres {
+			return service, nil, fmt.Errorf("replicas are only supported for postgres")
 		}
+		envs = append(envs, g.generatePostgresReplicas(ds, &service, network, password)...)
 	}
 
-	return service, envs, dockerfile, nil
+	if ds.DisableHealthCheck {
+		service.HealthCheck = nil
+	}
+
+	return service, envs, nil
 }
 
-func (g *Generator) buildOutput() (*GeneratedOutput, error) {
-	output := &GeneratedOutput{
-		Dockerfiles: g.dockerfiles,
+// datastoreUlimits merges the type's required ulimits with the datastore's
+// overrides into compose form
+func datastoreUlimits(ds models.Datastore) (map[string]interface{}, error) {
+	values := make(map[string]string)
+	for name, value := range models.GetDatastoreInfo(ds.Type).Ulimits {
+		values[name] = value
 	}
-
-	// Generate docker-compose.yml
-	composeYAML, err := yaml.Marshal(g.compose)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal compose file: %w", err)
+	for name, value := range ds.Ulimits {
+		values[name] = value
 	}
-	output.ComposeYAML = addComposeHeader(string(composeYAML))
-
-	// Generate .env
-	var envBuilder strings.Builder
-	envBuilder.WriteString("# Generated by stackgen - For local development only\n")
-	envBuilder.WriteString("# WARNING: Do not commit this file to version control\n\n")
-	for _, env := range g.envVars {
-		if env.Description != "" {
-			envBuilder.WriteString(fmt.Sprintf("# %s\n", env.Description))
-		}
-		envBuilder.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+	if len(values) == 0 {
+		return nil, nil
 	}
-	output.EnvFile = envBuilder.String()
 
-	// Generate .env.example
-	var envExampleBuilder strings.Builder
-	envExampleBuilder.WriteString("# Environment variables for stackgen\n")
-	envExampleBuilder.WriteString("# Copy this file to .env and fill in the values\n\n")
-	for _, env := range g.envVars {
-		if env.Description != "" {
-			envExampleBuilder.WriteString(fmt.Sprintf("# %s\n", env.Description))
-		}
-		if env.Secret {
-			envExampleBuilder.WriteString(fmt.Sprintf("%s=<your-%s>\n", env.Key, strings.ToLower(strings.ReplaceAll(env.Key, "_", "-"))))
-		} else {
-			envExampleBuilder.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+	ulimits := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		limit, err := ParseUlimit(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s ulimit %s: %w", ds.Name, name, err)
 		}
+		ulimits[name] = limit
 	}
-	output.EnvExampleFile = envExampleBuilder.String()
+	return ulimits, nil
+}
 
-	// Generate .gitignore
-	output.GitIgnore = templates.GitIgnore()
+// shmSizePattern matches docker byte sizes such as 256m or 1g
+var shmSizePattern = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
 
-	return output, nil
+// datastoreShmSize returns the datastore's shm_size, defaulting to the
+// type's
+func datastoreShmSize(ds models.Datastore) (string, error) {
+	if ds.ShmSize == "" {
+		return models.GetDatastoreInfo(ds.Type).ShmSize, nil
+	}
+	if !shmSizePattern.MatchString(ds.ShmSize) {
+		return "", fmt.Errorf("%s shm_size %q: expected a size such as 256m or 1g", ds.Name, ds.ShmSize)
+	}
+	return ds.ShmSize, nil
 }
 
-// GeneratedOutput holds all generated files
-type GeneratedOutput struct {
-	ComposeYAML    string
-	EnvFile        string
+// ParseUlimit converts a docker-style soft[:hard] ulimit to its compose
+// form: a single number when both limits are equal, else {soft, hard}
+func ParseUlimit(value string) (interface{}, error) {
+	softValue, hardValue, ranged := strings.Cut(value, ":")
+	soft, err := strconv.Atoi(softValue)
+	if err != nil || soft < -1 {
+		return nil, fmt.Errorf("invalid limit %q, expected soft[:hard]", value)
+	}
+	if !ranged {
+		return soft, nil
+	}
+	hard, err := strconv.Atoi(hardValue)
+	if err != nil || hard < -1 {
+		return nil, fmt.Errorf("invalid limit %q, expected soft[:hard]", value)
+	}
+	if hard == soft {
+		return soft, nil
+	}
+	return map[string]int{"soft": soft, "hard": hard}, nil
+}
+
+// seedExtensions are the fixture formats the postgres and mysql entrypoints
+// run from /docker-entrypoint-initdb.d
+var seedExtensions = []string{".sql", ".sql.gz", ".sh"}
+
+// seedMount returns the volume mounting a datastore's seed file into its
+// init directory. Scripts there run once, in name order, on an empty data
+// volume; the zz- prefix makes the seed run after any schema scripts.
+func seedMount(ds models.Datastore) (string, error) {
+	if ds.Type != models.DatastorePostgres && ds.Type != models.DatastoreMySQL {
+		return "", fmt.Errorf("seed data is only supported for postgres and mysql")
+	}
+	for _, ext := range seedExtensions {
+		if strings.HasSuffix(ds.SeedFile, ext) {
+			return fmt.Sprintf("%s:/docker-entrypoint-initdb.d/zz-seed%s:ro", bindSource(ds.SeedFile), ext), nil
+		}
+	}
+	return "", fmt.Errorf("seed file %s must end in %s", ds.SeedFile, strings.Join(seedExtensions, ", "))
+}
+
+// databaseNamePattern matches names that are safe to quote in the init
+// script and to turn into env var keys
+var databaseNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// ValidateDatabaseName checks an extra database name
+func ValidateDatabaseName(name string) error {
+	if !databaseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid database name %q: use letters, digits, _ and -, starting with a letter or _", name)
+	}
+	return nil
+}
+
+// databaseEnvKey turns a database name into its env var key prefix
+func databaseEnvKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// postgresDatabases mounts an init script creating the datastore's extra
+// databases, with a login role each when database_roles is set, and
+// returns a <NAME>_DATABASE_URL per database
+func (g *Generator) postgresDatabases(ds models.Datastore, service *models.ComposeService, password string) ([]models.EnvVar, error) {
+	if ds.Type != models.DatastorePostgres {
+		return nil, fmt.Errorf("multiple databases are only supported for postgres")
+	}
+
+	env := make(map[string]string, len(service.Environment)+len(ds.Databases))
+	for k, v := range service.Environment {
+		env[k] = v
+	}
+	var envs []models.EnvVar
+	databases := make([]templates.PostgresDatabase, 0, len(ds.Databases))
+	seen := make(map[string]bool, len(ds.Databases))
+	for _, name := range ds.Databases {
+		if err := ValidateDatabaseName(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", ds.Name, err)
+		}
+		key := databaseEnvKey(name)
+		if seen[key] {
+			return nil, fmt.Errorf("%s database %q is listed twice", ds.Name, name)
+		}
+		seen[key] = true
+
+		db := templates.PostgresDatabase{Name: name}
+		user, userPassword := "postgres", password
+		if ds.DatabaseRoles {
+			db.Role, user = name, name
+			userPassword = generatePassword(16)
+			if !ds.NoPassword {
+				db.PasswordVar = key + "_DB_PASSWORD"
+				env[db.PasswordVar] = "${" + db.PasswordVar + "}"
+				envs = append(envs, models.EnvVar{Key: db.PasswordVar, Value: userPassword, Description: "PostgreSQL password of role " + name, Secret: true})
+			}
+		}
+		databases = append(databases, db)
+
+		credentials := user + ":" + userPassword
+		if ds.NoPassword {
+			credentials = user
+		}
+		envs = append(envs, models.EnvVar{
+			Key:         key + "_DATABASE_URL",
+			Value:       fmt.Sprintf("postgresql://%s@%s:5432/%s", credentials, ds.Name, name),
+			Description: "PostgreSQL connection string for " + name,
+			Secret:      !ds.NoPassword,
+		})
+	}
+
+	scriptPath := ds.Name + "/init-databases.sh"
+	g.configFiles[scriptPath] = templates.PostgresDatabasesInit(databases)
+	service.Volumes = append(service.Volumes, fmt.Sprintf("./%s:/docker-entrypoint-initdb.d/init-databases.sh:ro", scriptPath))
+	service.Environment = env
+	return envs, nil
+}
+
+// charsetArgs returns the mysqld arguments for a datastore's character set
+// and collation, empty when neither is set
+func charsetArgs(ds models.Datastore) (string, error) {
+	if ds.Charset == "" && ds.Collation == "" {
+		return "", nil
+	}
+	if ds.Type != models.DatastoreMySQL {
+		return "", fmt.Errorf("charset and collation are only supported for mysql")
+	}
+	if err := models.ValidateCharset(ds.Charset, ds.Collation); err != nil {
+		return "", err
+	}
+	var args []string
+	if ds.Charset != "" {
+		args = append(args, "--character-set-server="+ds.Charset)
+	}
+	if ds.Collation != "" {
+		args = append(args, "--collation-server="+ds.Collation)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// redisPersistence maps persistence modes to redis-server arguments
+var redisPersistence = map[string]string{
+	models.PersistenceNone: `--save "" --appendonly no`,
+	models.PersistenceRDB:  "--appendonly no",
+	models.PersistenceAOF:  `--appendonly yes --save ""`,
+	models.PersistenceBoth: "--appendonly yes",
+}
+
+// redisStackConfTarget is where a Redis Stack datastore's generated
+// persistence settings are mounted
+const redisStackConfTarget = "/etc/redis/persistence.conf"
+
+// redisConf turns redis-server arguments into config file lines
+func redisConf(args string) string {
+	var b strings.Builder
+	b.WriteString("# Redis persistence - Generated by stackgen\n")
+	b.WriteString("# Settings from the datastore's persistence mode in stackgen.yaml\n\n")
+	for _, setting := range strings.Split(strings.TrimPrefix(args, "--"), " --") {
+		b.WriteString(setting + "\n")
+	}
+	return b.String()
+}
+
+// persistenceArgs returns the redis-server arguments for a datastore's
+// persistence mode. Redis defaults to --appendonly yes, which keeps the
+// default snapshots too; Redis Stack keeps its image defaults.
+func persistenceArgs(ds models.Datastore) (string, error) {
+	if err := models.ValidatePersistence(ds.Persistence); err != nil {
+		return "", err
+	}
+	switch ds.Type {
+	case models.DatastoreRedis, models.DatastoreRedisStack:
+	default:
+		if ds.Persistence != "" {
+			return "", fmt.Errorf("persistence is only supported for redis and redis-stack")
+		}
+		return "", nil
+	}
+	if ds.Persistence == "" {
+		if ds.Type == models.DatastoreRedis {
+			return redisPersistence[models.PersistenceBoth], nil
+		}
+		return "", nil
+	}
+	return redisPersistence[ds.Persistence], nil
+}
+
+// readOnlyTmpfs lists the paths each datastore writes outside its data
+// volume (sockets, pid and temp files), mounted as tmpfs under read_only
+var readOnlyTmpfs = map[models.DatastoreType][]string{
+	models.DatastorePostgres:   {"/var/run/postgresql", "/tmp"},
+	models.DatastoreMySQL:      {"/var/run/mysqld", "/var/lib/mysql-files", "/tmp"},
+	models.DatastoreMSSQL:      {"/tmp"},
+	models.DatastoreRedis:      {"/tmp"},
+	models.DatastoreRedisStack: {"/tmp"},
+}
+
+// readOnlyRoot sets read_only on a datastore service and adds its tmpfs
+// mounts. Neo4j is refused since its entrypoint rewrites neo4j.conf.
+func readOnlyRoot(ds models.Datastore, service *models.ComposeService) error {
+	tmpfs, ok := readOnlyTmpfs[ds.Type]
+	if !ok {
+		return fmt.Errorf("%s does not support a read-only root filesystem", ds.Type)
+	}
+	service.ReadOnly = true
+	service.Tmpfs = append(service.Tmpfs, tmpfs...)
+	return nil
+}
+
+// generatePostgresReplicas configures the primary for streaming replication
+// and adds one hot-standby service per replica, each seeded from the primary
+// with pg_basebackup on first start
+func (g *Generator) generatePostgresReplicas(ds models.Datastore, primary *models.ComposeService, network, password string) []models.EnvVar {
+	initPath := ds.Name + "/init-replication.sh"
+	entrypointPath := ds.Name + "/replica-entrypoint.sh"
+	g.configFiles[initPath] = templates.PostgresReplicationInit()
+	g.configFiles[entrypointPath] = templates.PostgresReplicaEntrypoint()
+
+	primary.Volumes = append(primary.Volumes, fmt.Sprintf("./%s:/docker-entrypoint-initdb.d/init-replication.sh:ro", initPath))
+	env := make(map[string]string, len(primary.Environment)+1)
+	for k, v := range primary.Environment {
+		env[k] = v
+	}
+	env["POSTGRES_REPLICATION_PASSWORD"] = "${POSTGRES_REPLICATION_PASSWORD}"
+	primary.Environment = env
+
+	credentials := "postgres:" + password
+	if ds.NoPassword {
+		credentials = "postgres"
+	}
+
+	envs := []models.EnvVar{
+		{Key: "POSTGRES_REPLICATION_PASSWORD", Value: generatePassword(16), Description: "PostgreSQL replication user password", Secret: true},
+	}
+	for i := 1; i <= ds.Replicas; i++ {
+		name := fmt.Sprintf("%s-replica-%d", ds.Name, i)
+		volumeName := name + "-data"
+		g.compose.Volumes[volumeName] = map[string]interface{}{}
+		replica := models.ComposeService{
+			Image:         primary.Image,
+			ContainerName: ContainerName(g.project, name),
+			Ports:         []string{publish(ds.Port, i, 5432)},
+			Volumes: []string{
+				fmt.Sprintf("%s:/var/lib/postgresql/data", volumeName),
+				fmt.Sprintf("./%s:/usr/local/bin/replica-entrypoint.sh:ro", entrypointPath),
+			},
+			Environment: map[string]string{
+				"PRIMARY_HOST": ds.Name,
+				"PGPASSWORD":   "${POSTGRES_REPLICATION_PASSWORD}",
+			},
+			Command:   "sh /usr/local/bin/replica-entrypoint.sh",
+			User:      "postgres",
+			DependsOn: models.DependsOnStarted(ds.Name),
+			Networks:  []string{network},
+			Restart:   "unless-stopped",
+			HealthCheck: &models.ComposeHealth{
+				Test:        []string{"CMD-SHELL", "pg_isready -U postgres"},
+				Interval:    "10s",
+				Timeout:     "5s",
+				Retries:     5,
+				StartPeriod: "30s",
+			},
+		}
+		if ds.DisableHealthCheck {
+			replica.HealthCheck = nil
+		}
+		replica.CapAdd = ds.CapAdd
+		replica.CapDrop = ds.CapDrop
+		replica.SecurityOpt = ds.SecurityOpt
+		replica.ShmSize = primary.ShmSize
+		replica.MemSwappiness = ds.MemSwappiness
+		replica.OOMKillDisable = ds.OOMKillDisable
+		if ds.ReadOnlyRootFS {
+			replica.ReadOnly = true
+			replica.Tmpfs = readOnlyTmpfs[ds.Type]
+		}
+		if !ds.IsExposed() {
+			replica.Expose = containerPorts(replica.Ports)
+			replica.Ports = nil
+		}
+		g.compose.Services[name] = replica
+		envs = append(envs, models.EnvVar{
+			Key:         fmt.Sprintf("DATABASE_REPLICA_%d_URL", i),
+			Value:       fmt.Sprintf("postgresql://%s@%s:5432/%s", credentials, name, g.project.Name),
+			Description: fmt.Sprintf("PostgreSQL read replica %d connection string", i),
+			Secret:      !ds.NoPassword,
+		})
+	}
+	return envs
+}
+
+// insecureNote labels env vars of datastores running without auth
+const insecureNote = " (INSECURE: no password, local development only)"
+
+// disableAuth switches a datastore service to passwordless access and
+// returns the env vars that replace its credentialed ones
+func (g *Generator) disableAuth(ds models.Datastore, service *models.ComposeService) ([]models.EnvVar, error) {
+	switch ds.Type {
+	case models.DatastorePostgres:
+		service.Environment = map[string]string{
+			"POSTGRES_USER":             "${POSTGRES_USER:-postgres}",
+			"POSTGRES_DB":               "${POSTGRES_DB:-" + g.project.Name + "}",
+			"POSTGRES_HOST_AUTH_METHOD": "trust",
+		}
+		return []models.EnvVar{
+			{Key: "POSTGRES_USER", Value: "postgres", Description: "PostgreSQL username"},
+			{Key: "POSTGRES_DB", Value: g.project.Name, Description: "PostgreSQL database name"},
+			{Key: "DATABASE_URL", Value: fmt.Sprintf("postgresql://postgres@%s:5432/%s", ds.Name, g.project.Name), Description: "PostgreSQL connection string" + insecureNote},
+		}, nil
+
+	case models.DatastoreMySQL:
+		service.Environment = map[string]string{
+			"MYSQL_ALLOW_EMPTY_PASSWORD": "yes",
+			"MYSQL_DATABASE":             "${MYSQL_DATABASE:-" + g.project.Name + "}",
+		}
+		return []models.EnvVar{
+			{Key: "MYSQL_DATABASE", Value: g.project.Name, Description: "MySQL database name"},
+			{Key: "MYSQL_URL", Value: fmt.Sprintf("mysql://root@%s:3306/%s", ds.Name, g.project.Name), Description: "MySQL connection string" + insecureNote},
+		}, nil
+
+	case models.DatastoreNeo4j:
+		service.Environment = map[string]string{"NEO4J_AUTH": "none"}
+		return []models.EnvVar{
+			{Key: "NEO4J_URI", Value: fmt.Sprintf("bolt://%s:7687", ds.Name), Description: "Neo4j Bolt connection URI" + insecureNote},
+		}, nil
+
+	case models.DatastoreRedis:
+		service.Command = strings.TrimSuffix(service.Command, " --requirepass ${REDIS_PASSWORD}")
+		service.HealthCheck.Test = []string{"CMD", "redis-cli", "ping"}
+		return []models.EnvVar{
+			{Key: "REDIS_URL", Value: fmt.Sprintf("redis://%s:6379", ds.Name), Description: "Redis connection string" + insecureNote},
+		}, nil
+
+	case models.DatastoreRedisStack:
+		service.Environment = nil
+		service.HealthCheck.Test = []string{"CMD", "redis-cli", "ping"}
+		return []models.EnvVar{
+			{Key: "REDIS_STACK_URL", Value: fmt.Sprintf("redis://%s:6379", ds.Name), Description: "Redis Stack connection string" + insecureNote},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%s does not support running without a password", ds.Type)
+}
+
+// publish maps host port base+offset to a container port. A base of 0
+// publishes the container port alone so Docker picks a free host port.
+func publish(base, offset, container int) string {
+	if base == 0 {
+		return strconv.Itoa(container)
+	}
+	return fmt.Sprintf("%d:%d", base+offset, container)
+}
+
+// hostPort is a published host port; an empty ip binds all interfaces
+type hostPort struct {
+	ip       string
+	port     int
+	protocol string
+	service  string
+}
+
+// validatePorts checks the final services for host ports published twice,
+// which docker compose only reports at 'up'. Ports come from the compose
+// service map, so anything add or a hand-edited config produced is seen.
+func (g *Generator) validatePorts() error {
+	names := make([]string, 0, len(g.compose.Services))
+	for name := range g.compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	claimed := make(map[string][]hostPort)
+	reported := make(map[string]bool)
+	var errs []error
+	for _, name := range names {
+		for _, spec := range g.compose.Services[name].Ports {
+			for _, hp := range hostPorts(name, spec) {
+				key := fmt.Sprintf("%d/%s", hp.port, hp.protocol)
+				for _, other := range claimed[key] {
+					if other.service == name || (other.ip != hp.ip && other.ip != "" && hp.ip != "") {
+						continue
+					}
+					pair := other.service + "|" + name + "|" + key
+					if reported[pair] {
+						continue
+					}
+					reported[pair] = true
+					errs = append(errs, fmt.Errorf("host port %d is published by both %s and %s", hp.port, other.service, name))
+				}
+				claimed[key] = append(claimed[key], hp)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("host port conflicts (run 'stackgen doctor --fix' or change the ports in the config):\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// hostPorts returns the host ports a compose ports entry publishes, in
+// [ip:]host[-end]:container[/protocol] form. Entries without a fixed host
+// port, and interpolated ones, publish nothing known in advance.
+func hostPorts(service, spec string) []hostPort {
+	if strings.Contains(spec, "$") {
+		return nil
+	}
+	mapping, protocol, _ := strings.Cut(spec, "/")
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	parts := strings.Split(mapping, ":")
+	if len(parts) < 2 {
+		return nil
+	}
+	ip := strings.Join(parts[:len(parts)-2], ":")
+	if ip == "0.0.0.0" {
+		ip = ""
+	}
+	host := parts[len(parts)-2]
+	from, to, isRange := strings.Cut(host, "-")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return nil
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.Atoi(to); err != nil {
+			return nil
+		}
+	}
+
+	var out []hostPort
+	for port := start; port <= end; port++ {
+		out = append(out, hostPort{ip: ip, port: port, protocol: protocol, service: service})
+	}
+	return out
+}
+
+// appendPortRanges adds port_ranges entries to a service's ports
+func appendPortRanges(ports, ranges []string) ([]string, error) {
+	for _, spec := range ranges {
+		r, err := models.ParsePortRange(spec)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, r.String())
+	}
+	return ports, nil
+}
+
+// containerPorts returns the container side of host:container mappings,
+// for services reachable only on the compose network
+func containerPorts(ports []string) []string {
+	exposed := make([]string, 0, len(ports))
+	for _, p := range ports {
+		exposed = append(exposed, p[strings.LastIndex(p, ":")+1:])
+	}
+	return exposed
+}
+
+// JaegerServiceName is the compose service name of the tracing backend
+const JaegerServiceName = models.JaegerServiceName
+
+func (g *Generator) generateJaegerService(network string) models.ComposeService {
+	return models.ComposeService{
+		Image:         "jaegertracing/all-in-one:1.62.0",
+		ContainerName: ContainerName(g.project, JaegerServiceName),
+		Ports: []string{
+			"16686:16686", // UI
+			"4317:4317",   // OTLP gRPC
+			"4318:4318",   // OTLP HTTP
+		},
+		Environment: map[string]string{
+			"COLLECTOR_OTLP_ENABLED": "true",
+		},
+		Networks: []string{network},
+		Restart:  "unless-stopped",
+	}
+}
+
+func (g *Generator) generateRuntimeService(rt models.Runtime, network string) (models.ComposeService, []models.EnvVar, string, error) {
+	service := models.ComposeService{
+		Build: &models.ComposeBuild{
+			Context:    rt.BuildContext,
+			Dockerfile: rt.Dockerfile,
+		},
+		ContainerName:   ContainerName(g.project, rt.Name),
+		Ports:           []string{fmt.Sprintf("%d:%d", rt.Port, rt.InternalPort)},
+		Volumes:         []string{bindSource(rt.BuildContext) + ":/app"},
+		EnvFile:         runtimeEnvFiles(rt),
+		Networks:        []string{network},
+		Restart:         "unless-stopped",
+		StopGracePeriod: rt.StopGracePeriod,
+		Init:            rt.Init,
+	}
+	switch rt.PortMode {
+	case "", models.PortModeHost:
+	case models.PortModeNone:
+		// Still reachable by other services on the compose network
+		service.Expose = containerPorts(service.Ports)
+		service.Ports = nil
+	case models.PortModeRange:
+		// Scaled replicas each take a host port from the range, which
+		// compose only allows without a fixed container name
+		last := rt.Port + rt.PortRangeSize() - 1
+		service.Ports = []string{fmt.Sprintf("%d-%d:%d", rt.Port, last, rt.InternalPort)}
+		service.ContainerName = ""
+	default:
+		return service, nil, "", models.ValidatePortMode(rt.PortMode)
+	}
+	ports, err := appendPortRanges(service.Ports, rt.PortRanges)
+	if err != nil {
+		return service, nil, "", err
+	}
+	service.Ports = ports
+	// SQLite has no service to wait for
+	noService := make(map[string]bool)
+	for _, ds := range g.project.Datastores {
+		if !ds.HasService() {
+			noService[ds.Name] = true
+		}
+	}
+	var deps []string
+	for _, name := range rt.DependsOn {
+		if !noService[name] {
+			deps = append(deps, name)
+		}
+	}
+	if g.project.Jaeger {
+		deps = append(append([]string{}, deps...), JaegerServiceName)
+	}
+	service.DependsOn = g.dependsOn(deps)
+	if g.usesSQLite() {
+		service.Volumes = append(service.Volumes, sqliteMount)
+	}
+	if len(rt.Environment) > 0 {
+		service.Environment = make(map[string]string, len(rt.Environment))
+		for k, v := range rt.Environment {
+			service.Environment[k] = v
+		}
+	}
+
+	var envs []models.EnvVar
+	var dockerfile string
+
+	if rt.PackageManager != "" && rt.Type != models.RuntimeNode {
+		return service, nil, "", fmt.Errorf("package_manager is only supported for node runtimes")
+	}
+	if rt.DepTool != "" && rt.Type != models.RuntimePython {
+		return service, nil, "", fmt.Errorf("dep_tool is only supported for python runtimes")
+	}
+	if err := models.ValidateToolchainVersion(rt.Version); err != nil {
+		return service, nil, "", err
+	}
+	switch rt.Type {
+	case models.RuntimeGo:
+		dockerfile = templates.GoDockerfile(rt.Framework, rt.Version)
+		envs = []models.EnvVar{
+			{Key: "GO_ENV", Value: "development", Description: "Go environment"},
+			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+		}
+
+	case models.RuntimeNode:
+		if err := models.ValidatePackageManager(rt.PackageManager); err != nil {
+			return service, nil, "", err
+		}
+		dockerfile = templates.NodeDockerfile(rt.Framework, rt.Version, rt.PackageManager)
+		if rt.Framework == "nextjs" {
+			// The shared .env sets NODE_ENV=development; the built image
+			// serves production output, and the bind mount must not hide
+			// the image's dependencies or build output
+			if service.Environment == nil {
+				service.Environment = make(map[string]string)
+			}
+			if _, ok := service.Environment["NODE_ENV"]; !ok {
+				service.Environment["NODE_ENV"] = "production"
+			}
+			service.Volumes = append(service.Volumes, "/app/node_modules", "/app/.next")
+		}
+		if rt.Framework == "nestjs" {
+			// dist/ is compiled into the image; the bind mount must not
+			// hide it or the production dependencies
+			service.Volumes = append(service.Volumes, "/app/node_modules", "/app/dist")
+		}
+		envs = []models.EnvVar{
+			{Key: "NODE_ENV", Value: "development", Description: "Node environment"},
+			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+		}
+
+	case models.RuntimePython:
+		if err := models.ValidateDepTool(rt.DepTool); err != nil {
+			return service, nil, "", err
+		}
+		dockerfile = templates.PythonDockerfile(rt.Framework, rt.Version, rt.DepTool)
+		envs = []models.EnvVar{
+			{Key: "PYTHON_ENV", Value: "development", Description: "Python environment"},
+			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+		}
+
+	case models.RuntimeJava:
+		dockerfile = templates.JavaDockerfile(rt.Framework, rt.Version)
+		envs = []models.EnvVar{
+			{Key: "JAVA_ENV", Value: "development", Description: "Java environment"},
+			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+		}
+
+	case models.RuntimeRust:
+		dockerfile = templates.RustDockerfile(rt.Framework, rt.Version)
+		envs = []models.EnvVar{
+			{Key: "RUST_ENV", Value: "development", Description: "Rust environment"},
+			{Key: "PORT", Value: fmt.Sprintf("%d", rt.InternalPort), Description: "Application port"},
+		}
+
+	case models.RuntimeCSharp:
+		dockerfile = templates.CSharpDockerfile(rt.Framework, rt.Version)
+		envs = []models.EnvVar{
+			{Key: "ASPNETCORE_ENVIRONMENT", Value: "Development", Description: ".NET environment"},
+			{Key: "ASPNETCORE_URLS", Value: fmt.Sprintf("http://+:%d", rt.InternalPort), Description: "ASP.NET Core URLs"},
+		}
+	}
+
+	if rt.HealthCheck != nil {
+		service.HealthCheck = runtimeHealthCheck(rt)
+	}
+	if rt.DebugPort != 0 {
+		var err error
+		if dockerfile, err = enableDebugger(rt, &service, dockerfile); err != nil {
+			return service, nil, "", err
+		}
+	}
+	if err := models.ValidateMemSwappiness(rt.MemSwappiness); err != nil {
+		return service, nil, "", fmt.Errorf("%s: %w", rt.Name, err)
+	}
+	service.MemSwappiness = rt.MemSwappiness
+	service.OOMKillDisable = rt.OOMKillDisable
+	if g.opts.WatchSync {
+		g.applyWatch(rt, &service)
+	}
+	if len(g.opts.Labels) > 0 {
+		service.Labels = g.opts.Labels
+		service.Build.Labels = g.opts.Labels
+	}
+
+	return service, envs, dockerfile, nil
+}
+
+// enableDebugger publishes a runtime's debug port and starts the app with
+// its debugger listening there, returning the Dockerfile to use. A custom
+// Dockerfile is left to the user, so only the port (and for Java, which
+// needs no command change, JAVA_TOOL_OPTIONS) is set.
+func enableDebugger(rt models.Runtime, service *models.ComposeService, dockerfile string) (string, error) {
+	port := models.GetRuntimeInfo(rt.Type).DebugPort
+	switch {
+	case port == 0:
+		return dockerfile, fmt.Errorf("debug_port is not supported for %s runtimes (use go, node, python or java)", rt.Type)
+	case rt.DebugPort < 0 || rt.DebugPort > 65535:
+		return dockerfile, fmt.Errorf("debug_port %d is not a valid port", rt.DebugPort)
+	case rt.PortMode == models.PortModeRange || rt.Replicas > 1:
+		return dockerfile, fmt.Errorf("debug_port cannot be combined with scaled replicas, which would publish it twice")
+	}
+	service.Ports = append(service.Ports, fmt.Sprintf("%d:%d", rt.DebugPort, port))
+
+	if rt.Type == models.RuntimeJava {
+		if service.Environment == nil {
+			service.Environment = make(map[string]string)
+		}
+		service.Environment["JAVA_TOOL_OPTIONS"] = templates.JavaDebugOptions(port)
+	}
+	if rt.Dockerfile != "" && rt.Dockerfile != "Dockerfile" {
+		return dockerfile, nil
+	}
+	switch rt.Type {
+	case models.RuntimeGo:
+		dockerfile = templates.GoDebugDockerfile(rt.Framework, rt.Version, port)
+		// Delve traces the server process
+		service.CapAdd = append(service.CapAdd, "SYS_PTRACE")
+	case models.RuntimeNode:
+		service.Command = templates.NodeDebugCommand(rt.Framework, port)
+	case models.RuntimePython:
+		dockerfile = templates.AddDebugpy(dockerfile)
+		service.Command = templates.PythonDebugCommand(rt.Framework, port)
+	}
+	return dockerfile, nil
+}
+
+// dependsOn returns a runtime's depends_on, waiting for dependencies with
+// a healthcheck to become healthy and for the others to start. Datastores
+// are generated before runtimes; other runtimes only have a healthcheck
+// when their health_check is set.
+func (g *Generator) dependsOn(services []string) models.ComposeDependsOn {
+	deps := models.DependsOnStarted(services...)
+	for _, name := range services {
+		healthy := g.compose.Services[name].HealthCheck != nil
+		for _, rt := range g.project.Runtimes {
+			if rt.Name == name {
+				healthy = rt.HealthCheck != nil
+			}
+		}
+		if healthy {
+			deps[name] = models.ComposeDependency{Condition: models.ConditionHealthy}
+		}
+	}
+	return deps
+}
+
+// runtimeHealthCheck builds a runtime's healthcheck from its health_check
+// config: the custom command, or else an HTTP GET of the path on the
+// container port. Python images are slim and lack wget, so they probe
+// with urllib instead.
+func runtimeHealthCheck(rt models.Runtime) *models.ComposeHealth {
+	hc := rt.HealthCheck
+	interval := hc.Interval
+	if interval == "" {
+		interval = "10s"
+	}
+
+	var test []string
+	if hc.Cmd != "" {
+		test = []string{"CMD-SHELL", hc.Cmd}
+	} else {
+		path := hc.Path
+		if path == "" {
+			path = models.DefaultHealthCheckPath
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		url := fmt.Sprintf("http://localhost:%d%s", rt.InternalPort, path)
+		if rt.Type == models.RuntimePython {
+			test = []string{"CMD", "python", "-c", fmt.Sprintf("import urllib.request; urllib.request.urlopen(%q, timeout=5)", url)}
+		} else {
+			test = []string{"CMD", "wget", "-q", "-O", "/dev/null", url}
+		}
+	}
+
+	return &models.ComposeHealth{
+		Test:        test,
+		Interval:    interval,
+		Timeout:     "5s",
+		Retries:     5,
+		StartPeriod: "30s",
+	}
+}
+
+// dependencyFiles lists the files whose changes need an image rebuild
+// for interpreted runtimes, relative to the build context
+var dependencyFiles = map[models.RuntimeType][]string{
+	models.RuntimeNode:   {"package.json", "package-lock.json"},
+	models.RuntimePython: {"requirements.txt"},
+}
+
+// applyWatch replaces the source bind mount with develop.watch rules.
+// Interpreted runtimes sync sources into /app and rebuild when dependency
+// files change; compiled runtimes rebuild on any source change since the
+// image holds a built binary.
+func (g *Generator) applyWatch(rt models.Runtime, service *models.ComposeService) {
+	source := bindSource(rt.BuildContext)
+	var volumes []string
+	for _, v := range service.Volumes {
+		if v != source+":/app" {
+			volumes = append(volumes, v)
+		}
+	}
+	service.Volumes = volumes
+
+	deps, interpreted := dependencyFiles[rt.Type]
+	if !interpreted {
+		service.Develop = &models.ComposeDevelop{Watch: []models.ComposeWatch{
+			{Action: "rebuild", Path: source},
+		}}
+		return
+	}
+
+	sync := models.ComposeWatch{Action: "sync", Path: source, Target: "/app", Ignore: deps}
+	if rt.Type == models.RuntimeNode {
+		sync.Ignore = append(sync.Ignore, "node_modules/")
+	}
+	watch := []models.ComposeWatch{sync}
+	for _, file := range deps {
+		watch = append(watch, models.ComposeWatch{Action: "rebuild", Path: source + "/" + file})
+	}
+	service.Develop = &models.ComposeDevelop{Watch: watch}
+}
+
+func (g *Generator) buildOutput() (*GeneratedOutput, error) {
+	if g.opts.DatastoreOnly || g.opts.RuntimeOnly {
+		g.restrictServices()
+	}
+
+	output := &GeneratedOutput{
+		Dockerfiles: g.dockerfiles,
+		ConfigFiles: g.configFiles,
+		EnvVars:     g.envVars,
+		indent:      g.indent(),
+
+		ComposeFileName: g.composeFileName(),
+	}
+
+	if g.opts.Minimal {
+		g.minimize()
+	}
+	output.ServiceImages = make(map[string]string, len(g.compose.Services))
+	for name, service := range g.compose.Services {
+		output.ServiceImages[name] = service.Image
+	}
+	if prefix := g.envPrefix(); prefix != "" {
+		g.applyEnvPrefix(prefix)
+		output.EnvVars = g.envVars
+	}
+	if g.opts.InlineEnv {
+		g.inlineEnv()
+		for _, rt := range g.project.Runtimes {
+			g.explain(rt.Name, "environment", "--inline-env", "generated .env values written inline, secrets as ${VAR}")
+		}
+	}
+	if g.opts.BaseCompose != "" {
+		output.BaseComposePath = g.opts.BaseCompose
+		base, err := g.extractBaseServices()
+		if err != nil {
+			return nil, err
+		}
+		output.BaseCompose = base
+		for _, ds := range g.project.Datastores {
+			if !ds.HasService() {
+				continue
+			}
+			if service := g.compose.Services[ds.Name]; service.Extends != nil {
+				g.explain(ds.Name, "extends", "--base-compose", "settings shared with "+service.Extends.Service+" inherited from "+g.opts.BaseCompose)
+			} else {
+				g.explain(ds.Name, "extends", "--base-compose", "not extended: "+g.opts.BaseCompose+" sets a command or healthcheck this service lacks")
+			}
+		}
+	}
+
+	// Generate docker-compose.yml
+	if g.opts.Split {
+		if err := g.buildSplitCompose(output); err != nil {
+			return nil, err
+		}
+	} else {
+		composeYAML, err := marshalYAML(g.compose, output.indent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal compose file: %w", err)
+		}
+		output.ComposeYAML = addComposeHeader(string(composeYAML))
+	}
+
+	// Generate .env
+	var envBuilder strings.Builder
+	envBuilder.WriteString("# Generated by stackgen - For local development only\n")
+	envBuilder.WriteString("# WARNING: Do not commit this file to version control\n\n")
+	for _, env := range g.envVars {
+		if env.Description != "" {
+			envBuilder.WriteString(fmt.Sprintf("# %s\n", env.Description))
+		}
+		envBuilder.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+	}
+	output.EnvFile = envBuilder.String()
+
+	// Generate .env.example
+	var envExampleBuilder strings.Builder
+	envExampleBuilder.WriteString("# Environment variables for stackgen\n")
+	envExampleBuilder.WriteString("# Copy this file to .env and fill in the values\n\n")
+	for _, env := range g.envVars {
+		if env.Description != "" {
+			envExampleBuilder.WriteString(fmt.Sprintf("# %s\n", env.Description))
+		}
+		if env.Secret {
+			envExampleBuilder.WriteString(fmt.Sprintf("%s=<your-%s>\n", env.Key, strings.ToLower(strings.ReplaceAll(env.Key, "_", "-"))))
+		} else {
+			envExampleBuilder.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+		}
+	}
+	output.EnvExampleFile = envExampleBuilder.String()
+
+	// Generate .gitignore
+	output.GitIgnore = templates.GitIgnore()
+
+	switch g.opts.CI {
+	case "":
+	case "github":
+		output.CIFiles = map[string]string{g.ciPath(GitHubWorkflowFile): g.githubWorkflow()}
+	default:
+		return nil, fmt.Errorf("unsupported CI provider %q (supported: github)", g.opts.CI)
+	}
+
+	g.stampVersion(output)
+	output.Provenance = g.resolveProvenance()
+
+	return output, nil
+}
+
+// GitHubWorkflowFile is where the GitHub Actions workflow is written,
+// relative to the repository root
+const GitHubWorkflowFile = ".github/workflows/stack-test.yml"
+
+// ciPath returns the path of a repository-root file relative to the
+// output directory
+func (g *Generator) ciPath(name string) string {
+	workDir := filepath.Clean(g.opts.CIWorkDir)
+	if workDir == "." {
+		return name
+	}
+	up := strings.Repeat("../", len(strings.Split(filepath.ToSlash(workDir), "/")))
+	return up + name
+}
+
+// githubWorkflow returns a workflow that starts the stack, waits for
+// healthchecks, runs each runtime's test container and tears down. Test
+// services follow the layout of 'stackgen test --all'.
+func (g *Generator) githubWorkflow() string {
+	var b strings.Builder
+	b.WriteString(`# Stack tests - Generated by stackgen
+#
+# Starts the generated stack, runs the test containers from
+# 'stackgen test --all' and tears everything down.
+#
+# .env is not committed. Store your .env contents in a STACK_ENV secret;
+# without it .env.example is used, whose secret values are placeholders.
+
+name: Stack tests
+
+on:
+  push:
+  pull_request:
+
+jobs:
+  stack-test:
+    runs-on: ubuntu-latest
+`)
+	if workDir := filepath.Clean(g.opts.CIWorkDir); workDir != "." {
+		fmt.Fprintf(&b, `    defaults:
+      run:
+        working-directory: %s
+`, filepath.ToSlash(workDir))
+	}
+	fmt.Fprintf(&b, `    steps:
+      - uses: actions/checkout@v4
+
+      - name: Create .env
+        env:
+          STACK_ENV: ${{ secrets.STACK_ENV }}
+        run: |
+          if [ -n "$STACK_ENV" ]; then
+            printf '%%s\n' "$STACK_ENV" > .env
+          else
+            cp .env.example .env
+          fi
+
+      - name: Start stack
+        run: docker compose -f %s up -d --build --wait
+`, g.composeFileName())
+
+	// Steps run from the output directory, which build contexts and the
+	// test containers 'stackgen test --all' writes into them are relative to
+	for _, rt := range g.project.Runtimes {
+		dir := rt.BuildContext
+		if dir == "" {
+			dir = rt.Name
+		}
+		testCompose := filepath.ToSlash(filepath.Join(dir, "test-container", "docker-compose.test.yml"))
+		// .env.test is gitignored like .env, so CI derives it from .env;
+		// the CI stack is thrown away, so it needs no separate database
+		testEnv := filepath.ToSlash(filepath.Join(dir, "test-container", ".env.test"))
+		fmt.Fprintf(&b, `
+      - name: Test %[1]s
+        run: |
+          if [ ! -f %[2]s ]; then
+            echo "No test container for %[1]s; run 'stackgen test --all'"
+            exit 1
+          fi
+          if [ ! -f %[4]s ]; then
+            cp .env %[4]s
+          fi
+          docker compose -f %[3]s -f %[2]s run --rm %[1]s-test
+`, rt.Name, testCompose, g.composeFileName(), testEnv)
+	}
+
+	fmt.Fprintf(&b, `
+      - name: Show logs
+        if: failure()
+        run: docker compose -f %[1]s logs
+
+      - name: Tear down
+        if: always()
+        run: docker compose -f %[1]s down -v
+`, g.composeFileName())
+	return b.String()
+}
+
+// versionStampPrefix marks the line recording which stackgen version
+// produced a file
+const versionStampPrefix = "# stackgen-version: "
+
+// stampVersion prefixes every generated file with the version stamp
+func (g *Generator) stampVersion(out *GeneratedOutput) {
+	if g.opts.Version == "" {
+		return
+	}
+	stamp := versionStampPrefix + g.opts.Version + "\n"
+
+	out.ComposeYAML = stamp + out.ComposeYAML
+	out.EnvFile = stamp + out.EnvFile
+	out.EnvExampleFile = stamp + out.EnvExampleFile
+	out.GitIgnore = stamp + out.GitIgnore
+	for name, content := range out.ComposeFiles {
+		out.ComposeFiles[name] = stamp + content
+	}
+	for name, content := range out.Dockerfiles {
+		out.Dockerfiles[name] = stamp + content
+	}
+	for name, content := range out.ConfigFiles {
+		out.ConfigFiles[name] = stamp + content
+	}
+	for name, content := range out.CIFiles {
+		out.CIFiles[name] = stamp + content
+	}
+}
+
+// skipDirs are never searched for stale Dockerfiles
+var skipDirs = map[string]bool{"node_modules": true, "vendor": true, "target": true, "bin": true, "obj": true}
+
+// StaleDockerfiles lists generated Dockerfiles at <runtime>/Dockerfile in
+// dir, and at <dockerfileDir>/<runtime>/Dockerfile when a Dockerfile dir
+// is given, whose runtime out no longer generates, e.g. after it was
+// removed. Deeper paths are never looked at, so nested projects and
+// Dockerfiles written with a --dockerfile-dir the caller does not know
+// about are left alone, as are directories holding their own
+// stackgen.yaml. Paths are relative to dir.
+func StaleDockerfiles(dir, dockerfileDir string, out *GeneratedOutput) ([]string, error) {
+	live := make(map[string]bool, len(out.Dockerfiles))
+	for name := range out.Dockerfiles {
+		live[filepath.Base(name)] = true
+	}
+
+	parents := []string{"."}
+	if dockerfileDir != "" {
+		parents = append(parents, filepath.Clean(dockerfileDir))
+	}
+	var stale []string
+	for _, parent := range parents {
+		entries, err := os.ReadDir(filepath.Join(dir, parent))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() || live[name] || strings.HasPrefix(name, ".") || skipDirs[name] {
+				continue
+			}
+			sub := filepath.Join(dir, parent, name)
+			if _, err := os.Stat(filepath.Join(sub, "stackgen.yaml")); err == nil {
+				continue
+			}
+			if generatedFile(filepath.Join(sub, "Dockerfile")) {
+				stale = append(stale, filepath.Join(parent, name, "Dockerfile"))
+			}
+		}
+	}
+	return stale, nil
+}
+
+// generatedMarker is in the header line of every generated Dockerfile and
+// config file
+const generatedMarker = "Generated by stackgen"
+
+// IsGenerated reports whether content is a file stackgen generated: it
+// carries a version stamp or the generated header
+func IsGenerated(content string) bool {
+	if ParseVersionStamp(content) != "" {
+		return true
+	}
+	lines := strings.SplitN(content, "\n", 3)
+	for _, line := range lines[:min(len(lines), 2)] {
+		if strings.HasPrefix(line, "#") && strings.Contains(line, generatedMarker) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedFile reports whether the file at path was generated by
+// stackgen, so that regenerating may overwrite it
+func generatedFile(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && IsGenerated(string(data))
+}
+
+// ParseVersionStamp returns the stackgen version recorded in a generated
+// file, or "" if the file carries no stamp
+func ParseVersionStamp(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, versionStampPrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(line, versionStampPrefix))
+		}
+	}
+	return ""
+}
+
+// Split compose file names
+const (
+	DatastoresComposeFile = "docker-compose.datastores.yml"
+	RuntimesComposeFile   = "docker-compose.runtimes.yml"
+)
+
+// buildSplitCompose writes datastore and runtime services to their own
+// compose files, each carrying the shared network and volume definitions,
+// and makes docker-compose.yml include them (requires Compose v2.20+)
+func (g *Generator) buildSplitCompose(output *GeneratedOutput) error {
+	isRuntime := g.runtimeServices()
+
+	datastores := &models.ComposeFile{
+		Services: make(map[string]models.ComposeService),
+		Volumes:  g.compose.Volumes,
+		Networks: g.compose.Networks,
+	}
+	runtimes := &models.ComposeFile{
+		Services: make(map[string]models.ComposeService),
+		Networks: g.compose.Networks,
+	}
+	for name, service := range g.compose.Services {
+		if isRuntime[name] {
+			runtimes.Services[name] = service
+		} else {
+			datastores.Services[name] = service
+		}
+	}
+
+	root := &models.ComposeFile{}
+	output.ComposeFiles = make(map[string]string)
+	for _, part := range []struct {
+		name string
+		file *models.ComposeFile
+	}{
+		{DatastoresComposeFile, datastores},
+		{RuntimesComposeFile, runtimes},
+	} {
+		if len(part.file.Services) == 0 {
+			continue
+		}
+		data, err := marshalYAML(part.file, output.indent)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", part.name, err)
+		}
+		output.ComposeFiles[part.name] = addComposeHeader(string(data))
+		root.Include = append(root.Include, part.name)
+	}
+
+	data, err := marshalYAML(root, output.indent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose file: %w", err)
+	}
+	output.ComposeYAML = addComposeHeader(string(data))
+	return nil
+}
+
+// runtimeServices returns the names of the services that belong to the
+// runtime half of the stack, including their proxies
+func (g *Generator) runtimeServices() map[string]bool {
+	isRuntime := make(map[string]bool)
+	for _, rt := range g.project.Runtimes {
+		isRuntime[rt.Name] = true
+		if rt.Replicas > 1 {
+			isRuntime[proxyName(rt.Name)] = true
+		}
+	}
+	return isRuntime
+}
+
+// restrictServices drops the runtime or datastore half of the stack for
+// Options.DatastoreOnly and RuntimeOnly, with the files, dependencies and
+// volumes only that half used. Env vars are kept, so runtimes can still be
+// pointed at datastores running elsewhere.
+func (g *Generator) restrictServices() {
+	isRuntime := g.runtimeServices()
+	for name := range g.compose.Services {
+		if isRuntime[name] != g.opts.DatastoreOnly {
+			continue
+		}
+		delete(g.compose.Services, name)
+		for path := range g.configFiles {
+			if strings.HasPrefix(path, name+"/") {
+				delete(g.configFiles, path)
+			}
+		}
+	}
+	if g.opts.DatastoreOnly {
+		g.dockerfiles = make(map[string]string)
+	}
+
+	used := make(map[string]bool)
+	for name, service := range g.compose.Services {
+		for dep := range service.DependsOn {
+			if _, ok := g.compose.Services[dep]; !ok {
+				delete(service.DependsOn, dep)
+			}
+		}
+		if len(service.DependsOn) == 0 {
+			service.DependsOn = nil
+		}
+		g.compose.Services[name] = service
+		for _, volume := range service.Volumes {
+			source, _, _ := strings.Cut(volume, ":")
+			used[source] = true
+		}
+	}
+	for volume := range g.compose.Volumes {
+		if !used[volume] {
+			delete(g.compose.Volumes, volume)
+		}
+	}
+}
+
+// networkSource names where the project network comes from, for explain
+func (g *Generator) networkSource() string {
+	switch {
+	case g.opts.ExternalNetwork != "":
+		return "--external-network"
+	case g.project.ExternalNetwork != "":
+		return "external_network"
+	}
+	return "default"
+}
+
+// externalNetwork returns the pre-existing network services join, if any,
+// letting the option override the project setting
+func (g *Generator) externalNetwork() string {
+	if g.opts.ExternalNetwork != "" {
+		return g.opts.ExternalNetwork
+	}
+	return g.project.ExternalNetwork
+}
+
+// pullPolicy returns the datastores' pull policy, letting the option
+// override the project setting
+func (g *Generator) pullPolicy() string {
+	if g.opts.PullPolicy != "" {
+		return g.opts.PullPolicy
+	}
+	return g.project.PullPolicy
+}
+
+// applyPullPolicy sets pull_policy on datastore services and their
+// replicas, which run pulled images; runtimes are built locally
+func (g *Generator) applyPullPolicy(policy string) {
+	source := "pull_policy"
+	if g.opts.PullPolicy != "" {
+		source = "--pull-policy"
+	}
+	for _, ds := range g.project.Datastores {
+		names := []string{ds.Name}
+		for i := 1; i <= ds.Replicas; i++ {
+			names = append(names, fmt.Sprintf("%s-replica-%d", ds.Name, i))
+		}
+		for _, name := range names {
+			service, ok := g.compose.Services[name]
+			if !ok {
+				continue
+			}
+			service.PullPolicy = policy
+			g.compose.Services[name] = service
+			g.explain(name, "pull_policy", source, "when compose pulls the datastore image")
+		}
+	}
+}
+
+// envPrefix returns the env var prefix, letting the option override the
+// project setting
+func (g *Generator) envPrefix() string {
+	if g.opts.EnvPrefix != "" {
+		return g.opts.EnvPrefix
+	}
+	return g.project.EnvPrefix
+}
+
+// Resolve returns a copy of the project as Generate sees it: option
+// overrides applied, friendly versions resolved to tags and defaults such
+// as the output dir and shm_size filled in
+func (g *Generator) Resolve() (*models.Project, error) {
+	if errs := models.ValidateNames(g.project); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	data, err := yaml.Marshal(g.project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy project: %w", err)
+	}
+	resolved := &models.Project{}
+	if err := yaml.Unmarshal(data, resolved); err != nil {
+		return nil, fmt.Errorf("failed to copy project: %w", err)
+	}
+
+	resolved.EnvPrefix = g.envPrefix()
+	resolved.ExternalNetwork = g.externalNetwork()
+	resolved.PullPolicy = g.pullPolicy()
+	if resolved.OutputDir == "" {
+		resolved.OutputDir = "."
+	}
+	for i := range resolved.Datastores {
+		ds := &resolved.Datastores[i]
+		if !ds.HasService() {
+			continue
+		}
+		if ds.Version != "" {
+			tag, err := models.ResolveVersion(ds.Type, ds.Version)
+			if err != nil {
+				return nil, err
+			}
+			ds.Tag = tag
+		}
+		if shmSize, err := datastoreShmSize(*ds); err == nil {
+			ds.ShmSize = shmSize
+		}
+	}
+	return resolved, nil
+}
+
+// envRefPattern matches ${VAR} interpolations, including ${VAR:-default}
+// and ${VAR?error} forms
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)`)
+
+// fixedEnvKeys are the generated .env keys that runtimes, frameworks and
+// SDKs read by fixed name through env_file, e.g. ASP.NET Core only listens
+// on ASPNETCORE_URLS, so the env prefix must not rename them
+var fixedEnvKeys = map[string]bool{
+	"PORT":                        true,
+	"GO_ENV":                      true,
+	"NODE_ENV":                    true,
+	"PYTHON_ENV":                  true,
+	"JAVA_ENV":                    true,
+	"RUST_ENV":                    true,
+	"ASPNETCORE_ENVIRONMENT":      true,
+	"ASPNETCORE_URLS":             true,
+	"OTEL_EXPORTER_OTLP_ENDPOINT": true,
+	"SENTRY_DSN":                  true,
+}
+
+// applyEnvPrefix prefixes every generated env var key, except the
+// fixedEnvKeys, and rewrites the compose interpolations that reference
+// them. Container-side variable names are left alone since images read
+// them by fixed name.
+func (g *Generator) applyEnvPrefix(prefix string) {
+	generated := make(map[string]bool, len(g.envVars))
+	for i, env := range g.envVars {
+		if fixedEnvKeys[env.Key] {
+			continue
+		}
+		generated[env.Key] = true
+		g.envVars[i].Key = prefix + env.Key
+	}
+
+	rewrite := func(value string) string {
+		return envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+			key := ref[2:]
+			if !generated[key] {
+				return ref
+			}
+			return "${" + prefix + key
+		})
+	}
+
+	for name, service := range g.compose.Services {
+		if len(service.Environment) > 0 {
+			env := make(map[string]string, len(service.Environment))
+			for k, v := range service.Environment {
+				env[k] = rewrite(v)
+			}
+			service.Environment = env
+		}
+		service.Command = rewrite(service.Command)
+		if service.HealthCheck != nil {
+			health := *service.HealthCheck
+			health.Test = make([]string, len(service.HealthCheck.Test))
+			for i, arg := range service.HealthCheck.Test {
+				health.Test[i] = rewrite(arg)
+			}
+			service.HealthCheck = &health
+		}
+		g.compose.Services[name] = service
+	}
+}
+
+// runtimeEnvFiles returns the env files a runtime loads, .env unless
+// env_files lists others. stackgen writes .env itself; the other files
+// are the user's and are marked optional, so a missing one (e.g. an
+// uncommitted .env.local) doesn't stop the stack from starting.
+func runtimeEnvFiles(rt models.Runtime) []models.ComposeEnvFile {
+	if len(rt.EnvFiles) == 0 {
+		return []models.ComposeEnvFile{{Path: ".env"}}
+	}
+	files := make([]models.ComposeEnvFile, 0, len(rt.EnvFiles))
+	for _, path := range rt.EnvFiles {
+		files = append(files, models.ComposeEnvFile{Path: path, Optional: path != ".env"})
+	}
+	return files
+}
+
+// loadsDotEnv reports whether env_file lists the generated .env
+func loadsDotEnv(f models.ComposeEnvFile) bool {
+	return f.Path == ".env"
+}
+
+// inlineEnv replaces the .env entry of env_file with an environment map on
+// the services that load it. Non-secret values are written literally;
+// secrets stay ${VAR} references resolved from the shell (or .env, which
+// compose reads for interpolation). Values already set on a service win, as
+// they would over env_file, and a key repeated in .env keeps its last
+// value. Other env files stay in env_file.
+func (g *Generator) inlineEnv() {
+	for name, service := range g.compose.Services {
+		if !slices.ContainsFunc(service.EnvFile, loadsDotEnv) {
+			continue
+		}
+		env := make(map[string]string, len(g.envVars)+len(service.Environment))
+		for _, v := range g.envVars {
+			if v.Secret {
+				env[v.Key] = "${" + v.Key + "}"
+			} else {
+				env[v.Key] = v.Value
+			}
+		}
+		for k, v := range service.Environment {
+			env[k] = v
+		}
+		service.Environment = env
+		service.EnvFile = slices.DeleteFunc(service.EnvFile, loadsDotEnv)
+		if len(service.EnvFile) == 0 {
+			service.EnvFile = nil
+		}
+		g.compose.Services[name] = service
+	}
+}
+
+// Provenance records which config field or default produced a key of a
+// generated compose service
+type Provenance struct {
+	Service string      `json:"service"`
+	Key     string      `json:"key"`
+	Value   interface{} `json:"value"`
+	Source  string      `json:"source"` // config field, flag, or "default"
+	Reason  string      `json:"reason"`
+}
+
+// explain records the source of a service key; values are filled in once
+// the compose file is final
+func (g *Generator) explain(service, key, source, reason string) {
+	g.provenance = append(g.provenance, Provenance{Service: service, Key: key, Source: source, Reason: reason})
+}
+
+func (g *Generator) explainDatastore(ds models.Datastore) {
+	field := func(name string) string { return fmt.Sprintf("datastores[%s].%s", ds.Name, name) }
+	repo, _ := DatastoreImageRef(ds)
+
+	if ds.Digest != "" {
+		g.explain(ds.Name, "image", field("digest"), "pinned digest of "+repo+", overrides the tag")
+	} else if ds.Version != "" {
+		g.explain(ds.Name, "image", field("version"), "repository "+repo+" with the tag stackgen resolves "+ds.Version+" to")
+	} else {
+		g.explain(ds.Name, "image", field("tag"), "repository "+repo+" for type "+string(ds.Type)+"; the tag defaults to stackgen's pinned tag when added")
+	}
+	g.explain(ds.Name, "container_name", "name", "<project>-<service>")
+	switch {
+	case ds.InternalNetwork:
+		g.explain(ds.Name, "expose", field("internal_network"), "internal network datastores are never published on the host")
+	case !ds.IsExposed():
+		g.explain(ds.Name, "expose", field("expose"), "expose: false keeps ports on the compose network")
+	case ds.Port == 0:
+		g.explain(ds.Name, "ports", field("port"), "port 0 lets Docker pick the host port")
+	default:
+		g.explain(ds.Name, "ports", field("port"), "host port; the container port is fixed by the datastore type")
+	}
+	if len(ds.PortRanges) > 0 {
+		g.explain(ds.Name, "ports", field("port_ranges"), "contiguous ranges published as host-host:container-container")
+	}
+	if ds.Persistence == models.PersistenceNone {
+		g.explain(ds.Name, "tmpfs", field("persistence"), "persistence none keeps /data in memory, without a volume")
+	} else {
+		g.explain(ds.Name, "volumes", "default", "named volume "+ds.Name+"-data")
+	}
+	if ds.Persistence != "" {
+		if ds.Type == models.DatastoreRedisStack {
+			g.explain(ds.Name, "environment", field("persistence"), "REDIS_ARGS includes the generated "+ds.Name+"/persistence.conf")
+			g.explain(ds.Name, "volumes", field("persistence"), "snapshot and append-only file settings for "+ds.Persistence+" in "+ds.Name+"/persistence.conf")
+		} else {
+			g.explain(ds.Name, "command", field("persistence"), "redis-server snapshot and append-only file settings for "+ds.Persistence)
+		}
+	}
+	if ds.Charset != "" || ds.Collation != "" {
+		g.explain(ds.Name, "command", field("charset"), "mysqld --character-set-server and --collation-server")
+	}
+	if ds.NoPassword {
+		g.explain(ds.Name, "environment", field("no_password"), "authentication disabled")
+	} else {
+		g.explain(ds.Name, "environment", "default", "generated credentials, interpolated from .env")
+	}
+	if len(ds.Databases) > 0 {
+		g.explain(ds.Name, "volumes", field("databases"), "init script creating the extra databases on first start")
+	}
+	if ds.SeedFile != "" {
+		g.explain(ds.Name, "volumes", field("seed_file"), "fixture data loaded last from /docker-entrypoint-initdb.d on first start")
+	}
+	if len(ds.Tuning) > 0 {
+		g.explain(ds.Name, "volumes", field("tuning"), "settings mounted as "+ds.Name+"/postgresql.conf")
+		g.explain(ds.Name, "command", field("tuning"), "loads the mounted postgresql.conf")
+	}
+	g.explain(ds.Name, "healthcheck", "default", "stackgen's readiness check for "+string(ds.Type))
+	g.explain(ds.Name, "read_only", field("read_only_root_fs"), "read-only root filesystem")
+	if ds.ShmSize != "" {
+		g.explain(ds.Name, "shm_size", field("shm_size"), "/dev/shm size")
+	} else {
+		g.explain(ds.Name, "shm_size", "default", "/dev/shm larger than Docker's 64MB for "+string(ds.Type))
+	}
+	g.explain(ds.Name, "cap_add", field("cap_add"), "added Linux capabilities")
+	g.explain(ds.Name, "cap_drop", field("cap_drop"), "dropped Linux capabilities")
+	g.explain(ds.Name, "security_opt", field("security_opt"), "security options")
+	if len(ds.Ulimits) > 0 {
+		g.explain(ds.Name, "ulimits", field("ulimits"), "overrides of the limits "+string(ds.Type)+" requires")
+	} else {
+		g.explain(ds.Name, "ulimits", "default", "limits "+string(ds.Type)+" requires to start reliably")
+	}
+	g.explain(ds.Name, "tmpfs", field("read_only_root_fs"), "writable paths outside the data volume under read_only")
+	g.explain(ds.Name, "mem_swappiness", field("mem_swappiness"), "how readily the kernel swaps the container's memory")
+	g.explain(ds.Name, "oom_kill_disable", field("oom_kill_disable"), "whether the OOM killer may stop the container")
+	if ds.InternalNetwork {
+		g.explain(ds.Name, "networks", field("internal_network"), "internal: true network shared with runtimes only")
+	} else {
+		g.explain(ds.Name, "networks", g.networkSource(), "project network")
+	}
+	g.explain(ds.Name, "restart", "default", "unless-stopped")
+	g.explain(ds.Name, "stop_grace_period", field("stop_grace_period"), "")
+	g.explain(ds.Name, "init", field("init"), "")
+	for i := 1; i <= ds.Replicas; i++ {
+		g.explain(fmt.Sprintf("%s-replica-%d", ds.Name, i), "image", field("replicas"), "streaming replica of "+ds.Name)
+	}
+}
+
+func (g *Generator) explainRuntime(rt models.Runtime) {
+	field := func(name string) string { return fmt.Sprintf("runtimes[%s].%s", rt.Name, name) }
+
+	g.explain(rt.Name, "build", field("build_context"), "sources the image is built from")
+	switch {
+	case rt.Dockerfile != "" && rt.Dockerfile != "Dockerfile":
+		g.explain(rt.Name, "build", field("dockerfile"), "user-supplied Dockerfile, not generated")
+	case g.opts.DockerfileDir != "":
+		g.explain(rt.Name, "build", "--dockerfile-dir", "generated Dockerfile kept outside the build context")
+	default:
+		g.explain(rt.Name, "build", "default", "generated "+string(rt.Type)+" Dockerfile for "+rt.Framework)
+	}
+	if rt.PackageManager != "" {
+		g.explain(rt.Name, "build", field("package_manager"), "dependencies installed and scripts run with "+rt.PackageManager)
+	}
+	if rt.DepTool != "" {
+		g.explain(rt.Name, "build", field("dep_tool"), "dependencies installed with "+rt.DepTool)
+	}
+	g.explain(rt.Name, "container_name", "name", "<project>-<service>; dropped in range port mode")
+	if rt.Replicas > 1 {
+		g.explain(rt.Name, "deploy", field("replicas"), "scaled behind "+proxyName(rt.Name)+", which publishes the port")
+	}
+	switch rt.PortMode {
+	case "":
+		g.explain(rt.Name, "ports", field("port"), "published on the host")
+	case models.PortModeNone:
+		g.explain(rt.Name, "expose", field("port_mode"), "port mode none keeps the container port on the compose network")
+	default:
+		g.explain(rt.Name, "ports", field("port_mode"), "port mode "+rt.PortMode+" starting at runtimes["+rt.Name+"].port")
+	}
+	if len(rt.PortRanges) > 0 {
+		g.explain(rt.Name, "ports", field("port_ranges"), "contiguous ranges published as host-host:container-container")
+	}
+	if rt.DebugPort != 0 {
+		g.explain(rt.Name, "ports", field("debug_port"), "debugger port published; the app starts with its debugger enabled")
+	}
+	if g.opts.WatchSync {
+		g.explain(rt.Name, "develop", "--watch-sync", "sync or rebuild rules for docker compose watch")
+	} else {
+		g.explain(rt.Name, "volumes", "default", "sources bind-mounted at /app")
+	}
+	if g.usesSQLite() {
+		g.explain(rt.Name, "volumes", "datastores (sqlite)", "SQLite data directory mounted at /data")
+	}
+	if hc := rt.HealthCheck; hc != nil {
+		if hc.Cmd != "" {
+			g.explain(rt.Name, "healthcheck", field("health_check.cmd"), "custom healthcheck command")
+		} else {
+			g.explain(rt.Name, "healthcheck", field("health_check"), "HTTP GET of health_check.path (default "+models.DefaultHealthCheckPath+")")
+		}
+	}
+	g.explain(rt.Name, "mem_swappiness", field("mem_swappiness"), "how readily the kernel swaps the container's memory")
+	g.explain(rt.Name, "oom_kill_disable", field("oom_kill_disable"), "whether the OOM killer may stop the container")
+	if len(rt.EnvFiles) > 0 {
+		g.explain(rt.Name, "env_file", field("env_files"), "loaded in order, later files overriding earlier ones")
+	} else {
+		g.explain(rt.Name, "env_file", "default", "shared generated .env")
+	}
+	g.explain(rt.Name, "environment", field("environment"), "")
+	g.explain(rt.Name, "depends_on", field("depends_on"), "")
+	if g.project.Jaeger {
+		g.explain(rt.Name, "depends_on", "jaeger", "runtimes wait for the tracing backend")
+	}
+	g.explain(rt.Name, "networks", g.networkSource(), "project network")
+	if g.usesInternalNetwork() {
+		g.explain(rt.Name, "networks", "datastores (internal_network)", "also joins the internal network to reach its datastores")
+	}
+	g.explain(rt.Name, "restart", "default", "unless-stopped")
+	g.explain(rt.Name, "labels", "--git-labels", "OCI labels from the git repository, also set on the built image")
+	g.explain(rt.Name, "stop_grace_period", field("stop_grace_period"), "")
+	g.explain(rt.Name, "init", field("init"), "")
+}
+
+// resolveProvenance fills in the final value of each recorded key and
+// drops keys the generated services do not contain
+func (g *Generator) resolveProvenance() []Provenance {
+	values := make(map[string]map[string]interface{}, len(g.compose.Services))
+	for name, service := range g.compose.Services {
+		data, err := yaml.Marshal(service)
+		if err != nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if yaml.Unmarshal(data, &fields) == nil {
+			values[name] = fields
+		}
+	}
+
+	var resolved []Provenance
+	for _, p := range g.provenance {
+		value, ok := values[p.Service][p.Key]
+		if !ok {
+			continue
+		}
+		p.Value = value
+		resolved = append(resolved, p)
+	}
+	sort.SliceStable(resolved, func(i, j int) bool { return resolved[i].Service < resolved[j].Service })
+	return resolved
+}
+
+// extractBaseServices moves the project-independent parts of each
+// datastore service into a <type>-base service and makes the datastore
+// extend it. Project-specific settings (name, ports, volumes, environment,
+// networks) stay in the project's compose file.
+//
+// The base service is the one already in the base file when present, so
+// a field is only dropped when the inherited value equals the generated
+// one; differing values stay on the service as overrides. A datastore
+// does not extend a base that sets a field it leaves empty, since extends
+// cannot unset it.
+func (g *Generator) extractBaseServices() (*models.ComposeFile, error) {
+	var existing struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(g.opts.ExistingBaseCompose, &existing); err != nil {
+		return nil, fmt.Errorf("failed to parse base compose %s: %w", g.opts.BaseCompose, err)
+	}
+	base := &models.ComposeFile{Services: make(map[string]models.ComposeService)}
+	for _, ds := range g.project.Datastores {
+		if !ds.HasService() {
+			continue
+		}
+		service, ok := g.compose.Services[ds.Name]
+		if !ok {
+			continue
+		}
+		baseName := string(ds.Type) + "-base"
+
+		shared, exists := existing.Services[baseName]
+		if !exists {
+			if _, added := base.Services[baseName]; !added {
+				base.Services[baseName] = models.ComposeService{
+					Image:       service.Image,
+					HealthCheck: service.HealthCheck,
+					Restart:     service.Restart,
+					Command:     service.Command,
+				}
+			}
+			var err error
+			if shared, err = yamlFields(base.Services[baseName]); err != nil {
+				return nil, err
+			}
+		}
+		own, err := yamlFields(service)
+		if err != nil {
+			return nil, err
+		}
+
+		inherit := make(map[string]bool)
+		compatible := true
+		for _, key := range []string{"image", "command", "healthcheck", "restart"} {
+			inherited, inBase := shared[key]
+			value, set := own[key]
+			switch {
+			case !inBase:
+			case !set:
+				compatible = false
+			case reflect.DeepEqual(value, inherited):
+				inherit[key] = true
+			}
+		}
+		if !compatible {
+			continue
+		}
+
+		// Keep anything that differs from the shared base on the service
+		if inherit["image"] {
+			service.Image = ""
+		}
+		if inherit["command"] {
+			service.Command = ""
+		}
+		if inherit["healthcheck"] {
+			service.HealthCheck = nil
+		}
+		if inherit["restart"] {
+			service.Restart = ""
+		}
+		service.Extends = &models.ComposeExtends{File: g.opts.BaseCompose, Service: baseName}
+		g.compose.Services[ds.Name] = service
+	}
+	return base, nil
+}
+
+// yamlFields returns the top-level keys of service as they marshal, so
+// generated and hand-written services compare by value
+func yamlFields(service models.ComposeService) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal service: %w", err)
+	}
+	fields := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse service: %w", err)
+	}
+	return fields, nil
+}
+
+// minimize drops the opinionated service fields, leaving image, build,
+// ports, environment and volumes for callers layering their own tooling
+func (g *Generator) minimize() {
+	for name, service := range g.compose.Services {
+		service.ContainerName = ""
+		service.Restart = ""
+		service.HealthCheck = nil
+		// Without healthchecks services can only wait for each other to start
+		for dep := range service.DependsOn {
+			service.DependsOn[dep] = models.ComposeDependency{Condition: models.ConditionStarted}
+		}
+		g.compose.Services[name] = service
+	}
+}
+
+// GeneratedOutput holds all generated files
+type GeneratedOutput struct {
+	ComposeYAML    string
+	EnvFile        string
 	EnvExampleFile string
 	GitIgnore      string
-	Dockerfiles    map[string]string
+	Dockerfiles    map[string]string // keyed by build context directory
+	ConfigFiles    map[string]string // service config files, keyed by path relative to the output dir
+	ComposeFiles   map[string]string // split compose files included from ComposeYAML, keyed by file name
+	EnvVars        []models.EnvVar
+
+	// BaseCompose holds the services extended via Options.BaseCompose.
+	// WriteToDir only adds services missing from an existing base file, so
+	// several projects can share one.
+	BaseCompose     *models.ComposeFile
+	BaseComposePath string
+
+	// CIFiles holds CI pipeline definitions keyed by path
+	CIFiles map[string]string
+
+	// ComposeFileName is the path of ComposeYAML relative to the output
+	// directory
+	ComposeFileName string
+
+	// ServiceImages maps each generated compose service to its image,
+	// empty for services built from a Dockerfile
+	ServiceImages map[string]string
+
+	// Provenance explains where each compose service key came from
+	Provenance []Provenance
+
+	// OnWrite, when set, is called by WriteToDir after each file is
+	// written, with its path relative to the output directory
+	OnWrite func(name string)
+
+	indent int
+	// composeMerged is set by MergeCompose, so WriteToDir reports a merge
+	composeMerged bool
 }
 
-// WriteToDir writes all generated files to the specified directory
-func (out *GeneratedOutput) WriteToDir(dir string) error {
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+// wrote reports a written file to OnWrite
+func (out *GeneratedOutput) wrote(name string) {
+	if out.OnWrite != nil {
+		out.OnWrite(filepath.ToSlash(name))
 	}
+}
+
+// File is one generated file
+type File struct {
+	Path    string // relative to the output directory
+	Content string
+}
 
-	files := map[string]string{
-		"docker-compose.yml": out.ComposeYAML,
-		".env":               out.EnvFile,
-		".env.example":       out.EnvExampleFile,
-		".gitignore":         out.GitIgnore,
+// OutputFiles returns every generated file: the compose files and env
+// files first, then Dockerfiles, config files and CI pipelines, each group
+// sorted by path. The base compose file is left out since it is merged
+// into rather than written.
+func (out *GeneratedOutput) OutputFiles() []File {
+	name := out.ComposeFileName
+	if name == "" {
+		name = DefaultComposeFileName
 	}
+	files := []File{{name, out.ComposeYAML}}
+	files = appendSorted(files, out.ComposeFiles, "")
+	files = append(files,
+		File{".env", out.EnvFile},
+		File{".env.example", out.EnvExampleFile},
+		File{".gitignore", out.GitIgnore},
+	)
+	files = appendSorted(files, out.Dockerfiles, "Dockerfile")
+	// Service config files and CI pipelines
+	files = appendSorted(files, out.ConfigFiles, "")
+	return appendSorted(files, out.CIFiles, "")
+}
 
-	for name, content := range files {
-		path := filepath.Join(dir, name)
-		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-			return fmt.Errorf("failed to write %s: %w", name, err)
+// appendSorted appends a file map in path order, joining suffix to each
+// key when set
+func appendSorted(files []File, contents map[string]string, suffix string) []File {
+	keys := make([]string, 0, len(contents))
+	for key := range contents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := key
+		if suffix != "" {
+			path = filepath.Join(key, suffix)
 		}
+		files = append(files, File{path, contents[key]})
+	}
+	return files
+}
+
+// Files returns the content of every generated file keyed by its path
+// relative to the output directory
+func (out *GeneratedOutput) Files() map[string]string {
+	files := make(map[string]string)
+	for _, f := range out.OutputFiles() {
+		files[f.Path] = f.Content
 	}
+	return files
+}
 
-	// Write Dockerfiles
-	for name, content := range out.Dockerfiles {
-		dockerDir := filepath.Join(dir, name)
-		if err := os.MkdirAll(dockerDir, 0755); err != nil {
-			return fmt.Errorf("failed to create dockerfile directory: %w", err)
+// File actions reported by WriteToDir
+const (
+	ActionCreate    = "create"
+	ActionOverwrite = "overwrite"
+	// ActionMerge adds missing services to an existing base compose file,
+	// or layers the generated compose file onto one via MergeCompose
+	ActionMerge = "merge"
+	// ActionKeep leaves a Dockerfile stackgen did not generate in place
+	ActionKeep = "keep"
+)
+
+// FileAction is one file WriteToDir wrote, or would write in a dry run
+type FileAction struct {
+	Path   string // relative to the output directory
+	Action string
+}
+
+// WriteToDir writes all generated files to the specified directory and
+// returns what it did to each. With dryRun nothing is touched on disk; the
+// actions say which files would be created and which overwritten.
+func (out *GeneratedOutput) WriteToDir(dir string, dryRun bool) ([]FileAction, error) {
+	files := out.OutputFiles()
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	if !dryRun {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	dockerfiles := make(map[string]bool, len(out.Dockerfiles))
+	for name := range out.Dockerfiles {
+		dockerfiles[filepath.Join(name, "Dockerfile")] = true
+	}
+
+	actions := make([]FileAction, 0, len(files)+1)
+	var parallel []File
+	for _, f := range files {
+		action := ActionCreate
+		if _, err := os.Stat(filepath.Join(dir, f.Path)); err == nil {
+			action = ActionOverwrite
+		}
+		if out.composeMerged && f.Path == out.ComposeFileName {
+			action = ActionMerge
+		}
+		if action == ActionOverwrite && dockerfiles[f.Path] && !generatedFile(filepath.Join(dir, f.Path)) {
+			action = ActionKeep
+		}
+		actions = append(actions, FileAction{Path: f.Path, Action: action})
+		if dryRun || action == ActionKeep {
+			continue
+		}
+		// Dockerfiles each get their own directory, so they are written
+		// concurrently below
+		if dockerfiles[f.Path] {
+			parallel = append(parallel, f)
+			continue
+		}
+		if err := writeFile(dir, f); err != nil {
+			return actions, err
+		}
+		out.wrote(f.Path)
+	}
+	if err := out.writeParallel(dir, parallel); err != nil {
+		return actions, err
+	}
+
+	if out.BaseCompose != nil {
+		path := filepath.Join(dir, out.BaseComposePath)
+		action := ActionCreate
+		if _, err := os.Stat(path); err == nil {
+			action = ActionMerge
+		}
+		actions = append(actions, FileAction{Path: out.BaseComposePath, Action: action})
+		if !dryRun {
+			if err := writeBaseCompose(path, out.BaseCompose, out.indent); err != nil {
+				return actions, err
+			}
+			out.wrote(out.BaseComposePath)
+		}
+	}
+
+	return actions, nil
+}
+
+// writeFile writes one generated file, creating its directory
+func writeFile(dir string, f File) error {
+	path := filepath.Join(dir, f.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", f.Path, err)
+	}
+	if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// writeWorkers bounds the number of files written concurrently
+const writeWorkers = 8
+
+// writeParallel writes the named files on a bounded pool of goroutines,
+// which helps on slow or networked filesystems, and returns the first error
+func (out *GeneratedOutput) writeParallel(dir string, files []File) error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, writeWorkers)
+	for _, f := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f File) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := writeFile(dir, f)
+
+			// Also serializes OnWrite for callers that keep counters
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			out.wrote(f.Path)
+		}(f)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+const baseComposeHeader = `# Shared base services generated by stackgen.
+# Project compose files reference these via extends:.
+# Existing services are never overwritten on regenerate.
+
+`
+
+// writeBaseCompose merges base services into the shared base file,
+// leaving services that already exist there untouched. The file is merged
+// as YAML nodes, so keys stackgen doesn't model and comments survive.
+func writeBaseCompose(path string, base *models.ComposeFile, indent int) error {
+	var doc yaml.Node
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("failed to parse base compose %s: %w", path, err)
+		}
+	}
+	header := ""
+	if len(doc.Content) == 0 {
+		header = baseComposeHeader
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("base compose %s is not a mapping", path)
+	}
+	i := mappingIndex(root, "services")
+	if i < 0 {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "services"},
+			&yaml.Node{Kind: yaml.MappingNode})
+		i = len(root.Content) - 2
+	}
+	services := root.Content[i+1]
+	if services.Kind != yaml.MappingNode {
+		return fmt.Errorf("base compose %s: services is not a mapping", path)
+	}
+
+	names := make([]string, 0, len(base.Services))
+	for name := range base.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if mappingIndex(services, name) >= 0 {
+			continue
 		}
-		path := filepath.Join(dockerDir, "Dockerfile")
-		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-			return fmt.Errorf("failed to write Dockerfile for %s: %w", name, err)
+		var node yaml.Node
+		if err := node.Encode(base.Services[name]); err != nil {
+			return fmt.Errorf("failed to encode base service %s: %w", name, err)
 		}
+		services.Content = append(services.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, &node)
 	}
 
+	untagMergeKeys(&doc)
+	data, err := marshalYAML(&doc, indent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal base compose: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create base compose directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(header+string(data)), 0644); err != nil {
+		return fmt.Errorf("failed to write base compose %s: %w", path, err)
+	}
 	return nil
 }
 
+// ComposeMerge reports what MergeCompose did with each service, volume and
+// network, named like "services.api", and with the file's other top-level
+// keys
+type ComposeMerge struct {
+	Added   []string // generated entries the file did not have
+	Updated []string // entries of the file overlaid with the generated ones
+	Kept    []string // entries and top-level keys only the file has
+}
+
+// mergedSections are the top-level compose keys merged entry by entry
+var mergedSections = map[string]bool{"services": true, "volumes": true, "networks": true}
+
+// MergeCompose layers the generated compose file onto existing, an
+// existing compose file at name (relative to the output directory), which
+// WriteToDir then writes in its place. Generated services, volumes and
+// networks are deep-merged into the file's entries of the same name:
+// mappings are merged key by key and other values replaced, so keys only
+// the file sets survive. Other services and top-level keys are kept, along
+// with the file's key order and comments.
+func (out *GeneratedOutput) MergeCompose(name string, existing []byte) (*ComposeMerge, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(existing, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if doc.Kind == 0 {
+		// An empty file
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a compose file: expected a mapping at the top level", name)
+	}
+	var generated yaml.Node
+	if err := yaml.Unmarshal([]byte(out.ComposeYAML), &generated); err != nil {
+		return nil, fmt.Errorf("failed to parse the generated compose file: %w", err)
+	}
+
+	report := &ComposeMerge{}
+	genRoot := generated.Content[0]
+	for i := 0; i+1 < len(genRoot.Content); i += 2 {
+		key, value := genRoot.Content[i].Value, genRoot.Content[i+1]
+		j := mappingIndex(root, key)
+		switch {
+		case j < 0:
+			root.Content = append(root.Content, genRoot.Content[i], value)
+			for k := 0; mergedSections[key] && k+1 < len(value.Content); k += 2 {
+				report.Added = append(report.Added, key+"."+value.Content[k].Value)
+			}
+		case mergedSections[key] && root.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeSection(key, root.Content[j+1], value, report)
+		default:
+			root.Content[j+1] = replaceNode(root.Content[j+1], value)
+		}
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; mappingIndex(genRoot, key) < 0 {
+			report.Kept = append(report.Kept, key)
+		}
+	}
+
+	untagMergeKeys(&doc)
+	data, err := marshalYAML(&doc, out.indent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	out.ComposeYAML = string(data)
+	out.ComposeFileName = name
+	out.composeMerged = true
+	return report, nil
+}
+
+// mergeSection merges the generated entries of a services, volumes or
+// networks mapping into the file's
+func mergeSection(section string, dst, src *yaml.Node, report *ComposeMerge) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		name := src.Content[i].Value
+		if j := mappingIndex(dst, name); j >= 0 {
+			dst.Content[j+1] = mergeNode(dst.Content[j+1], src.Content[i+1])
+			report.Updated = append(report.Updated, section+"."+name)
+			continue
+		}
+		dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+		report.Added = append(report.Added, section+"."+name)
+	}
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		if name := dst.Content[i].Value; mappingIndex(src, name) < 0 {
+			report.Kept = append(report.Kept, section+"."+name)
+		}
+	}
+}
+
+// mergeNode deep-merges src into dst: mappings key by key, anything else
+// replaced by src
+func mergeNode(dst, src *yaml.Node) *yaml.Node {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return replaceNode(dst, src)
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if j := mappingIndex(dst, src.Content[i].Value); j >= 0 {
+			dst.Content[j+1] = mergeNode(dst.Content[j+1], src.Content[i+1])
+		} else {
+			dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+		}
+	}
+	return dst
+}
+
+// replaceNode returns src carrying over the comments of the dst it replaces
+func replaceNode(dst, src *yaml.Node) *yaml.Node {
+	src.HeadComment = dst.HeadComment
+	src.LineComment = dst.LineComment
+	src.FootComment = dst.FootComment
+	return src
+}
+
+// untagMergeKeys clears the tag of << merge keys, which the encoder would
+// otherwise write out as "!!merge <<"
+func untagMergeKeys(node *yaml.Node) {
+	if node.Tag == "!!merge" {
+		node.Tag = ""
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node, or -1
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// indent returns the configured compose indentation
+// composeFileName returns the main compose file name
+func (g *Generator) composeFileName() string {
+	if g.opts.ComposeFileName != "" {
+		return g.opts.ComposeFileName
+	}
+	return DefaultComposeFileName
+}
+
+func (g *Generator) indent() int {
+	if g.opts.Indent != 0 {
+		return g.opts.Indent
+	}
+	return DefaultIndent
+}
+
+// marshalYAML encodes v with the given indentation; yaml.Marshal always
+// uses four spaces
+func marshalYAML(v interface{}, indent int) ([]byte, error) {
+	if indent == 0 {
+		indent = DefaultIndent
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // Print outputs all generated files to stdout (for --dry-run)
 func (out *GeneratedOutput) Print() {
-	fmt.Println("=== docker-compose.yml ===")
-	fmt.Println(out.ComposeYAML)
-	fmt.Println("\n=== .env ===")
-	fmt.Println(out.EnvFile)
-	fmt.Println("\n=== .env.example ===")
-	fmt.Println(out.EnvExampleFile)
-	for name, content := range out.Dockerfiles {
-		fmt.Printf("\n=== %s/Dockerfile ===\n", name)
-		fmt.Println(content)
+	for i, f := range out.OutputFiles() {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("=== %s ===\n", filepath.ToSlash(f.Path))
+		fmt.Println(f.Content)
+	}
+	if out.BaseCompose != nil {
+		if data, err := marshalYAML(out.BaseCompose, out.indent); err == nil {
+			fmt.Printf("\n=== %s (services added if missing) ===\n", out.BaseComposePath)
+			fmt.Println(string(data))
+		}
+	}
+}
+
+// EnvFormats lists the formats supported by FormatEnv
+var EnvFormats = []string{"dotenv", "json", "direnv", "shell"}
+
+// FormatEnv renders environment variables in the given format. Later
+// duplicates of a key win, matching how docker compose reads .env files.
+func FormatEnv(envs []models.EnvVar, format string) (string, error) {
+	var b strings.Builder
+	switch format {
+	case "dotenv":
+		for _, env := range envs {
+			b.WriteString(fmt.Sprintf("%s=%s\n", env.Key, env.Value))
+		}
+	case "json":
+		values := make(map[string]string)
+		for _, env := range envs {
+			values[env.Key] = env.Value
+		}
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal env: %w", err)
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	case "direnv", "shell":
+		if format == "direnv" {
+			b.WriteString("# .envrc - Generated by stackgen\n")
+		}
+		for _, env := range envs {
+			b.WriteString(fmt.Sprintf("export %s=%s\n", env.Key, shellQuote(env.Value)))
+		}
+	default:
+		return "", fmt.Errorf("unknown env format: %s. Use: %s", format, strings.Join(EnvFormats, ", "))
+	}
+	return b.String(), nil
+}
+
+// TestEnvFile renders the env vars as a .env.test for integration test
+// containers. The project's database becomes <project>_test in the
+// database settings and connection URLs, and a SQLite file gets a _test
+// suffix, so tests started with it do not touch development data.
+func TestEnvFile(project *models.Project, envs []models.EnvVar) string {
+	database := project.Name
+	urlDatabase := regexp.MustCompile(`(://[^/]*/)` + regexp.QuoteMeta(database) + `(\?|$)`)
+
+	var b strings.Builder
+	b.WriteString("# Generated by stackgen - Environment for integration tests\n")
+	b.WriteString("# Use with: docker compose --env-file <this file> ...\n\n")
+	for _, env := range envs {
+		value := env.Value
+		switch {
+		case (strings.HasSuffix(env.Key, "POSTGRES_DB") || strings.HasSuffix(env.Key, "MYSQL_DATABASE")) && value == database:
+			value = database + "_test"
+		case strings.HasPrefix(value, "sqlite://") && strings.HasSuffix(value, ".db"):
+			value = strings.TrimSuffix(value, ".db") + "_test.db"
+		default:
+			value = urlDatabase.ReplaceAllString(value, "${1}"+database+"_test${2}")
+		}
+		if env.Description != "" {
+			b.WriteString(fmt.Sprintf("# %s\n", env.Description))
+		}
+		b.WriteString(fmt.Sprintf("%s=%s\n", env.Key, value))
 	}
+	return b.String()
+}
+
+// shellQuote wraps a value in single quotes for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
 
 func generatePassword(length int) string {
@@ -439,6 +3255,33 @@ func generateStrongPassword(length int) string {
 	return string(password)
 }
 
+// postgresConfTarget is where a generated postgresql.conf is mounted
+const postgresConfTarget = "/etc/postgresql/postgresql.conf"
+
+// postgresConf renders a postgresql.conf from tuning settings. Because it
+// replaces the image's config file, listen_addresses is always set so the
+// server stays reachable from other containers.
+func postgresConf(tuning map[string]string) string {
+	settings := map[string]string{"listen_addresses": "'*'"}
+	for k, v := range tuning {
+		settings[k] = v
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# PostgreSQL configuration - Generated by stackgen\n")
+	b.WriteString("# Settings from the datastore's tuning section in stackgen.yaml\n\n")
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("%s = %s\n", k, settings[k]))
+	}
+	return b.String()
+}
+
 func addComposeHeader(yaml string) string {
 	header := `# Generated by stackgen - Local Development Environment Generator
 # For local development and testing only.
@@ -1,9 +1,14 @@
 package generator
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
+	"github.com/stackgen-cli/stackgen/internal/composespec"
 	"github.com/stackgen-cli/stackgen/internal/models"
 )
 
@@ -213,3 +218,2470 @@ func TestStrongPasswordGeneration(t *testing.T) {
 		t.Error("Strong password should contain uppercase, lowercase, digit, and special char")
 	}
 }
+
+func TestGeneratePostgresTuning(t *testing.T) {
+	project := &models.Project{
+		Name:      "tuningtest",
+		OutputDir: ".",
+		Datastores: []models.Datastore{
+			{
+				Type:         models.DatastorePostgres,
+				Name:         "postgres",
+				Port:         5432,
+				InternalPort: 5432,
+				Tag:          "16-alpine",
+				Tuning:       map[string]string{"shared_buffers": "256MB", "max_connections": "200"},
+			},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	conf, ok := output.ConfigFiles["postgres/postgresql.conf"]
+	if !ok {
+		t.Fatal("postgresql.conf should be generated when tuning is set")
+	}
+	if !strings.Contains(conf, "shared_buffers = 256MB") {
+		t.Error("postgresql.conf should contain shared_buffers setting")
+	}
+	if !strings.Contains(conf, "listen_addresses = '*'") {
+		t.Error("postgresql.conf should keep listen_addresses open")
+	}
+
+	if !strings.Contains(output.ComposeYAML, "config_file=/etc/postgresql/postgresql.conf") {
+		t.Error("ComposeYAML should start postgres with the generated config file")
+	}
+}
+
+func TestGeneratePostgresNoTuning(t *testing.T) {
+	project := &models.Project{
+		Name: "notuning",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if len(output.ConfigFiles) != 0 {
+		t.Error("No config files should be generated without tuning")
+	}
+	if strings.Contains(output.ComposeYAML, "config_file") {
+		t.Error("ComposeYAML should use the stock postgres command without tuning")
+	}
+}
+
+func TestGenerateMinimal(t *testing.T) {
+	project := &models.Project{
+		Name: "minimaltest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine"},
+		},
+	}
+
+	output, err := New(project).WithOptions(Options{Minimal: true}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for _, field := range []string{"container_name:", "restart:", "healthcheck:"} {
+		if strings.Contains(output.ComposeYAML, field) {
+			t.Errorf("Minimal ComposeYAML should not contain %s", field)
+		}
+	}
+	if !strings.Contains(output.ComposeYAML, "redis:7-alpine") {
+		t.Error("Minimal ComposeYAML should keep the image")
+	}
+	if !strings.Contains(output.ComposeYAML, "6379:6379") {
+		t.Error("Minimal ComposeYAML should keep ports")
+	}
+}
+
+func TestGenerateJaeger(t *testing.T) {
+	project := &models.Project{
+		Name:   "tracingtest",
+		Jaeger: true,
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080, InternalPort: 8080, BuildContext: "go-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "jaegertracing/all-in-one") {
+		t.Error("ComposeYAML should contain the jaeger service")
+	}
+	if !strings.Contains(output.ComposeYAML, "16686:16686") {
+		t.Error("ComposeYAML should expose the Jaeger UI")
+	}
+	if !strings.Contains(output.EnvFile, "OTEL_EXPORTER_OTLP_ENDPOINT=http://jaeger:4318") {
+		t.Error("EnvFile should point OTLP exporters at jaeger")
+	}
+	if !strings.Contains(output.ComposeYAML, "      jaeger:\n        condition: service_started\n") {
+		t.Error("Runtimes should depend on jaeger")
+	}
+}
+
+func TestVersionStamp(t *testing.T) {
+	project := &models.Project{
+		Name: "stamptest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080, InternalPort: 8080, BuildContext: "go-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	output, err := New(project).WithOptions(Options{Version: "1.2.3"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"docker-compose.yml": output.ComposeYAML,
+		".env":               output.EnvFile,
+		".env.example":       output.EnvExampleFile,
+		"go-app/Dockerfile":  output.Dockerfiles["go-app"],
+	} {
+		if got := ParseVersionStamp(content); got != "1.2.3" {
+			t.Errorf("%s: expected version stamp 1.2.3, got %q", name, got)
+		}
+	}
+}
+
+func TestParseVersionStampMissing(t *testing.T) {
+	if got := ParseVersionStamp("services:\n  app:\n"); got != "" {
+		t.Errorf("Expected no version stamp, got %q", got)
+	}
+}
+
+func TestGenerateSplit(t *testing.T) {
+	project := &models.Project{
+		Name: "splittest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080, InternalPort: 8080, BuildContext: "go-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	output, err := New(project).WithOptions(Options{Split: true}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "include:") {
+		t.Error("Root ComposeYAML should include the split files")
+	}
+	if strings.Contains(output.ComposeYAML, "services:") {
+		t.Error("Root ComposeYAML should not define services")
+	}
+
+	ds, ok := output.ComposeFiles[DatastoresComposeFile]
+	if !ok || !strings.Contains(ds, "postgres:16-alpine") || !strings.Contains(ds, "postgres-data") {
+		t.Error("Datastores compose file should contain postgres and its volume")
+	}
+	rt, ok := output.ComposeFiles[RuntimesComposeFile]
+	if !ok || !strings.Contains(rt, "go-app") || !strings.Contains(rt, "splittest-network") {
+		t.Error("Runtimes compose file should contain go-app and the shared network")
+	}
+}
+
+func TestFormatEnv(t *testing.T) {
+	envs := []models.EnvVar{
+		{Key: "PORT", Value: "8080"},
+		{Key: "GREETING", Value: "it's"},
+	}
+
+	dotenv, err := FormatEnv(envs, "dotenv")
+	if err != nil || !strings.Contains(dotenv, "PORT=8080\n") {
+		t.Errorf("dotenv output unexpected: %q (%v)", dotenv, err)
+	}
+
+	js, err := FormatEnv(envs, "json")
+	if err != nil || !strings.Contains(js, `"PORT": "8080"`) {
+		t.Errorf("json output unexpected: %q (%v)", js, err)
+	}
+
+	sh, err := FormatEnv(envs, "shell")
+	if err != nil || !strings.Contains(sh, `export GREETING='it'\''s'`) {
+		t.Errorf("shell output unexpected: %q (%v)", sh, err)
+	}
+
+	if _, err := FormatEnv(envs, "xml"); err == nil {
+		t.Error("Unknown format should return an error")
+	}
+}
+
+func TestFrameworkDockerfiles(t *testing.T) {
+	project := &models.Project{
+		Name: "frameworktest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Framework: "gin", Port: 8080, InternalPort: 8080, BuildContext: "go-app", Dockerfile: "Dockerfile"},
+			{Type: models.RuntimeNode, Name: "node-app", Framework: "nestjs", Port: 3000, InternalPort: 3000, BuildContext: "node-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.Dockerfiles["go-app"], "github.com/gin-gonic/gin") {
+		t.Error("Gin Dockerfile should fetch the gin module")
+	}
+	goDockerfile := output.Dockerfiles["go-app"]
+	if strings.Index(goDockerfile, "go get") < strings.Index(goDockerfile, "COPY . .") {
+		t.Error("Gin Dockerfile should fetch the module after copying the source, which would replace go.mod")
+	}
+	if !strings.Contains(output.Dockerfiles["node-app"], `CMD ["node", "dist/main.js"]`) {
+		t.Error("NestJS Dockerfile should run dist/main.js")
+	}
+	if !strings.Contains(output.ComposeYAML, "- /app/dist") {
+		t.Error("ComposeYAML should keep dist out of the NestJS bind mount")
+	}
+}
+
+func TestGenerateNextjs(t *testing.T) {
+	project := &models.Project{
+		Name: "nexttest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "node-app", Framework: "nextjs", Port: 3000, InternalPort: 3000, BuildContext: "node-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	dockerfile := output.Dockerfiles["node-app"]
+	if !strings.Contains(dockerfile, "RUN npm run build") || !strings.Contains(dockerfile, `CMD ["npm", "start"]`) {
+		t.Error("Next.js Dockerfile should build and run npm start")
+	}
+	if !strings.Contains(output.ComposeYAML, "/app/.next") {
+		t.Error("ComposeYAML should keep .next out of the bind mount")
+	}
+	if !strings.Contains(output.ComposeYAML, "NODE_ENV: production") {
+		t.Error("ComposeYAML should run Next.js with NODE_ENV=production")
+	}
+}
+
+func TestGenerateNoPassword(t *testing.T) {
+	project := &models.Project{
+		Name: "trusttest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine", NoPassword: true},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine", NoPassword: true},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "POSTGRES_HOST_AUTH_METHOD: trust") {
+		t.Error("ComposeYAML should enable trust auth for postgres")
+	}
+	if strings.Contains(output.ComposeYAML, "requirepass") {
+		t.Error("ComposeYAML should not require a redis password")
+	}
+	if strings.Contains(output.EnvFile, "PASSWORD") {
+		t.Error("EnvFile should not contain passwords")
+	}
+	if !strings.Contains(output.EnvFile, "DATABASE_URL=postgresql://postgres@postgres:5432/trusttest") {
+		t.Error("EnvFile should contain a passwordless connection string")
+	}
+	if !strings.Contains(output.EnvFile, "INSECURE") {
+		t.Error("EnvFile should label passwordless datastores as insecure")
+	}
+}
+
+func TestGenerateNoPasswordUnsupported(t *testing.T) {
+	project := &models.Project{
+		Name: "trusttest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreMSSQL, Name: "mssql", Port: 1433, InternalPort: 1433, Tag: "2022-latest", NoPassword: true},
+		},
+	}
+
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("MSSQL without a password should fail")
+	}
+}
+
+func TestGenerateTimezone(t *testing.T) {
+	project := &models.Project{
+		Name:     "tztest",
+		Timezone: "Europe/Berlin",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, InternalPort: 3306, Tag: "8.0"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080, InternalPort: 8080, BuildContext: "go-app", Dockerfile: "Dockerfile"},
+		},
+	}
+
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for name, service := range gen.compose.Services {
+		if service.Environment["TZ"] != "Europe/Berlin" {
+			t.Errorf("%s should have TZ set", name)
+		}
+	}
+	if gen.compose.Services["postgres"].Environment["PGTZ"] != "Europe/Berlin" {
+		t.Error("postgres should have PGTZ set")
+	}
+	if gen.compose.Services["postgres"].Command != "postgres -c timezone=Europe/Berlin" {
+		t.Errorf("postgres should set the server time zone, got %q", gen.compose.Services["postgres"].Command)
+	}
+	if gen.compose.Services["mysql"].Command != "--default-time-zone=Europe/Berlin" {
+		t.Error("mysql should set the server time zone")
+	}
+}
+
+func TestGeneratePostgresReplicas(t *testing.T) {
+	project := &models.Project{
+		Name: "replicatest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine", Replicas: 2},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for i, port := range []string{"5433:5432", "5434:5432"} {
+		name := fmt.Sprintf("postgres-replica-%d", i+1)
+		replica, ok := gen.compose.Services[name]
+		if !ok {
+			t.Fatalf("%s should be generated", name)
+		}
+		if _, ok := replica.DependsOn["postgres"]; len(replica.DependsOn) != 1 || !ok {
+			t.Errorf("%s should depend on the primary", name)
+		}
+		if replica.Ports[0] != port {
+			t.Errorf("%s should publish %s, got %s", name, port, replica.Ports[0])
+		}
+		if !strings.Contains(output.EnvFile, fmt.Sprintf("DATABASE_REPLICA_%d_URL=", i+1)) {
+			t.Errorf("EnvFile should contain DATABASE_REPLICA_%d_URL", i+1)
+		}
+	}
+
+	if _, ok := output.ConfigFiles["postgres/init-replication.sh"]; !ok {
+		t.Error("Primary replication init script should be generated")
+	}
+	if gen.compose.Services["postgres"].Environment["POSTGRES_REPLICATION_PASSWORD"] == "" {
+		t.Error("Primary should receive the replication password")
+	}
+}
+
+func TestGenerateReplicasUnsupported(t *testing.T) {
+	project := &models.Project{
+		Name: "replicatest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine", Replicas: 1},
+		},
+	}
+
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Replicas on redis should fail")
+	}
+}
+
+func TestGenerateInitAndStopGracePeriod(t *testing.T) {
+	enabled := true
+	project := &models.Project{
+		Name: "shutdowntest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "node-app", Port: 3000, InternalPort: 3000, BuildContext: "node-app", Dockerfile: "Dockerfile", Init: &enabled, StopGracePeriod: "30s"},
+		},
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine"},
+		},
+	}
+
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	rt := gen.compose.Services["node-app"]
+	if rt.Init == nil || !*rt.Init {
+		t.Error("node-app should have init enabled")
+	}
+	if rt.StopGracePeriod != "30s" {
+		t.Errorf("node-app stop_grace_period should be 30s, got %q", rt.StopGracePeriod)
+	}
+	if gen.compose.Services["redis"].Init != nil {
+		t.Error("init should be left unset by default")
+	}
+}
+
+func TestGenerateRuntimeEnvAndSentry(t *testing.T) {
+	project := &models.Project{
+		Name:   "envtest",
+		Sentry: true,
+		Runtimes: []models.Runtime{
+			{
+				Type:         models.RuntimeNode,
+				Name:         "node-app",
+				Framework:    "nextjs",
+				Port:         3000,
+				InternalPort: 3000,
+				BuildContext: "node-app",
+				Dockerfile:   "Dockerfile",
+				Environment:  map[string]string{"LOG_LEVEL": "debug"},
+			},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	env := gen.compose.Services["node-app"].Environment
+	if env["LOG_LEVEL"] != "debug" {
+		t.Error("Runtime environment should be passed to the service")
+	}
+	if env["NODE_ENV"] != "production" {
+		t.Error("Next.js NODE_ENV should still be set alongside runtime environment")
+	}
+	if !strings.Contains(output.EnvExampleFile, "SENTRY_DSN=<your-sentry-dsn>") {
+		t.Error("EnvExampleFile should document SENTRY_DSN as a secret")
+	}
+}
+
+func TestGenerateInternalOnlyDatastore(t *testing.T) {
+	expose := false
+	project := &models.Project{
+		Name: "exposetest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6380, InternalPort: 6379, Tag: "7-alpine", Expose: &expose},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	redis := gen.compose.Services["redis"]
+	if len(redis.Ports) != 0 {
+		t.Error("Internal-only datastore should not publish host ports")
+	}
+	if len(redis.Expose) != 1 || redis.Expose[0] != "6379" {
+		t.Errorf("Internal-only datastore should expose 6379, got %v", redis.Expose)
+	}
+	if !strings.Contains(output.EnvFile, "@redis:6379") {
+		t.Error("Connection string should keep using the service name")
+	}
+}
+
+func TestGenerateBaseCompose(t *testing.T) {
+	project := &models.Project{
+		Name:      "basetest",
+		OutputDir: t.TempDir(),
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+		},
+	}
+
+	gen := New(project).WithOptions(Options{BaseCompose: "../base.yml"})
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "file: ../base.yml") || !strings.Contains(output.ComposeYAML, "service: postgres-base") {
+		t.Error("ComposeYAML should extend postgres-base from the base file")
+	}
+	if strings.Contains(output.ComposeYAML, "postgres:16-alpine") {
+		t.Error("ComposeYAML should inherit the image from the base service")
+	}
+	base, ok := output.BaseCompose.Services["postgres-base"]
+	if !ok || base.Image != "postgres:16-alpine" || base.HealthCheck == nil {
+		t.Error("Base compose should hold the postgres image and healthcheck")
+	}
+}
+
+func TestGenerateBaseComposeExisting(t *testing.T) {
+	project := &models.Project{
+		Name:      "basetest",
+		OutputDir: t.TempDir(),
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine"},
+		},
+	}
+	existing := `services:
+  postgres-base:
+    image: postgres:16-alpine
+    command: ["postgres", "-c", "fsync=off"]
+  redis-base:
+    image: redis:6-alpine
+    restart: unless-stopped
+`
+
+	gen := New(project).WithOptions(Options{BaseCompose: "base.yml", ExistingBaseCompose: []byte(existing)})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if postgres := gen.compose.Services["postgres"]; postgres.Extends != nil || postgres.Image == "" {
+		t.Error("postgres should not extend a base whose command it would inherit")
+	}
+	redis := gen.compose.Services["redis"]
+	if redis.Extends == nil {
+		t.Fatal("redis should extend redis-base")
+	}
+	if redis.Image != "redis:7-alpine" {
+		t.Errorf("redis should override the base image, got %q", redis.Image)
+	}
+	if redis.HealthCheck == nil {
+		t.Error("redis should keep its healthcheck, which the base lacks")
+	}
+	if redis.Restart != "" {
+		t.Error("redis should inherit the matching restart policy")
+	}
+}
+
+func TestWriteBaseComposeKeepsExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "base.yml")
+	existing := `# team base services
+x-logging: &logging
+  driver: local
+services:
+  postgres-base:
+    image: postgres:16-alpine # pinned
+    logging: *logging
+`
+	if err := os.WriteFile(path, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := &models.ComposeFile{Services: map[string]models.ComposeService{
+		"postgres-base": {Image: "postgres:17-alpine"},
+		"redis-base":    {Image: "redis:7-alpine"},
+	}}
+	if err := writeBaseCompose(path, base, DefaultIndent); err != nil {
+		t.Fatalf("writeBaseCompose failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(data)
+	for _, want := range []string{"# team base services", "x-logging: &logging", "logging: *logging", "postgres:16-alpine # pinned", "redis-base:"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("base compose should contain %q, got:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "postgres:17-alpine") {
+		t.Error("existing base services should not be overwritten")
+	}
+}
+
+func TestGenerateEnvPrefix(t *testing.T) {
+	project := &models.Project{
+		Name:      "prefixtest",
+		EnvPrefix: "MYAPP_",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.EnvFile, "\nMYAPP_DATABASE_URL=") {
+		t.Error("EnvFile should prefix DATABASE_URL")
+	}
+	if strings.Contains(output.EnvFile, "\nDATABASE_URL=") {
+		t.Error("EnvFile should not keep unprefixed keys")
+	}
+	if !strings.Contains(output.ComposeYAML, "POSTGRES_PASSWORD: ${MYAPP_POSTGRES_PASSWORD}") {
+		t.Error("ComposeYAML should reference the prefixed password while keeping the container variable name")
+	}
+	if !strings.Contains(output.ComposeYAML, "--requirepass ${MYAPP_REDIS_PASSWORD}") {
+		t.Error("ComposeYAML should rewrite references in commands")
+	}
+}
+
+func TestGenerateEnvPrefixKeepsFrameworkKeys(t *testing.T) {
+	project := &models.Project{
+		Name:      "prefixtest",
+		EnvPrefix: "MYAPP_",
+		Jaeger:    true,
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeCSharp, Name: "api", Framework: "aspnetcore", Port: 5000, InternalPort: 5000, BuildContext: "api"},
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	for _, key := range []string{"ASPNETCORE_URLS", "ASPNETCORE_ENVIRONMENT", "NODE_ENV", "PORT", "OTEL_EXPORTER_OTLP_ENDPOINT"} {
+		if !strings.Contains(output.EnvFile, "\n"+key+"=") {
+			t.Errorf("EnvFile should keep %s unprefixed for the framework to read", key)
+		}
+		if strings.Contains(output.EnvFile, "MYAPP_"+key+"=") {
+			t.Errorf("EnvFile should not prefix %s", key)
+		}
+	}
+	if !strings.Contains(output.EnvFile, "\nMYAPP_DATABASE_URL=") {
+		t.Error("EnvFile should still prefix app variables")
+	}
+}
+
+func TestGeneratePinnedDigest(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	project := &models.Project{
+		Name: "pintest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine", Digest: digest},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, InternalPort: 6379, Tag: "7-alpine"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "image: postgres@"+digest) {
+		t.Error("ComposeYAML should pin postgres by digest")
+	}
+	if !strings.Contains(output.ComposeYAML, "image: redis:7-alpine") {
+		t.Error("ComposeYAML should fall back to the tag without a digest")
+	}
+}
+
+func TestGenerateRuntimeVersion(t *testing.T) {
+	project := &models.Project{
+		Name: "versiontest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "gin", Version: "1.23", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web"},
+		},
+	}
+
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.Dockerfiles["api"], "FROM golang:1.23-alpine") {
+		t.Error("Go Dockerfile should use the configured version")
+	}
+	if !strings.Contains(output.Dockerfiles["web"], "FROM node:20-alpine") {
+		t.Error("Node Dockerfile should fall back to the default version")
+	}
+}
+
+func TestGenerateRejectsInvalidRuntimeVersion(t *testing.T) {
+	project := &models.Project{
+		Name: "versiontest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Version: "20\nRUN echo injected", Port: 3000, InternalPort: 3000},
+		},
+	}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("A version that is not a docker tag should not reach the Dockerfile")
+	}
+}
+
+func TestGenerateGitHubWorkflow(t *testing.T) {
+	project := &models.Project{
+		Name: "citest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "gin", Port: 8080, InternalPort: 8080, BuildContext: "services/api"},
+		},
+	}
+
+	output, err := New(project).WithOptions(Options{CI: "github"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	workflow, ok := output.CIFiles[GitHubWorkflowFile]
+	if !ok {
+		t.Fatal("CIFiles should contain the GitHub workflow")
+	}
+	if !strings.Contains(workflow, "-f services/api/test-container/docker-compose.test.yml run --rm api-test") {
+		t.Error("Workflow should run the runtime's test container")
+	}
+	if !strings.Contains(workflow, "cp .env services/api/test-container/.env.test") {
+		t.Error("Workflow should create the gitignored .env.test")
+	}
+
+	for _, cmd := range []string{"up -d --build --wait", "logs", "down -v"} {
+		if !strings.Contains(workflow, "docker compose -f docker-compose.yml "+cmd) {
+			t.Errorf("Workflow should pass the compose file to %q", cmd)
+		}
+	}
+
+	output, err = New(project).WithOptions(Options{CI: "github", CIWorkDir: "deploy/dev"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	workflow, ok = output.CIFiles["../../"+GitHubWorkflowFile]
+	if !ok {
+		t.Fatalf("Workflow should be written to the repository root, got %v", output.CIFiles)
+	}
+	if !strings.Contains(workflow, "working-directory: deploy/dev") {
+		t.Error("Workflow should run from the output directory")
+	}
+
+	// Build contexts are relative to the output directory the steps run in
+	project.OutputDir = "deploy"
+	project.Runtimes[0].BuildContext = "../api"
+	output, err = New(project).WithOptions(Options{CI: "github", CIWorkDir: "deploy"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	workflow = output.CIFiles["../"+GitHubWorkflowFile]
+	if !strings.Contains(workflow, "-f ../api/test-container/docker-compose.test.yml run --rm api-test") {
+		t.Error("Workflow should find the test container relative to the output directory")
+	}
+
+	if _, err := New(project).WithOptions(Options{CI: "jenkins"}).Generate(); err == nil {
+		t.Error("Generate should reject unknown CI providers")
+	}
+}
+
+func TestGenerateRuntimePortModes(t *testing.T) {
+	project := &models.Project{
+		Name: "portmodetest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimePython, Name: "worker", Framework: "fastapi", Port: 8000, InternalPort: 8000, BuildContext: "worker", PortMode: models.PortModeNone},
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web", PortMode: models.PortModeRange, PortRange: 4},
+		},
+	}
+
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	worker := gen.compose.Services["worker"]
+	if len(worker.Ports) != 0 {
+		t.Errorf("Port mode none should publish nothing, got %v", worker.Ports)
+	}
+	if len(worker.Expose) != 1 || worker.Expose[0] != "8000" {
+		t.Errorf("Port mode none should expose the container port, got %v", worker.Expose)
+	}
+	web := gen.compose.Services["web"]
+	if len(web.Ports) != 1 || web.Ports[0] != "3000-3003:3000" {
+		t.Errorf("Port mode range should publish 3000-3003, got %v", web.Ports)
+	}
+	if web.ContainerName != "" {
+		t.Error("Port mode range should drop container_name so the service can scale")
+	}
+}
+
+func TestGenerateRuntimeContextSubdirectory(t *testing.T) {
+	project := &models.Project{
+		Name: "contexttest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "services/api"},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"]
+	if api.Build.Context != "services/api" {
+		t.Errorf("Build context should be services/api, got %s", api.Build.Context)
+	}
+	if len(api.Volumes) == 0 || api.Volumes[0] != "./services/api:/app" {
+		t.Errorf("Source mount should use the build context, got %v", api.Volumes)
+	}
+	if _, ok := output.Dockerfiles["services/api"]; !ok {
+		t.Error("Dockerfile should be written into the build context")
+	}
+}
+
+func TestGenerateCustomDockerfile(t *testing.T) {
+	project := &models.Project{
+		Name: "dockerfiletest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web", Dockerfile: "Dockerfile.dev"},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if df := gen.compose.Services["web"].Build.Dockerfile; df != "Dockerfile.dev" {
+		t.Errorf("Build should use Dockerfile.dev, got %s", df)
+	}
+	if _, ok := output.Dockerfiles["web"]; ok {
+		t.Error("No Dockerfile should be generated for a custom dockerfile path")
+	}
+}
+
+func TestGenerateWatchSync(t *testing.T) {
+	project := &models.Project{
+		Name: "watchtest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web"},
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+
+	gen := New(project).WithOptions(Options{WatchSync: true})
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	web := gen.compose.Services["web"]
+	if web.Develop == nil || web.Develop.Watch[0].Action != "sync" || web.Develop.Watch[0].Target != "/app" {
+		t.Fatal("Node runtime should sync its build context into /app")
+	}
+	if !strings.Contains(output.ComposeYAML, "path: ./web/package.json") {
+		t.Error("Node runtime should rebuild when package.json changes")
+	}
+	for _, v := range web.Volumes {
+		if v == "./web:/app" {
+			t.Error("Watched runtime should not also bind-mount its sources")
+		}
+	}
+
+	api := gen.compose.Services["api"]
+	if api.Develop == nil || api.Develop.Watch[0].Action != "rebuild" {
+		t.Error("Go runtime should rebuild on source changes")
+	}
+}
+
+func TestGenerateInlineEnv(t *testing.T) {
+	project := &models.Project{
+		Name: "inlinetest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080,
+				Environment: map[string]string{"GO_ENV": "test"}},
+		},
+	}
+
+	gen := New(project).WithOptions(Options{InlineEnv: true})
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"]
+	if len(api.EnvFile) != 0 {
+		t.Error("Inline env should drop env_file")
+	}
+	if api.Environment["POSTGRES_USER"] != "postgres" {
+		t.Error("Non-secret values should be written inline")
+	}
+	if api.Environment["POSTGRES_PASSWORD"] != "${POSTGRES_PASSWORD}" {
+		t.Error("Secrets should stay interpolated from the shell")
+	}
+	if api.Environment["GO_ENV"] != "test" {
+		t.Error("Runtime environment should override generated values")
+	}
+	if !strings.Contains(output.EnvExampleFile, "POSTGRES_PASSWORD=") {
+		t.Error(".env.example should still document every variable")
+	}
+}
+
+func TestGenerateDockerfileDir(t *testing.T) {
+	project := &models.Project{
+		Name: "dockerdirtest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "services/api"},
+		},
+	}
+
+	gen := New(project).WithOptions(Options{DockerfileDir: "docker"})
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if _, ok := output.Dockerfiles["docker/api"]; !ok {
+		t.Errorf("Dockerfile should be written under docker/api, got %v", output.Dockerfiles)
+	}
+	build := gen.compose.Services["api"].Build
+	if build.Context != "services/api" || build.Dockerfile != "../../docker/api/Dockerfile" {
+		t.Errorf("Build should keep the source context and point at the moved Dockerfile, got %+v", build)
+	}
+}
+
+func TestGenerateEphemeralPort(t *testing.T) {
+	project := &models.Project{
+		Name: "ephemeraltest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 0, Replicas: 1},
+			{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 0},
+		},
+	}
+
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if ports := gen.compose.Services["postgres"].Ports; len(ports) != 1 || ports[0] != "5432" {
+		t.Errorf("Port 0 should publish the container port only, got %v", ports)
+	}
+	if ports := gen.compose.Services["postgres-replica-1"].Ports; len(ports) != 1 || ports[0] != "5432" {
+		t.Errorf("Replicas of an ephemeral primary should also get ephemeral ports, got %v", ports)
+	}
+	if ports := gen.compose.Services["neo4j"].Ports; len(ports) != 2 || ports[1] != "7687" {
+		t.Errorf("Neo4j Bolt port should also be ephemeral, got %v", ports)
+	}
+}
+
+func TestGenerateProvenance(t *testing.T) {
+	project := &models.Project{
+		Name: "explaintest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Tag: "16-alpine"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+
+	output, err := New(project).WithOptions(Options{Minimal: true}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	found := false
+	for _, p := range output.Provenance {
+		if p.Service == "postgres" && p.Key == "image" {
+			found = true
+			if p.Source != "datastores[postgres].tag" || p.Value != "postgres:16-alpine" {
+				t.Errorf("postgres image should be explained by its tag, got %+v", p)
+			}
+		}
+		if p.Key == "container_name" || p.Key == "healthcheck" {
+			t.Errorf("Keys removed by --minimal should not be explained, got %+v", p)
+		}
+	}
+	if !found {
+		t.Error("Provenance should explain the postgres image")
+	}
+
+	swappiness, disable := 0, true
+	project.Datastores[0].MemSwappiness = &swappiness
+	project.Datastores[0].OOMKillDisable = &disable
+	project.Runtimes[0].MemSwappiness = &swappiness
+	output, err = New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	explained := make(map[string]string)
+	for _, p := range output.Provenance {
+		explained[p.Service+"."+p.Key] = p.Source
+	}
+	for key, source := range map[string]string{
+		"postgres.mem_swappiness":   "datastores[postgres].mem_swappiness",
+		"postgres.oom_kill_disable": "datastores[postgres].oom_kill_disable",
+		"api.mem_swappiness":        "runtimes[api].mem_swappiness",
+	} {
+		if explained[key] != source {
+			t.Errorf("%s should be explained by %s, got %q", key, source, explained[key])
+		}
+	}
+}
+
+func TestGenerateSQLite(t *testing.T) {
+	project := &models.Project{
+		Name:     "sqlitetest",
+		Timezone: "UTC",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreSQLite, Name: "sqlite"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimePython, Name: "app", Framework: "flask", Port: 5000, InternalPort: 5000, BuildContext: "app"},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if _, ok := gen.compose.Services["sqlite"]; ok {
+		t.Error("SQLite should not produce a compose service")
+	}
+	if _, ok := gen.compose.Volumes["sqlite-data"]; ok {
+		t.Error("SQLite should not produce a named volume")
+	}
+	found := false
+	for _, v := range gen.compose.Services["app"].Volumes {
+		if v == "./data:/data" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Runtimes should mount ./data for the SQLite file")
+	}
+	if !strings.Contains(output.EnvFile, "DATABASE_URL=sqlite:///data/app.db") {
+		t.Error(".env should point DATABASE_URL at the SQLite file")
+	}
+	if _, ok := output.ConfigFiles["data/.gitkeep"]; !ok {
+		t.Error("The data directory should be created with the output")
+	}
+}
+
+func TestGenerateSQLiteWithOtherDatabases(t *testing.T) {
+	project := &models.Project{
+		Name: "sqlitetest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreSQLite, Name: "cache"},
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, InternalPort: 5432, Tag: "16-alpine"},
+			{Type: models.DatastoreSQLite, Name: "audit-log"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimePython, Name: "app", Framework: "flask", Port: 5000, InternalPort: 5000, BuildContext: "app", DependsOn: []string{"cache", "postgres"}},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if n := strings.Count(output.EnvFile, "\nDATABASE_URL="); n != 1 {
+		t.Errorf(".env should set DATABASE_URL once, got %d", n)
+	}
+	for _, want := range []string{"CACHE_DATABASE_URL=sqlite:///data/cache.db", "AUDIT_LOG_DATABASE_URL=sqlite:///data/audit-log.db"} {
+		if !strings.Contains(output.EnvFile, want) {
+			t.Errorf(".env should contain %q", want)
+		}
+	}
+	deps := gen.compose.Services["app"].DependsOn
+	if _, ok := deps["cache"]; ok {
+		t.Error("depends_on should leave out SQLite, which has no service")
+	}
+	if _, ok := deps["postgres"]; !ok {
+		t.Error("depends_on should keep postgres")
+	}
+}
+
+func TestGenerateInternalNetwork(t *testing.T) {
+	project := &models.Project{
+		Name: "tiered",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1, InternalNetwork: true},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if !strings.Contains(output.ComposeYAML, "internal: true") {
+		t.Error("An internal: true network should be defined")
+	}
+	for _, name := range []string{"postgres", "postgres-replica-1"} {
+		service := gen.compose.Services[name]
+		if len(service.Networks) != 1 || service.Networks[0] != "tiered-internal" {
+			t.Errorf("%s should only be on the internal network, got %v", name, service.Networks)
+		}
+		if len(service.Ports) != 0 {
+			t.Errorf("%s should not publish host ports, got %v", name, service.Ports)
+		}
+	}
+	if networks := gen.compose.Services["redis"].Networks; len(networks) != 1 || networks[0] != "tiered-network" {
+		t.Errorf("Other datastores should stay on the default network, got %v", networks)
+	}
+	if networks := gen.compose.Services["api"].Networks; len(networks) != 2 {
+		t.Errorf("Runtimes should bridge both networks, got %v", networks)
+	}
+}
+
+func TestGenerateLabels(t *testing.T) {
+	project := &models.Project{
+		Name: "labeltest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+	labels := map[string]string{"org.opencontainers.image.source": "https://github.com/acme/shop"}
+
+	gen := New(project).WithOptions(Options{Labels: labels})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"]
+	if api.Labels["org.opencontainers.image.source"] == "" || api.Build.Labels["org.opencontainers.image.source"] == "" {
+		t.Error("Runtime service and its image should carry the labels")
+	}
+	if len(gen.compose.Services["redis"].Labels) != 0 {
+		t.Error("Datastores should not be labelled with the project's source")
+	}
+}
+
+func TestGenerateReplicasBehindProxy(t *testing.T) {
+	project := &models.Project{
+		Name: "scaled",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api", Replicas: 3},
+		},
+	}
+
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"]
+	if api.Deploy == nil || api.Deploy.Replicas != 3 {
+		t.Error("Runtime should be scaled to 3 replicas")
+	}
+	if api.ContainerName != "" || len(api.Ports) != 0 {
+		t.Error("Scaled runtime should have no container_name or host ports")
+	}
+	proxy, ok := gen.compose.Services["api-proxy"]
+	if !ok || len(proxy.Ports) != 1 || proxy.Ports[0] != "8080:80" {
+		t.Fatalf("Proxy should publish the runtime's port, got %+v", proxy)
+	}
+	if !strings.Contains(output.ConfigFiles["api-proxy/nginx.conf"], "server api:8080;") {
+		t.Error("Proxy upstream should point at the runtime service name")
+	}
+
+	project.Runtimes[0].PortMode = models.PortModeRange
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Replicas behind a proxy should reject range port mode")
+	}
+	project.Runtimes[0].PortMode = ""
+	project.Runtimes[0].PortRanges = []string{"10000-10010:10000-10010"}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Replicas behind a proxy should reject port_ranges, which the proxy would drop")
+	}
+	project.Runtimes[0].PortRanges = nil
+	project.Runtimes[0].DebugPort = 40000
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Replicas behind a proxy should reject debug_port")
+	}
+}
+
+func TestStaleDockerfiles(t *testing.T) {
+	dir := t.TempDir()
+	project := &models.Project{
+		Name: "prunetest",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+	output, err := New(project).WithOptions(Options{Version: "1.0.0"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, err := output.WriteToDir(dir, false); err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+
+	// A removed runtime's generated Dockerfile and a hand-written one, a
+	// nested project's and one written with --dockerfile-dir
+	for path, content := range map[string]string{
+		"worker/Dockerfile":        output.Dockerfiles["api"],
+		"legacy/Dockerfile":        "FROM alpine\n",
+		"svc/go-app/Dockerfile":    output.Dockerfiles["api"],
+		"docker/go-app/Dockerfile": output.Dockerfiles["api"],
+		"docker/old/Dockerfile":    output.Dockerfiles["api"],
+	} {
+		full := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		os.WriteFile(full, []byte(content), 0644)
+	}
+
+	stale, err := StaleDockerfiles(dir, "", output)
+	if err != nil {
+		t.Fatalf("StaleDockerfiles failed: %v", err)
+	}
+	if len(stale) != 1 || stale[0] != filepath.Join("worker", "Dockerfile") {
+		t.Errorf("Only the removed runtime's generated Dockerfile should be stale, got %v", stale)
+	}
+
+	project.Runtimes = append(project.Runtimes, models.Runtime{Type: models.RuntimeGo, Name: "go-app", Framework: "stdlib", Port: 8081, InternalPort: 8080})
+	output, err = New(project).WithOptions(Options{Version: "1.0.0", DockerfileDir: "docker"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	stale, err = StaleDockerfiles(dir, "docker", output)
+	if err != nil {
+		t.Fatalf("StaleDockerfiles failed: %v", err)
+	}
+	want := []string{filepath.Join("worker", "Dockerfile"), filepath.Join("docker", "old", "Dockerfile")}
+	if !slices.Equal(stale, want) {
+		t.Errorf("With a Dockerfile dir, stale = %v, want %v", stale, want)
+	}
+}
+
+func TestWriteToDirDryRun(t *testing.T) {
+	dir := t.TempDir()
+	project := &models.Project{
+		Name: "dryrun",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("OLD=1\n"), 0644)
+
+	actions, err := output.WriteToDir(dir, true)
+	if err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	got := make(map[string]string)
+	for _, a := range actions {
+		got[a.Path] = a.Action
+	}
+	if got[".env"] != ActionOverwrite {
+		t.Errorf("Existing .env should be overwritten, got %q", got[".env"])
+	}
+	if got["docker-compose.yml"] != ActionCreate {
+		t.Errorf("Missing docker-compose.yml should be created, got %q", got["docker-compose.yml"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docker-compose.yml")); err == nil {
+		t.Error("Dry run should not write files")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".env")); string(data) != "OLD=1\n" {
+		t.Error("Dry run should not modify existing files")
+	}
+
+	actions, err = output.WriteToDir(dir, false)
+	if err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	if len(actions) != len(got) {
+		t.Errorf("Dry run reported %d actions, write reported %d", len(got), len(actions))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docker-compose.yml")); err != nil {
+		t.Error("docker-compose.yml should be written")
+	}
+}
+
+func TestDisableHealthCheck(t *testing.T) {
+	project := &models.Project{
+		Name: "nohealth",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1, DisableHealthCheck: true},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if gen.compose.Services["postgres"].HealthCheck != nil {
+		t.Error("postgres healthcheck should be omitted")
+	}
+	if gen.compose.Services["postgres-replica-1"].HealthCheck != nil {
+		t.Error("postgres replica healthcheck should be omitted")
+	}
+	if gen.compose.Services["redis"].HealthCheck == nil {
+		t.Error("redis healthcheck should be kept")
+	}
+}
+
+func TestReadOnlyRootFS(t *testing.T) {
+	project := &models.Project{
+		Name: "hardened",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, ReadOnlyRootFS: true},
+		},
+	}
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	pg := gen.compose.Services["postgres"]
+	if !pg.ReadOnly {
+		t.Error("postgres should have a read-only root filesystem")
+	}
+	if strings.Join(pg.Tmpfs, ",") != "/var/run/postgresql,/tmp" {
+		t.Errorf("postgres tmpfs = %v", pg.Tmpfs)
+	}
+	if !strings.Contains(output.ComposeYAML, "read_only: true") {
+		t.Error("ComposeYAML should contain read_only: true")
+	}
+
+	project.Datastores = []models.Datastore{{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 7474, ReadOnlyRootFS: true}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("neo4j should reject a read-only root filesystem")
+	}
+}
+
+func TestUlimits(t *testing.T) {
+	project := &models.Project{
+		Name: "limits",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 7474},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, Ulimits: map[string]string{"nofile": "1024:4096"}},
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if got := gen.compose.Services["neo4j"].Ulimits["nofile"]; got != 40000 {
+		t.Errorf("neo4j should get its required nofile limit, got %v", got)
+	}
+	redis, ok := gen.compose.Services["redis"].Ulimits["nofile"].(map[string]int)
+	if !ok || redis["soft"] != 1024 || redis["hard"] != 4096 {
+		t.Errorf("redis nofile = %v, want soft 1024 hard 4096", gen.compose.Services["redis"].Ulimits["nofile"])
+	}
+	if gen.compose.Services["postgres"].Ulimits != nil {
+		t.Error("postgres needs no ulimits")
+	}
+
+	project.Datastores[1].Ulimits = map[string]string{"nofile": "lots"}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An invalid ulimit should fail generation")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	project := &models.Project{
+		Name: "caps",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, CapDrop: []string{"ALL"}, SecurityOpt: []string{"no-new-privileges:true"}},
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, CapAdd: []string{"SYS_NICE"}},
+		},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, want := range []string{"cap_drop:\n      - ALL", "security_opt:\n      - no-new-privileges:true", "cap_add:\n      - SYS_NICE"} {
+		if !strings.Contains(output.ComposeYAML, want) {
+			t.Errorf("ComposeYAML should contain %q", want)
+		}
+	}
+	if strings.Count(output.ComposeYAML, "cap_") != 2 {
+		t.Error("Capabilities should only be emitted where configured")
+	}
+}
+
+func TestShmSize(t *testing.T) {
+	project := &models.Project{
+		Name: "shm",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1},
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, ShmSize: "1g"},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for name, want := range map[string]string{"postgres": "256m", "postgres-replica-1": "256m", "mysql": "1g", "redis": ""} {
+		if got := gen.compose.Services[name].ShmSize; got != want {
+			t.Errorf("%s shm_size = %q, want %q", name, got, want)
+		}
+	}
+
+	project.Datastores[1].ShmSize = "lots"
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An invalid shm_size should fail generation")
+	}
+}
+
+func TestDatastoreOnlyAndRuntimeOnly(t *testing.T) {
+	project := &models.Project{
+		Name: "halves",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Tuning: map[string]string{"max_connections": "50"}},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, DependsOn: []string{"postgres"}},
+		},
+	}
+
+	gen := New(project).WithOptions(Options{DatastoreOnly: true})
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, ok := gen.compose.Services["api"]; ok {
+		t.Error("--datastore-only should leave runtimes out")
+	}
+	if _, ok := gen.compose.Services["postgres"]; !ok {
+		t.Error("--datastore-only should keep datastores")
+	}
+	if len(output.Dockerfiles) != 0 {
+		t.Error("--datastore-only should not generate Dockerfiles")
+	}
+
+	gen = New(project).WithOptions(Options{RuntimeOnly: true})
+	output, err = gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	api, ok := gen.compose.Services["api"]
+	if !ok || len(gen.compose.Services) != 1 {
+		t.Fatalf("--runtime-only should keep only runtimes, got %d services", len(gen.compose.Services))
+	}
+	if len(api.DependsOn) != 0 {
+		t.Errorf("depends_on should not reference left-out datastores, got %v", api.DependsOn)
+	}
+	if len(gen.compose.Volumes) != 0 {
+		t.Errorf("Datastore volumes should be dropped, got %v", gen.compose.Volumes)
+	}
+	if _, ok := output.ConfigFiles["postgres/postgresql.conf"]; ok {
+		t.Error("Datastore config files should be dropped")
+	}
+	if !strings.Contains(output.EnvFile, "DATABASE_URL=") {
+		t.Error("Connection env vars should be kept for runtimes")
+	}
+}
+
+func TestSeedFile(t *testing.T) {
+	project := &models.Project{
+		Name: "seeded",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, SeedFile: "fixtures/seed.sql.gz"},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	want := "./fixtures/seed.sql.gz:/docker-entrypoint-initdb.d/zz-seed.sql.gz:ro"
+	if !strings.Contains(strings.Join(gen.compose.Services["postgres"].Volumes, "\n"), want) {
+		t.Errorf("postgres should mount the seed file as %s, got %v", want, gen.compose.Services["postgres"].Volumes)
+	}
+
+	project.Datastores[0].SeedFile = "seed.json"
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An unsupported seed file format should fail")
+	}
+	project.Datastores[0] = models.Datastore{Type: models.DatastoreRedis, Name: "redis", Port: 6379, SeedFile: "seed.sql"}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Seed data should be rejected for redis")
+	}
+}
+
+func TestWriteToDirDockerfiles(t *testing.T) {
+	dir := t.TempDir()
+	project := &models.Project{Name: "many"}
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("svc-%d", i)
+		project.Runtimes = append(project.Runtimes, models.Runtime{Type: models.RuntimeGo, Name: name, Framework: "stdlib", Port: 8080 + i, InternalPort: 8080})
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	written := 0
+	output.OnWrite = func(string) { written++ }
+	if _, err := output.WriteToDir(dir, false); err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	for name, content := range output.Dockerfiles {
+		data, err := os.ReadFile(filepath.Join(dir, name, "Dockerfile"))
+		if err != nil || string(data) != content {
+			t.Errorf("%s/Dockerfile not written correctly: %v", name, err)
+		}
+	}
+	if written != len(output.Files()) {
+		t.Errorf("OnWrite called %d times, want %d", written, len(output.Files()))
+	}
+}
+
+func TestWriteToDirKeepsUserDockerfile(t *testing.T) {
+	dir := t.TempDir()
+	project := &models.Project{Name: "adopted", Runtimes: []models.Runtime{
+		{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api", Dockerfile: "Dockerfile"},
+		{Type: models.RuntimeGo, Name: "worker", Framework: "stdlib", Port: 8081, InternalPort: 8080, BuildContext: "worker", Dockerfile: "Dockerfile"},
+	}}
+	own := "FROM golang:1.22\nCOPY . .\n"
+	for name, content := range map[string]string{"api": own, "worker": "# Go Dockerfile - Generated by stackgen\nFROM old\n"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "Dockerfile"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	actions, err := output.WriteToDir(dir, false)
+	if err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	if !slices.Contains(actions, FileAction{Path: filepath.Join("api", "Dockerfile"), Action: ActionKeep}) {
+		t.Errorf("the user's Dockerfile should be kept, got %v", actions)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "api", "Dockerfile")); string(data) != own {
+		t.Errorf("the user's Dockerfile was overwritten:\n%s", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "worker", "Dockerfile")); string(data) != output.Dockerfiles["worker"] {
+		t.Error("a previously generated Dockerfile should be regenerated")
+	}
+}
+
+func BenchmarkWriteToDir(b *testing.B) {
+	project := &models.Project{Name: "bench"}
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("svc-%d", i)
+		project.Runtimes = append(project.Runtimes, models.Runtime{Type: models.RuntimeNode, Name: name, Framework: "express", Port: 3000 + i, InternalPort: 3000})
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		b.Fatalf("Generate failed: %v", err)
+	}
+	dir := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := output.WriteToDir(dir, false); err != nil {
+			b.Fatalf("WriteToDir failed: %v", err)
+		}
+	}
+}
+
+func TestOutputFiles(t *testing.T) {
+	project := &models.Project{
+		Name:       "stream",
+		Datastores: []models.Datastore{{Type: models.DatastoreRedis, Name: "redis", Port: 6379}},
+		Runtimes:   []models.Runtime{{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080}},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	files := output.OutputFiles()
+	if files[0].Path != "docker-compose.yml" {
+		t.Errorf("docker-compose.yml should come first, got %s", files[0].Path)
+	}
+	if len(files) != len(output.Files()) {
+		t.Errorf("OutputFiles and Files should list the same files, got %d and %d", len(files), len(output.Files()))
+	}
+	for _, f := range files {
+		if f.Content != output.Files()[f.Path] {
+			t.Errorf("OutputFiles and Files disagree on %s", f.Path)
+		}
+	}
+}
+
+func TestDatastoreVersionAlias(t *testing.T) {
+	project := &models.Project{
+		Name: "aliases",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, Tag: "8.0", Version: "lts"},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if image := gen.compose.Services["mysql"].Image; image != "mysql:8.4" {
+		t.Errorf("version lts should resolve to mysql:8.4, got %s", image)
+	}
+
+	project.Datastores[0].Version = "newest"
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An unknown version alias should fail generation")
+	}
+}
+
+func TestRuntimeHealthCheck(t *testing.T) {
+	project := &models.Project{
+		Name: "health",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 8080, InternalPort: 8080, HealthCheck: &models.RuntimeHealthCheck{}},
+			{Type: models.RuntimePython, Name: "web", Port: 8000, InternalPort: 8000, HealthCheck: &models.RuntimeHealthCheck{Path: "readyz", Interval: "30s"}},
+			{Type: models.RuntimeNode, Name: "grpc", Port: 3000, InternalPort: 3000, HealthCheck: &models.RuntimeHealthCheck{Cmd: "grpc_health_probe -addr=:3000"}},
+			{Type: models.RuntimeNode, Name: "plain", Port: 3001, InternalPort: 3000},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"].HealthCheck
+	if api == nil || strings.Join(api.Test, " ") != "CMD wget -q -O /dev/null http://localhost:8080/health" || api.Interval != "10s" {
+		t.Errorf("api healthcheck = %+v, want a wget of /health every 10s", api)
+	}
+	web := gen.compose.Services["web"].HealthCheck
+	if web == nil || web.Test[1] != "python" || !strings.Contains(web.Test[3], "http://localhost:8000/readyz") || web.Interval != "30s" {
+		t.Errorf("web healthcheck = %+v, want a urllib probe of /readyz every 30s", web)
+	}
+	grpc := gen.compose.Services["grpc"].HealthCheck
+	if grpc == nil || strings.Join(grpc.Test, " ") != "CMD-SHELL grpc_health_probe -addr=:3000" {
+		t.Errorf("grpc healthcheck = %+v, want the custom command", grpc)
+	}
+	if plain := gen.compose.Services["plain"].HealthCheck; plain != nil {
+		t.Errorf("Runtime without health_check got a healthcheck: %+v", plain)
+	}
+}
+
+func TestPostgresDatabases(t *testing.T) {
+	project := &models.Project{
+		Name: "multi",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Databases: []string{"app", "key-cloak"}, DatabaseRoles: true},
+		},
+	}
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	script := output.ConfigFiles["postgres/init-databases.sh"]
+	for _, want := range []string{
+		`CREATE ROLE "app" LOGIN PASSWORD '$APP_DB_PASSWORD'`,
+		`CREATE DATABASE "key-cloak" OWNER "key-cloak"`,
+		`\$\$`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("init-databases.sh missing %q:\n%s", want, script)
+		}
+	}
+	service := gen.compose.Services["postgres"]
+	if !strings.Contains(strings.Join(service.Volumes, " "), "./postgres/init-databases.sh:/docker-entrypoint-initdb.d/init-databases.sh:ro") {
+		t.Errorf("init script not mounted: %v", service.Volumes)
+	}
+	if service.Environment["KEY_CLOAK_DB_PASSWORD"] != "${KEY_CLOAK_DB_PASSWORD}" {
+		t.Errorf("role password not passed to the container: %v", service.Environment)
+	}
+	if !strings.Contains(output.EnvFile, "KEY_CLOAK_DATABASE_URL=postgresql://key-cloak:") {
+		t.Errorf(".env missing KEY_CLOAK_DATABASE_URL:\n%s", output.EnvFile)
+	}
+
+	project.Datastores[0].Databases = []string{"bad name"}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An invalid database name should fail generation")
+	}
+}
+
+func TestMemorySettings(t *testing.T) {
+	swappiness, disable := 0, true
+	project := &models.Project{
+		Name: "memory",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, MemSwappiness: &swappiness, OOMKillDisable: &disable},
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 8080, InternalPort: 8080, OOMKillDisable: &disable},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	redis := gen.compose.Services["redis"]
+	if redis.MemSwappiness == nil || *redis.MemSwappiness != 0 || redis.OOMKillDisable == nil || !*redis.OOMKillDisable {
+		t.Errorf("redis should set mem_swappiness 0 and oom_kill_disable, got %v %v", redis.MemSwappiness, redis.OOMKillDisable)
+	}
+	if api := gen.compose.Services["api"]; api.OOMKillDisable == nil || api.MemSwappiness != nil {
+		t.Errorf("api should set only oom_kill_disable, got %v %v", api.MemSwappiness, api.OOMKillDisable)
+	}
+	if pg := gen.compose.Services["postgres"]; pg.MemSwappiness != nil || pg.OOMKillDisable != nil {
+		t.Error("Unset memory settings should not be emitted")
+	}
+
+	swappiness = 150
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("mem_swappiness above 100 should fail generation")
+	}
+}
+
+func TestExternalNetwork(t *testing.T) {
+	project := &models.Project{
+		Name:            "shared",
+		ExternalNetwork: "platform",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 8080, InternalPort: 8080},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if network, ok := gen.compose.Networks["platform"].(map[string]bool); len(gen.compose.Networks) != 1 || !ok || !network["external"] {
+		t.Errorf("Expected only the external platform network, got %v", gen.compose.Networks)
+	}
+	for _, name := range []string{"redis", "api"} {
+		if got := gen.compose.Services[name].Networks; len(got) != 1 || got[0] != "platform" {
+			t.Errorf("%s networks = %v, want [platform]", name, got)
+		}
+	}
+
+	gen = New(project).WithOptions(Options{ExternalNetwork: "other"})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, ok := gen.compose.Networks["other"]; !ok {
+		t.Errorf("The option should override external_network, got %v", gen.compose.Networks)
+	}
+}
+
+func TestMetricsExporter(t *testing.T) {
+	project := &models.Project{
+		Name: "metrics",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, MetricsPort: 9187},
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, MetricsPort: 9104},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, MetricsPort: 9121, NoPassword: true},
+			{Type: models.DatastoreRedis, Name: "cache", Port: 6380},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	pg := gen.compose.Services["postgres-exporter"]
+	if pg.Ports[0] != "9187:9187" || !strings.Contains(pg.Environment["DATA_SOURCE_NAME"], "${POSTGRES_PASSWORD}@postgres:5432") {
+		t.Errorf("postgres-exporter should publish 9187 and use the datastore's password, got %+v", pg)
+	}
+	if _, ok := pg.DependsOn["postgres"]; len(pg.DependsOn) != 1 || !ok {
+		t.Errorf("postgres-exporter should depend on postgres, got %v", pg.DependsOn)
+	}
+	if mysql := gen.compose.Services["mysql-exporter"]; mysql.Environment["MYSQLD_EXPORTER_PASSWORD"] != "${MYSQL_ROOT_PASSWORD}" {
+		t.Errorf("mysql-exporter should read MYSQL_ROOT_PASSWORD, got %v", mysql.Environment)
+	}
+	if redis := gen.compose.Services["redis-exporter"]; redis.Environment["REDIS_ADDR"] != "redis://redis:6379" || redis.Environment["REDIS_PASSWORD"] != "" {
+		t.Errorf("passwordless redis-exporter should not set REDIS_PASSWORD, got %v", redis.Environment)
+	}
+	if _, ok := gen.compose.Services["cache-exporter"]; ok {
+		t.Error("Datastores without metrics_port should not get an exporter")
+	}
+
+	project.Datastores = []models.Datastore{{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 7474, MetricsPort: 9999}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("metrics_port on a datastore without an exporter should fail generation")
+	}
+}
+
+func TestIndent(t *testing.T) {
+	project := &models.Project{
+		Name:       "indent",
+		Datastores: []models.Datastore{{Type: models.DatastoreRedis, Name: "redis", Port: 6379}},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(output.ComposeYAML, "services:\n  redis:\n    image:") {
+		t.Errorf("Compose should default to 2-space indentation:\n%s", output.ComposeYAML)
+	}
+
+	output, err = New(project).WithOptions(Options{Indent: 4, Split: true}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !strings.Contains(output.ComposeFiles[DatastoresComposeFile], "services:\n    redis:\n        image:") {
+		t.Errorf("Split compose files should use the configured indentation:\n%s", output.ComposeFiles[DatastoresComposeFile])
+	}
+
+	if _, err := New(project).WithOptions(Options{Indent: 12}).Generate(); err == nil {
+		t.Error("An indent the encoder cannot produce should fail generation")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	project := &models.Project{
+		Name: "resolve",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Tag: "14", Version: "16"},
+		},
+	}
+	resolved, err := New(project).WithOptions(Options{EnvPrefix: "APP_"}).Resolve()
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if resolved.EnvPrefix != "APP_" || resolved.OutputDir != "." {
+		t.Errorf("Overrides and defaults not applied: env_prefix %q, output_dir %q", resolved.EnvPrefix, resolved.OutputDir)
+	}
+	ds := resolved.Datastores[0]
+	if ds.Tag != "16-alpine" || ds.ShmSize == "" {
+		t.Errorf("Datastore not resolved: tag %q, shm_size %q", ds.Tag, ds.ShmSize)
+	}
+	if project.Datastores[0].Tag != "14" || project.OutputDir != "" {
+		t.Error("Resolve should not modify the project")
+	}
+
+	project.Datastores[0].Version = "ancient"
+	if _, err := New(project).Resolve(); err == nil {
+		t.Error("An unknown version should fail resolution")
+	}
+}
+
+func TestPortRanges(t *testing.T) {
+	project := &models.Project{
+		Name:       "ranges",
+		Datastores: []models.Datastore{{Type: models.DatastoreRedis, Name: "redis", Port: 6379, PortRanges: []string{"7000-7005"}}},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "media", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "media", PortRanges: []string{"10000-10100:20000-20100/udp"}},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if ports := strings.Join(gen.compose.Services["redis"].Ports, " "); !strings.Contains(ports, "7000-7005:7000-7005") {
+		t.Errorf("redis ports = %s, want the range published", ports)
+	}
+	if ports := strings.Join(gen.compose.Services["media"].Ports, " "); ports != "8080:8080 10000-10100:20000-20100/udp" {
+		t.Errorf("media ports = %s", ports)
+	}
+
+	project.Runtimes[0].PortRanges = []string{"10000-10100:20000-20050"}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Ranges of different length should fail generation")
+	}
+}
+
+// envFilePaths joins the paths of an env_file list with commas
+func envFilePaths(files []models.ComposeEnvFile) string {
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return strings.Join(paths, ",")
+}
+
+func TestRuntimeEnvFiles(t *testing.T) {
+	project := &models.Project{
+		Name: "envfiles",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web", EnvFiles: []string{".env", ".env.local"}},
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if files := envFilePaths(gen.compose.Services["web"].EnvFile); files != ".env,.env.local" {
+		t.Errorf("web env_file = %s, want .env,.env.local in order", files)
+	}
+	if !strings.Contains(output.ComposeYAML, "- .env\n      - path: .env.local\n        required: false\n") {
+		t.Errorf("Extra env files should be optional:\n%s", output.ComposeYAML)
+	}
+	if files := envFilePaths(gen.compose.Services["api"].EnvFile); files != ".env" {
+		t.Errorf("api env_file = %s, want the default .env", files)
+	}
+
+	gen = New(project).WithOptions(Options{InlineEnv: true})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if files := envFilePaths(gen.compose.Services["web"].EnvFile); files != ".env.local" {
+		t.Errorf("--inline-env should keep extra env files, got %s", files)
+	}
+	if gen.compose.Services["api"].EnvFile != nil {
+		t.Error("--inline-env should drop env_file when only .env was loaded")
+	}
+	if project.Runtimes[0].EnvFiles[0] != ".env" {
+		t.Error("Generate should not modify the runtime's env_files")
+	}
+}
+
+func TestRedisPersistence(t *testing.T) {
+	project := &models.Project{
+		Name: "persist",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+			{Type: models.DatastoreRedis, Name: "cache", Port: 6380, Persistence: models.PersistenceNone},
+			{Type: models.DatastoreRedisStack, Name: "stack", Port: 6381, Persistence: models.PersistenceRDB},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if cmd := gen.compose.Services["redis"].Command; cmd != "redis-server --appendonly yes --requirepass ${REDIS_PASSWORD}" {
+		t.Errorf("Default redis command changed: %v", cmd)
+	}
+	cache := gen.compose.Services["cache"]
+	if !strings.Contains(cache.Command, `--save "" --appendonly no`) {
+		t.Errorf("cache command = %v, want persistence disabled", cache.Command)
+	}
+	if len(cache.Volumes) != 0 || strings.Join(cache.Tmpfs, ",") != "/data" {
+		t.Errorf("cache should keep /data on tmpfs, got volumes %v tmpfs %v", cache.Volumes, cache.Tmpfs)
+	}
+	if _, ok := gen.compose.Volumes["cache-data"]; ok {
+		t.Error("No volume should be declared for persistence none")
+	}
+	if args := gen.compose.Services["stack"].Environment["REDIS_ARGS"]; !strings.HasSuffix(args, "--include /etc/redis/persistence.conf") {
+		t.Errorf("stack REDIS_ARGS = %q, want the persistence config included", args)
+	}
+
+	project.Datastores[2].Persistence = models.PersistenceAOF
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if conf := output.ConfigFiles["stack/persistence.conf"]; !strings.HasSuffix(conf, "\nappendonly yes\nsave \"\"\n") {
+		t.Errorf("stack persistence.conf should disable snapshots without an empty REDIS_ARGS argument, got:\n%s", conf)
+	}
+	if !strings.Contains(output.ComposeYAML, "./stack/persistence.conf:/etc/redis/persistence.conf:ro") {
+		t.Error("stack should mount its persistence config")
+	}
+
+	project.Datastores = []models.Datastore{{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Persistence: models.PersistenceAOF}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Persistence on postgres should fail generation")
+	}
+}
+
+func TestNodePackageManager(t *testing.T) {
+	project := &models.Project{
+		Name: "pm",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Framework: "nextjs", Port: 3000, InternalPort: 3000, BuildContext: "web", PackageManager: "pnpm"},
+			{Type: models.RuntimeNode, Name: "api", Framework: "nestjs", Port: 3001, InternalPort: 3000, BuildContext: "api", PackageManager: "yarn"},
+			{Type: models.RuntimeNode, Name: "app", Framework: "express", Port: 3002, InternalPort: 3000, BuildContext: "app"},
+		},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	web := output.Dockerfiles["web"]
+	if !strings.Contains(web, "corepack enable") || !strings.Contains(web, "pnpm install --frozen-lockfile") {
+		t.Errorf("pnpm Dockerfile should enable corepack and install from pnpm-lock.yaml:\n%s", web)
+	}
+	if api := output.Dockerfiles["api"]; !strings.Contains(api, "COPY package.json yarn.lock ./") || strings.Contains(api, "npm ci") {
+		t.Errorf("yarn Dockerfile should install with yarn:\n%s", api)
+	}
+	if app := output.Dockerfiles["app"]; !strings.Contains(app, "npm ci") {
+		t.Errorf("Runtimes without package_manager should keep using npm:\n%s", app)
+	}
+
+	project.Runtimes = []models.Runtime{{Type: models.RuntimeGo, Name: "go-app", Port: 8080, PackageManager: "pnpm"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("package_manager on a Go runtime should fail generation")
+	}
+	project.Runtimes = []models.Runtime{{Type: models.RuntimeNode, Name: "web", Port: 3000, PackageManager: "bun"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Unknown package managers should fail generation")
+	}
+}
+
+func TestPythonDepTool(t *testing.T) {
+	project := &models.Project{
+		Name: "deptool",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimePython, Name: "api", Framework: "fastapi", Port: 8000, InternalPort: 8000, BuildContext: "api", DepTool: "uv"},
+			{Type: models.RuntimePython, Name: "web", Framework: "django", Port: 8001, InternalPort: 8000, BuildContext: "web", DepTool: "poetry"},
+			{Type: models.RuntimePython, Name: "app", Framework: "fastapi", Port: 8002, InternalPort: 8000, BuildContext: "app"},
+		},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if api := output.Dockerfiles["api"]; !strings.Contains(api, "COPY pyproject.toml uv.lock ./") || !strings.Contains(api, "uv sync --frozen") {
+		t.Errorf("uv Dockerfile should install from uv.lock:\n%s", api)
+	}
+	if web := output.Dockerfiles["web"]; !strings.Contains(web, "COPY pyproject.toml poetry.lock ./") || strings.Contains(web, "requirements.txt") {
+		t.Errorf("poetry Dockerfile should install from poetry.lock:\n%s", web)
+	}
+	if app := output.Dockerfiles["app"]; !strings.Contains(app, "pip install --no-cache-dir -r requirements.txt") {
+		t.Errorf("Runtimes without dep_tool should keep using requirements.txt:\n%s", app)
+	}
+
+	project.Runtimes = []models.Runtime{{Type: models.RuntimeNode, Name: "web", Port: 3000, DepTool: "uv"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("dep_tool on a Node runtime should fail generation")
+	}
+	project.Runtimes = []models.Runtime{{Type: models.RuntimePython, Name: "api", Port: 8000, DepTool: "conda"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("Unknown dependency tools should fail generation")
+	}
+}
+
+func TestMySQLCharset(t *testing.T) {
+	project := &models.Project{
+		Name:     "charset",
+		Timezone: "Europe/Berlin",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, Charset: "utf8mb4", Collation: "utf8mb4_unicode_ci"},
+			{Type: models.DatastoreMySQL, Name: "legacy", Port: 3307},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	want := "--character-set-server=utf8mb4 --collation-server=utf8mb4_unicode_ci --default-time-zone=Europe/Berlin"
+	if cmd := gen.compose.Services["mysql"].Command; cmd != want {
+		t.Errorf("mysql command = %q, want %q", cmd, want)
+	}
+	if cmd := gen.compose.Services["legacy"].Command; cmd != "--default-time-zone=Europe/Berlin" {
+		t.Errorf("MySQL without charset should keep its command, got %q", cmd)
+	}
+
+	project.Datastores = []models.Datastore{{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, Charset: "latin1", Collation: "utf8mb4_bin"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("A collation of another character set should fail generation")
+	}
+	project.Datastores = []models.Datastore{{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Charset: "utf8mb4"}}
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("charset on postgres should fail generation")
+	}
+}
+
+func TestComposeSpec(t *testing.T) {
+	swappiness := 0
+	project := &models.Project{
+		Name:     "spec",
+		Jaeger:   true,
+		Timezone: "UTC",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1, Tuning: map[string]string{"max_connections": "200"}, Databases: []string{"billing"}, MetricsPort: 9187},
+			{Type: models.DatastoreMySQL, Name: "mysql", Port: 3306, Charset: "utf8mb4", Collation: "utf8mb4_unicode_ci", ReadOnlyRootFS: true},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, Persistence: models.PersistenceNone, PortRanges: []string{"7000-7002"}, MemSwappiness: &swappiness},
+			{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 7474, InternalNetwork: true},
+			{Type: models.DatastoreMSSQL, Name: "mssql", Port: 1433, CapAdd: []string{"SYS_PTRACE"}, ShmSize: "512m"},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "gin", Port: 8080, InternalPort: 8080, BuildContext: "api", Replicas: 2},
+			{Type: models.RuntimeNode, Name: "web", Framework: "nextjs", Port: 3000, InternalPort: 3000, BuildContext: "web", PortMode: models.PortModeRange, EnvFiles: []string{".env", ".env.local"}},
+			{Type: models.RuntimePython, Name: "ml", Framework: "fastapi", Port: 8000, InternalPort: 8000, BuildContext: "ml", DepTool: "uv"},
+		},
+	}
+
+	for name, opts := range map[string]Options{
+		"default": {},
+		"minimal": {Minimal: true, InlineEnv: true, WatchSync: true},
+		"split":   {Split: true, EnvPrefix: "APP_", ExternalNetwork: "shared"},
+	} {
+		output, err := New(project).WithOptions(opts).Generate()
+		if err != nil {
+			t.Fatalf("%s: Generate failed: %v", name, err)
+		}
+		files := map[string]string{"docker-compose.yml": output.ComposeYAML}
+		for file, content := range output.ComposeFiles {
+			files[file] = content
+		}
+		if err := composespec.Validate(files); err != nil {
+			t.Errorf("%s: generated compose does not match the spec:\n%v", name, err)
+		}
+	}
+}
+
+func TestComposeFileName(t *testing.T) {
+	project := &models.Project{
+		Name:       "filename",
+		Datastores: []models.Datastore{{Type: models.DatastoreRedis, Name: "redis", Port: 6379}},
+	}
+	dir := t.TempDir()
+	output, err := New(project).WithOptions(Options{ComposeFileName: "compose.yaml"}).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if _, err := output.WriteToDir(dir, false); err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DefaultComposeFileName)); err == nil {
+		t.Error("docker-compose.yml should not be written when compose.yaml is chosen")
+	}
+	if got := FindComposeFile(dir); got != "compose.yaml" {
+		t.Errorf("FindComposeFile = %q, want compose.yaml", got)
+	}
+	if err := os.WriteFile(filepath.Join(dir, DefaultComposeFileName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindComposeFile(dir); got != "compose.yaml" {
+		t.Errorf("FindComposeFile should prefer compose.yaml like docker compose, got %q", got)
+	}
+	if got := FindComposeFile(t.TempDir()); got != "" {
+		t.Errorf("FindComposeFile in an empty dir = %q, want none", got)
+	}
+
+	if _, err := New(project).WithOptions(Options{ComposeFileName: "../compose.yaml"}).Generate(); err == nil {
+		t.Error("A compose file name with a directory should fail generation")
+	}
+}
+
+func TestDatastoreVolumes(t *testing.T) {
+	project := &models.Project{
+		Name: "volumes",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 2},
+			{Type: models.DatastoreNeo4j, Name: "graph", Port: 7474},
+			{Type: models.DatastoreRedis, Name: "cache", Port: 6379, Persistence: models.PersistenceNone},
+			{Type: models.DatastoreSQLite, Name: "sqlite"},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	var volumes []string
+	for _, ds := range project.Datastores {
+		volumes = append(volumes, DatastoreVolumes(ds)...)
+	}
+	want := []string{"postgres-data", "postgres-replica-1-data", "postgres-replica-2-data", "graph-data", "graph-logs"}
+	if strings.Join(volumes, ",") != strings.Join(want, ",") {
+		t.Errorf("DatastoreVolumes = %v, want %v", volumes, want)
+	}
+	if len(gen.compose.Volumes) != len(want) {
+		t.Errorf("compose declares %d volumes, want %d: %v", len(gen.compose.Volumes), len(want), gen.compose.Volumes)
+	}
+	for _, name := range want {
+		if _, ok := gen.compose.Volumes[name]; !ok {
+			t.Errorf("compose does not declare %s", name)
+		}
+	}
+}
+
+func TestTestEnvFile(t *testing.T) {
+	project := &models.Project{Name: "shop"}
+	envs := []models.EnvVar{
+		{Key: "POSTGRES_DB", Value: "shop", Description: "PostgreSQL database name"},
+		{Key: "DATABASE_URL", Value: "postgresql://postgres:pw@postgres:5432/shop"},
+		{Key: "APP_MYSQL_DATABASE", Value: "shop"},
+		{Key: "MYSQL_URL", Value: "mysql://app:pw@mysql:3306/shop?parseTime=true"},
+		{Key: "ANALYTICS_DATABASE_URL", Value: "postgresql://postgres:pw@postgres:5432/analytics"},
+		{Key: "REDIS_URL", Value: "redis://:pw@redis:6379"},
+		{Key: "SHOP_NAME", Value: "shop"},
+	}
+	env := TestEnvFile(project, envs)
+	for _, want := range []string{
+		"# PostgreSQL database name\nPOSTGRES_DB=shop_test\n",
+		"DATABASE_URL=postgresql://postgres:pw@postgres:5432/shop_test\n",
+		"APP_MYSQL_DATABASE=shop_test\n",
+		"MYSQL_URL=mysql://app:pw@mysql:3306/shop_test?parseTime=true\n",
+		"ANALYTICS_DATABASE_URL=postgresql://postgres:pw@postgres:5432/analytics\n",
+		"REDIS_URL=redis://:pw@redis:6379\n",
+		"SHOP_NAME=shop\n",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf(".env.test missing %q:\n%s", want, env)
+		}
+	}
+
+	env = TestEnvFile(project, []models.EnvVar{{Key: "DATABASE_URL", Value: "sqlite:///data/app.db"}})
+	if !strings.Contains(env, "DATABASE_URL=sqlite:///data/app_test.db\n") {
+		t.Errorf("SQLite file should get a _test suffix:\n%s", env)
+	}
+}
+
+func TestPullPolicy(t *testing.T) {
+	project := &models.Project{
+		Name:       "offline",
+		PullPolicy: "never",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+	gen := New(project)
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	for _, name := range []string{"postgres", "postgres-replica-1", "redis"} {
+		if policy := gen.compose.Services[name].PullPolicy; policy != "never" {
+			t.Errorf("%s pull_policy = %q, want never", name, policy)
+		}
+	}
+	if policy := gen.compose.Services["api"].PullPolicy; policy != "" {
+		t.Errorf("Runtimes are built and should have no pull_policy, got %q", policy)
+	}
+
+	gen = New(project).WithOptions(Options{PullPolicy: "always"})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if policy := gen.compose.Services["redis"].PullPolicy; policy != "always" {
+		t.Errorf("Option should override the project's pull_policy, got %q", policy)
+	}
+
+	project.PullPolicy = "sometimes"
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("An unknown pull policy should fail generation")
+	}
+}
+
+func TestPortConflicts(t *testing.T) {
+	project := &models.Project{
+		Name: "clash",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1},
+			{Type: models.DatastoreRedis, Name: "redis", Port: 5433},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api"},
+		},
+	}
+	_, err := New(project).Generate()
+	if err == nil || !strings.Contains(err.Error(), "host port 5433 is published by both postgres-replica-1 and redis") {
+		t.Fatalf("Generate should report the replica colliding with redis, got %v", err)
+	}
+
+	// Ephemeral ports, UDP and ranges only clash on the same port and protocol
+	project.Datastores[1].Port = 0
+	project.Runtimes[0].PortRanges = []string{"5432-5433/udp"}
+	if _, err := New(project).Generate(); err != nil {
+		t.Errorf("Generate failed without conflicts: %v", err)
+	}
+	project.Runtimes[0].PortRanges = []string{"5430-5440:5430-5440"}
+	if _, err := New(project).Generate(); err == nil || !strings.Contains(err.Error(), "host port 5432 is published by both api and postgres") {
+		t.Errorf("A range over the postgres port should be reported, got %v", err)
+	}
+}
+
+func TestHostPorts(t *testing.T) {
+	for spec, want := range map[string]string{
+		"5432:5432":             "[{ 5432 tcp db}]",
+		"127.0.0.1:8080:80":     "[{127.0.0.1 8080 tcp db}]",
+		"0.0.0.0:8080:80/udp":   "[{ 8080 udp db}]",
+		"3000-3001:3000":        "[{ 3000 tcp db} { 3001 tcp db}]",
+		"5432":                  "[]",
+		"${DB_PORT:-5432}:5432": "[]",
+	} {
+		if got := fmt.Sprint(hostPorts("db", spec)); got != want {
+			t.Errorf("hostPorts(%q) = %s, want %s", spec, got, want)
+		}
+	}
+}
+
+func TestDebugPort(t *testing.T) {
+	project := &models.Project{
+		Name: "debug",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api", DebugPort: 2345},
+			{Type: models.RuntimeNode, Name: "web", Framework: "express", Port: 3000, InternalPort: 3000, BuildContext: "web", DebugPort: 9229},
+			{Type: models.RuntimePython, Name: "worker", Framework: "fastapi", Port: 8000, InternalPort: 8000, BuildContext: "worker", DebugPort: 5678},
+			{Type: models.RuntimeJava, Name: "billing", Framework: "spring-boot", Port: 8081, InternalPort: 8080, BuildContext: "billing", DebugPort: 15005},
+		},
+	}
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	api := gen.compose.Services["api"]
+	if !slices.Contains(api.Ports, "2345:2345") || !slices.Contains(api.CapAdd, "SYS_PTRACE") {
+		t.Errorf("Go debugger should publish 2345 with SYS_PTRACE, got ports %v cap_add %v", api.Ports, api.CapAdd)
+	}
+	if dockerfile := output.Dockerfiles["api"]; !strings.Contains(dockerfile, "dlv") || !strings.Contains(dockerfile, `-gcflags="all=-N -l"`) {
+		t.Errorf("Go Dockerfile should build without optimizations and run dlv:\n%s", dockerfile)
+	}
+
+	web := gen.compose.Services["web"]
+	if !slices.Contains(web.Ports, "9229:9229") || !strings.Contains(web.Command, "--inspect=0.0.0.0:9229") {
+		t.Errorf("Node debugger not enabled, got ports %v command %q", web.Ports, web.Command)
+	}
+
+	worker := gen.compose.Services["worker"]
+	if !strings.Contains(output.Dockerfiles["worker"], "debugpy") || !strings.Contains(worker.Command, "debugpy --listen 0.0.0.0:5678") {
+		t.Errorf("Python debugger not enabled, got command %q", worker.Command)
+	}
+
+	billing := gen.compose.Services["billing"]
+	if !slices.Contains(billing.Ports, "15005:5005") || !strings.Contains(billing.Environment["JAVA_TOOL_OPTIONS"], "address=*:5005") {
+		t.Errorf("Java debugger not enabled, got ports %v env %v", billing.Ports, billing.Environment)
+	}
+
+	rust := &models.Project{Name: "debug", Runtimes: []models.Runtime{
+		{Type: models.RuntimeRust, Name: "svc", Framework: "axum", Port: 8080, InternalPort: 8080, BuildContext: "svc", DebugPort: 9000},
+	}}
+	if _, err := New(rust).Generate(); err == nil {
+		t.Error("debug_port on a Rust runtime should fail generation")
+	}
+
+	project.Runtimes[1].PortMode = models.PortModeRange
+	if _, err := New(project).Generate(); err == nil {
+		t.Error("debug_port with --port-mode range should fail generation")
+	}
+}
+
+func TestDependsOnHealthy(t *testing.T) {
+	project := &models.Project{
+		Name:   "waits",
+		Jaeger: true,
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Framework: "stdlib", Port: 8080, InternalPort: 8080, BuildContext: "api", DependsOn: []string{"postgres"}},
+		},
+	}
+	gen := New(project)
+	output, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	api := gen.compose.Services["api"]
+	if api.DependsOn["postgres"].Condition != models.ConditionHealthy {
+		t.Errorf("api should wait for postgres to be healthy, got %+v", api.DependsOn)
+	}
+	// The Jaeger service has no healthcheck
+	if api.DependsOn[JaegerServiceName].Condition != models.ConditionStarted {
+		t.Errorf("api should wait for jaeger to start, got %+v", api.DependsOn)
+	}
+	if !strings.Contains(output.ComposeYAML, "      postgres:\n        condition: service_healthy\n") {
+		t.Errorf("compose file should use the long depends_on form:\n%s", output.ComposeYAML)
+	}
+
+	gen = New(project).WithOptions(Options{Minimal: true})
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if condition := gen.compose.Services["api"].DependsOn["postgres"].Condition; condition != models.ConditionStarted {
+		t.Errorf("--minimal drops healthchecks, so api should wait for postgres to start, got %q", condition)
+	}
+}
+
+func TestMergeCompose(t *testing.T) {
+	project := &models.Project{
+		Name: "layered",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+			{Type: models.DatastoreRedis, Name: "redis", Tag: "7-alpine", Port: 6379},
+		},
+	}
+	output, err := New(project).Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	existing := `name: team
+x-defaults: &defaults
+  restart: always
+services:
+  # Maintained by hand
+  legacy:
+    image: legacy:1.0
+    <<: *defaults
+  redis:
+    image: redis:6 # pinned
+    labels:
+      team: platform
+secrets:
+  token:
+    file: ./token.txt
+`
+	report, err := output.MergeCompose("compose.yaml", []byte(existing))
+	if err != nil {
+		t.Fatalf("MergeCompose failed: %v", err)
+	}
+
+	merged := output.ComposeYAML
+	for _, want := range []string{
+		"name: team\n",
+		"x-defaults: &defaults\n",
+		"  # Maintained by hand\n  legacy:\n    image: legacy:1.0\n    <<: *defaults\n",
+		"secrets:\n  token:\n",
+		"    labels:\n      team: platform\n",
+		"  postgres:\n",
+		"  postgres-data: {}\n",
+	} {
+		if !strings.Contains(merged, want) {
+			t.Errorf("merged compose file should contain %q:\n%s", want, merged)
+		}
+	}
+	if !strings.Contains(merged, "image: redis:7-alpine # pinned") {
+		t.Errorf("generated values should replace the file's, keeping comments:\n%s", merged)
+	}
+	if strings.Contains(merged, "!!merge") {
+		t.Errorf("merge keys should be written as plain <<:\n%s", merged)
+	}
+
+	if !slices.Contains(report.Added, "services.postgres") || !slices.Contains(report.Added, "volumes.postgres-data") {
+		t.Errorf("postgres and its volume should be reported as added, got %v", report.Added)
+	}
+	if !slices.Equal(report.Updated, []string{"services.redis"}) {
+		t.Errorf("Updated = %v, want [services.redis]", report.Updated)
+	}
+	for _, kept := range []string{"services.legacy", "name", "x-defaults", "secrets"} {
+		if !slices.Contains(report.Kept, kept) {
+			t.Errorf("%s should be reported as kept, got %v", kept, report.Kept)
+		}
+	}
+
+	files, err := output.WriteToDir(t.TempDir(), true)
+	if err != nil {
+		t.Fatalf("WriteToDir failed: %v", err)
+	}
+	if !slices.Contains(files, FileAction{Path: "compose.yaml", Action: ActionMerge}) {
+		t.Errorf("the merged compose file should be reported as a merge, got %v", files)
+	}
+
+	if _, err := output.MergeCompose("list.yaml", []byte("- not a compose file\n")); err == nil {
+		t.Error("MergeCompose should reject a file that is not a mapping")
+	}
+}
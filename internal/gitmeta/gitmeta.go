@@ -0,0 +1,74 @@
+// Package gitmeta reads repository metadata for OCI image labels
+package gitmeta
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// OCI annotation keys, see
+// https://github.com/opencontainers/image-spec/blob/main/annotations.md
+const (
+	LabelSource   = "org.opencontainers.image.source"
+	LabelRevision = "org.opencontainers.image.revision"
+)
+
+// Labels returns OCI source and revision labels for the git repository
+// containing dir. The source is left out when there is no origin remote.
+func Labels(dir string) (map[string]string, error) {
+	revision, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository with commits: %w", dir, err)
+	}
+	labels := map[string]string{LabelRevision: revision}
+	if remote, err := git(dir, "config", "--get", "remote.origin.url"); err == nil && remote != "" {
+		labels[LabelSource] = SourceURL(remote)
+	}
+	return labels, nil
+}
+
+// Root returns the top-level directory of the git repository containing
+// dir
+func Root(dir string) (string, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	return root, nil
+}
+
+// SourceURL turns a git remote into a browsable URL. ssh:// and
+// scp-style (git@host:owner/repo) remotes become https, credentials and the
+// .git suffix are dropped, and local paths are returned unchanged.
+func SourceURL(remote string) string {
+	url := strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+
+	var scheme, host, path string
+	if s, rest, ok := strings.Cut(url, "://"); ok {
+		scheme = s
+		host, path, _ = strings.Cut(rest, "/")
+	} else if h, p, ok := strings.Cut(url, ":"); ok && !strings.Contains(h, "/") {
+		scheme, host, path = "ssh", h, p
+	} else {
+		return url
+	}
+
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if scheme != "http" && scheme != "https" {
+		// An ssh port does not apply to the web URL
+		host, _, _ = strings.Cut(host, ":")
+		scheme = "https"
+	}
+	return scheme + "://" + host + "/" + path
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,246 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/stackgen-cli/stackgen/internal/detect"
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Result is a project recovered from an existing compose file
+type Result struct {
+	Project *models.Project
+	// Unmapped lists services stackgen does not recognize
+	Unmapped []string
+}
+
+// composeFile is a lenient view of a compose file; fields with several
+// allowed shapes are decoded as yaml nodes
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image     string      `yaml:"image"`
+	Build     yaml.Node   `yaml:"build"`
+	Ports     []yaml.Node `yaml:"ports"`
+	DependsOn yaml.Node   `yaml:"depends_on"`
+}
+
+// datastoreImages maps image repository names to datastores, checked in
+// order so redis-stack wins over redis
+var datastoreImages = []struct {
+	repo string
+	ds   models.DatastoreType
+}{
+	{"redis/redis-stack", models.DatastoreRedisStack},
+	{"redis/redis-stack-server", models.DatastoreRedisStack},
+	{"mcr.microsoft.com/mssql/server", models.DatastoreMSSQL},
+	{"postgres", models.DatastorePostgres},
+	{"mysql", models.DatastoreMySQL},
+	{"neo4j", models.DatastoreNeo4j},
+	{"redis", models.DatastoreRedis},
+}
+
+// jaegerImage is the tracing backend stackgen generates
+const jaegerImage = "jaegertracing/all-in-one"
+
+// FromComposeFile builds a project from a compose file. Datastores are
+// recognized by image, runtimes by detecting the language in their build
+// context. Build contexts are rewritten relative to outputDir.
+func FromComposeFile(path, name, outputDir string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	result := &Result{Project: &models.Project{Name: name, OutputDir: outputDir}}
+	composeDir := filepath.Dir(path)
+
+	names := make([]string, 0, len(compose.Services))
+	for n := range compose.Services {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, serviceName := range names {
+		service := compose.Services[serviceName]
+		repo, tag, digest := splitImage(service.Image)
+
+		if repo == jaegerImage {
+			result.Project.Jaeger = true
+			continue
+		}
+		if dsType, ok := datastoreType(repo); ok {
+			result.Project.Datastores = append(result.Project.Datastores, importDatastore(serviceName, dsType, tag, digest, service.Ports))
+			continue
+		}
+		if context, dockerfile := buildContext(service.Build); context != "" {
+			rt, ok := importRuntime(serviceName, filepath.Join(composeDir, context), outputDir, service.Ports)
+			if ok {
+				rt.Dockerfile = dockerfile
+				rt.DependsOn = dependsOn(service.DependsOn)
+				result.Project.Runtimes = append(result.Project.Runtimes, rt)
+				continue
+			}
+		}
+		result.Unmapped = append(result.Unmapped, serviceName)
+	}
+	return result, nil
+}
+
+func importDatastore(name string, dsType models.DatastoreType, tag, digest string, ports []yaml.Node) models.Datastore {
+	info := models.GetDatastoreInfo(dsType)
+	if dsType == models.DatastoreNeo4j {
+		tag = strings.TrimSuffix(tag, "-community")
+	}
+	ds := models.Datastore{
+		Type:         dsType,
+		Name:         name,
+		Tag:          tag,
+		Digest:       digest,
+		Port:         info.DefaultPort,
+		InternalPort: info.DefaultPort,
+	}
+	if host, container, ok := firstPort(ports); ok {
+		ds.Port, ds.InternalPort = host, container
+	} else {
+		expose := false
+		ds.Expose = &expose
+	}
+	return ds
+}
+
+func importRuntime(name, contextDir, outputDir string, ports []yaml.Node) (models.Runtime, bool) {
+	matches, err := detect.Runtimes(contextDir)
+	if err != nil || len(matches) != 1 {
+		return models.Runtime{}, false
+	}
+	info := models.GetRuntimeInfo(matches[0].Type)
+
+	context := contextDir
+	if rel, err := filepath.Rel(outputDir, contextDir); err == nil {
+		context = filepath.ToSlash(rel)
+	}
+	rt := models.Runtime{
+		Type:         matches[0].Type,
+		Name:         name,
+		Framework:    matches[0].Framework,
+		Port:         info.DefaultPort,
+		InternalPort: info.DefaultPort,
+		BuildContext: context,
+	}
+	if host, container, ok := firstPort(ports); ok {
+		rt.Port, rt.InternalPort = host, container
+	}
+	return rt, true
+}
+
+// splitImage splits an image reference into repository, tag and digest,
+// dropping Docker Hub prefixes so official images compare by short name
+func splitImage(image string) (repo, tag, digest string) {
+	repo = image
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, digest = repo[:i], repo[i+1:]
+	}
+	// A colon after the last slash separates the tag; earlier ones are ports
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, tag = repo[:i], repo[i+1:]
+	}
+	repo = strings.TrimPrefix(repo, "docker.io/")
+	repo = strings.TrimPrefix(repo, "library/")
+	if tag == "" {
+		tag = "latest"
+	}
+	return repo, tag, digest
+}
+
+func datastoreType(repo string) (models.DatastoreType, bool) {
+	for _, img := range datastoreImages {
+		if repo == img.repo {
+			return img.ds, true
+		}
+	}
+	return "", false
+}
+
+// buildContext returns the context and Dockerfile of a build given as a
+// string or as a mapping with context and dockerfile keys. The Dockerfile
+// defaults to compose's "Dockerfile".
+func buildContext(build yaml.Node) (context, dockerfile string) {
+	switch build.Kind {
+	case yaml.ScalarNode:
+		return build.Value, "Dockerfile"
+	case yaml.MappingNode:
+		var b struct {
+			Context    string `yaml:"context"`
+			Dockerfile string `yaml:"dockerfile"`
+		}
+		if err := build.Decode(&b); err == nil {
+			if b.Context == "" {
+				b.Context = "."
+			}
+			if b.Dockerfile == "" {
+				b.Dockerfile = "Dockerfile"
+			}
+			return b.Context, b.Dockerfile
+		}
+	}
+	return "", ""
+}
+
+// dependsOn returns the services of depends_on in short (list) or long
+// (mapping) syntax
+func dependsOn(node yaml.Node) []string {
+	var names []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		_ = node.Decode(&names)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			names = append(names, node.Content[i].Value)
+		}
+	}
+	return names
+}
+
+// firstPort returns the host and container port of the first published
+// port, in short ("8080:80", "127.0.0.1:8080:80/tcp") or long syntax
+func firstPort(ports []yaml.Node) (host, container int, ok bool) {
+	for _, node := range ports {
+		switch node.Kind {
+		case yaml.ScalarNode:
+			parts := strings.Split(strings.SplitN(node.Value, "/", 2)[0], ":")
+			if len(parts) < 2 {
+				continue
+			}
+			h, err1 := strconv.Atoi(parts[len(parts)-2])
+			c, err2 := strconv.Atoi(parts[len(parts)-1])
+			if err1 == nil && err2 == nil {
+				return h, c, true
+			}
+		case yaml.MappingNode:
+			var p struct {
+				Target    int    `yaml:"target"`
+				Published string `yaml:"published"`
+			}
+			if err := node.Decode(&p); err != nil {
+				continue
+			}
+			if h, err := strconv.Atoi(p.Published); err == nil && p.Target != 0 {
+				return h, p.Target, true
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+func TestFromComposeFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "api"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "api", "go.mod"), []byte("module api\n\nrequire github.com/gin-gonic/gin v1.9.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	compose := `services:
+  db:
+    image: postgres:15-alpine
+    ports:
+      - "5433:5432"
+  cache:
+    image: docker.io/library/redis:7-alpine
+  api:
+    build:
+      context: ./api
+    depends_on: [db]
+    ports:
+      - target: 8080
+        published: "9090"
+  mailhog:
+    image: mailhog/mailhog
+`
+	path := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(path, []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := FromComposeFile(path, "demo", dir)
+	if err != nil {
+		t.Fatalf("FromComposeFile failed: %v", err)
+	}
+	project := result.Project
+
+	if len(project.Datastores) != 2 {
+		t.Fatalf("Expected 2 datastores, got %d", len(project.Datastores))
+	}
+	db := project.Datastores[1]
+	if db.Name != "db" || db.Type != models.DatastorePostgres || db.Tag != "15-alpine" || db.Port != 5433 {
+		t.Errorf("Unexpected postgres datastore: %+v", db)
+	}
+	cache := project.Datastores[0]
+	if cache.Type != models.DatastoreRedis || cache.IsExposed() {
+		t.Errorf("Redis without ports should be imported as internal-only: %+v", cache)
+	}
+
+	if len(project.Runtimes) != 1 {
+		t.Fatalf("Expected 1 runtime, got %d", len(project.Runtimes))
+	}
+	api := project.Runtimes[0]
+	if api.Type != models.RuntimeGo || api.Framework != "gin" || api.Port != 9090 || api.BuildContext != "api" {
+		t.Errorf("Unexpected runtime: %+v", api)
+	}
+	if api.Dockerfile != "Dockerfile" || len(api.DependsOn) != 1 || api.DependsOn[0] != "db" {
+		t.Errorf("The runtime should keep its Dockerfile and depends_on, got %q %v", api.Dockerfile, api.DependsOn)
+	}
+
+	if len(result.Unmapped) != 1 || result.Unmapped[0] != "mailhog" {
+		t.Errorf("Expected mailhog to be unmapped, got %v", result.Unmapped)
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image, repo, tag, digest string
+	}{
+		{"postgres", "postgres", "latest", ""},
+		{"redis/redis-stack:7.2.0-v10", "redis/redis-stack", "7.2.0-v10", ""},
+		{"localhost:5000/app", "localhost:5000/app", "latest", ""},
+		{"mysql:8.0@sha256:abc", "mysql", "8.0", "sha256:abc"},
+	}
+
+	for _, tt := range tests {
+		repo, tag, digest := splitImage(tt.image)
+		if repo != tt.repo || tag != tt.tag || digest != tt.digest {
+			t.Errorf("splitImage(%q) = %q, %q, %q", tt.image, repo, tag, digest)
+		}
+	}
+}
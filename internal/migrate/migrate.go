@@ -0,0 +1,101 @@
+// Package migrate loads stackgen.yaml files written for older schemas,
+// upgrading them to models.SchemaVersion
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// steps[i] upgrades a raw config from schema i+1 to i+2. Steps work on the
+// decoded YAML rather than models.Project so they can rename or reshape
+// fields the current model no longer has.
+var steps = []func(doc map[string]interface{}){
+	fillNames,
+}
+
+// Load parses a stackgen.yaml, upgrading it to the current schema, and
+// returns the schema version it was written with
+func Load(data []byte) (*models.Project, int, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+	if doc == nil {
+		doc = make(map[string]interface{})
+	}
+
+	from := 1
+	if v, ok := doc["schema_version"]; ok {
+		n, ok := v.(int)
+		if !ok || n < 1 {
+			return nil, 0, fmt.Errorf("invalid schema_version %v", v)
+		}
+		from = n
+	}
+	if from > models.SchemaVersion {
+		return nil, 0, fmt.Errorf("config uses schema %d but this stackgen reads up to %d; upgrade stackgen", from, models.SchemaVersion)
+	}
+	for v := from; v < models.SchemaVersion; v++ {
+		steps[v-1](doc)
+	}
+	doc["schema_version"] = models.SchemaVersion
+
+	upgraded, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	var project models.Project
+	if err := yaml.Unmarshal(upgraded, &project); err != nil {
+		return nil, 0, err
+	}
+	return &project, from, nil
+}
+
+// fillNames (schema 1 to 2) fills the service names, internal ports and
+// build contexts that early configs could leave out
+func fillNames(doc map[string]interface{}) {
+	for _, ds := range items(doc, "datastores") {
+		dsType, _ := ds["type"].(string)
+		setDefault(ds, "name", dsType)
+		setDefault(ds, "internal_port", models.GetDatastoreInfo(models.DatastoreType(dsType)).DefaultPort)
+	}
+	for _, rt := range items(doc, "runtimes") {
+		rtType, _ := rt["type"].(string)
+		setDefault(rt, "name", rtType+"-app")
+		setDefault(rt, "internal_port", models.GetRuntimeInfo(models.RuntimeType(rtType)).DefaultPort)
+		setDefault(rt, "build_context", rt["name"])
+	}
+}
+
+// items returns the mappings in the sequence doc[key]
+func items(doc map[string]interface{}, key string) []map[string]interface{} {
+	list, _ := doc[key].([]interface{})
+	var maps []map[string]interface{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+// setDefault sets m[key] when it is missing, empty or zero
+func setDefault(m map[string]interface{}, key string, value interface{}) {
+	switch v := m[key].(type) {
+	case nil:
+	case string:
+		if v != "" {
+			return
+		}
+	case int:
+		if v != 0 {
+			return
+		}
+	default:
+		return
+	}
+	m[key] = value
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+func TestLoadSchema1(t *testing.T) {
+	config := `name: old
+datastores:
+  - type: postgres
+    port: 5433
+runtimes:
+  - type: go
+    port: 8081
+`
+	project, from, err := Load([]byte(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if from != 1 {
+		t.Errorf("A config without schema_version is schema 1, got %d", from)
+	}
+	if project.SchemaVersion != models.SchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", project.SchemaVersion, models.SchemaVersion)
+	}
+	ds := project.Datastores[0]
+	if ds.Name != "postgres" || ds.InternalPort != 5432 || ds.Port != 5433 {
+		t.Errorf("Datastore defaults not filled: %+v", ds)
+	}
+	rt := project.Runtimes[0]
+	if rt.Name != "go-app" || rt.BuildContext != "go-app" || rt.InternalPort != 8080 {
+		t.Errorf("Runtime defaults not filled: %+v", rt)
+	}
+}
+
+func TestLoadCurrent(t *testing.T) {
+	config := `schema_version: 2
+name: current
+runtimes:
+  - type: node
+    name: web
+    build_context: frontend
+`
+	project, from, err := Load([]byte(config))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if from != models.SchemaVersion {
+		t.Errorf("from = %d, want %d", from, models.SchemaVersion)
+	}
+	if project.Runtimes[0].BuildContext != "frontend" {
+		t.Error("Current configs should load unchanged")
+	}
+}
+
+func TestLoadNewer(t *testing.T) {
+	_, _, err := Load([]byte("schema_version: 99\nname: future\n"))
+	if err == nil || !strings.Contains(err.Error(), "upgrade stackgen") {
+		t.Errorf("A newer schema should ask to upgrade stackgen, got %v", err)
+	}
+}
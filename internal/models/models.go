@@ -1,27 +1,128 @@
 package models
 
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion is the stackgen.yaml schema this version writes. Configs
+// without schema_version are schema 1.
+const SchemaVersion = 2
+
 // Project represents the entire generated configuration
 type Project struct {
+	SchemaVersion int `yaml:"schema_version,omitempty"`
+
 	Name       string      `yaml:"name"`
 	OutputDir  string      `yaml:"output_dir"`
 	Datastores []Datastore `yaml:"datastores"`
 	Runtimes   []Runtime   `yaml:"runtimes"`
 	Networks   []Network   `yaml:"networks"`
 	Profile    string      `yaml:"profile,omitempty"`
+	Jaeger     bool        `yaml:"jaeger,omitempty"`     // add a Jaeger all-in-one tracing backend
+	Timezone   string      `yaml:"timezone,omitempty"`   // IANA zone set as TZ on all services
+	Sentry     bool        `yaml:"sentry,omitempty"`     // add a SENTRY_DSN placeholder for runtimes
+	EnvPrefix  string      `yaml:"env_prefix,omitempty"` // prepended to every generated env var key
+
+	// DefaultFrameworks picks the framework for new runtimes without prompting
+	DefaultFrameworks map[RuntimeType]string `yaml:"default_frameworks,omitempty"`
+
+	// Hooks are shell commands run around generate (requires --allow-hooks)
+	Hooks *Hooks `yaml:"hooks,omitempty"`
+
+	// ExternalNetwork joins a pre-existing docker network of that name,
+	// shared with other compose projects, instead of creating <name>-network
+	ExternalNetwork string `yaml:"external_network,omitempty"`
+
+	// PullPolicy is the compose pull_policy of datastore services, e.g.
+	// never to run offline from pre-loaded images
+	PullPolicy string `yaml:"pull_policy,omitempty"`
+}
+
+// Hooks lists shell commands run in the output directory before and after
+// generated files are written
+type Hooks struct {
+	PreGenerate  []string `yaml:"pre_generate,omitempty"`
+	PostGenerate []string `yaml:"post_generate,omitempty"`
 }
 
 // Datastore represents a database or cache service
 type Datastore struct {
-	Type        DatastoreType     `yaml:"type"`
-	Name        string            `yaml:"name"`
-	Image       string            `yaml:"image"`
-	Tag         string            `yaml:"tag"`
-	Port        int               `yaml:"port"`
-	InternalPort int              `yaml:"internal_port"`
-	Volumes     []Volume          `yaml:"volumes"`
-	Environment map[string]string `yaml:"environment"`
-	HealthCheck *HealthCheck      `yaml:"health_check,omitempty"`
-	Networks    []string          `yaml:"networks"`
+	Type            DatastoreType     `yaml:"type"`
+	Name            string            `yaml:"name"`
+	Image           string            `yaml:"image"`
+	Tag             string            `yaml:"tag"`
+	Digest          string            `yaml:"digest,omitempty"` // sha256:... pins the image instead of Tag
+	Port            int               `yaml:"port"`
+	InternalPort    int               `yaml:"internal_port"`
+	Volumes         []Volume          `yaml:"volumes"`
+	Environment     map[string]string `yaml:"environment"`
+	HealthCheck     *HealthCheck      `yaml:"health_check,omitempty"`
+	Networks        []string          `yaml:"networks"`
+	Tuning          map[string]string `yaml:"tuning,omitempty"`      // server settings (postgres: written to postgresql.conf)
+	NoPassword      bool              `yaml:"no_password,omitempty"` // disable auth (insecure, local dev only)
+	Replicas        int               `yaml:"replicas,omitempty"`    // streaming read replicas (postgres only)
+	StopGracePeriod string            `yaml:"stop_grace_period,omitempty"`
+	Init            *bool             `yaml:"init,omitempty"`             // run an init process (tini) as PID 1
+	Expose          *bool             `yaml:"expose,omitempty"`           // publish ports on the host (default true)
+	InternalNetwork bool              `yaml:"internal_network,omitempty"` // only on an internal: true network shared with runtimes
+	// DisableHealthCheck omits the healthcheck, e.g. for image variants
+	// without pg_isready or redis-cli
+	DisableHealthCheck bool `yaml:"disable_health_check,omitempty"`
+	// ReadOnlyRootFS mounts the root filesystem read-only, with tmpfs for
+	// the paths the server writes outside its data volume
+	ReadOnlyRootFS bool `yaml:"read_only_root_fs,omitempty"`
+	// Ulimits overrides the type's required ulimits, as soft[:hard] by name
+	Ulimits map[string]string `yaml:"ulimits,omitempty"`
+	// Linux capabilities and security options, e.g. cap_drop: [ALL]
+	CapAdd      []string `yaml:"cap_add,omitempty"`
+	CapDrop     []string `yaml:"cap_drop,omitempty"`
+	SecurityOpt []string `yaml:"security_opt,omitempty"`
+	ShmSize     string   `yaml:"shm_size,omitempty"` // /dev/shm size, e.g. 256m (default: the type's)
+	// Version is a friendly version (latest, stable, lts or a major
+	// version) resolved to a concrete tag at generation; it overrides Tag
+	Version string `yaml:"version,omitempty"`
+	// SeedFile is a .sql, .sql.gz or .sh fixture file, relative to the
+	// output dir, loaded after the schema on first start (postgres, mysql)
+	SeedFile string `yaml:"seed_file,omitempty"`
+	// Databases are created on first start besides the default one, each
+	// owned by a login role of the same name with DatabaseRoles (postgres)
+	Databases     []string `yaml:"databases,omitempty"`
+	DatabaseRoles bool     `yaml:"database_roles,omitempty"`
+	// Memory-pressure settings, emitted only when set
+	MemSwappiness  *int  `yaml:"mem_swappiness,omitempty"` // 0-100
+	OOMKillDisable *bool `yaml:"oom_kill_disable,omitempty"`
+	// MetricsPort publishes a Prometheus exporter sidecar on this host
+	// port; 0 means no exporter (postgres, mysql, redis)
+	MetricsPort int `yaml:"metrics_port,omitempty"`
+	// PortRanges publishes contiguous port ranges, e.g. 8000-8010:8000-8010
+	PortRanges []string `yaml:"port_ranges,omitempty"`
+	// Persistence is none, rdb, aof or both (redis, redis-stack); empty
+	// keeps the type's default
+	Persistence string `yaml:"persistence,omitempty"`
+	// Charset and Collation set the server character set and collation
+	// (mysql); empty keeps the image default
+	Charset   string `yaml:"charset,omitempty"`
+	Collation string `yaml:"collation,omitempty"`
+}
+
+// HasService reports whether the datastore runs as a compose service.
+// SQLite is a file mounted into the runtimes instead.
+func (d Datastore) HasService() bool {
+	return d.Type != DatastoreSQLite
+}
+
+// IsExposed reports whether the datastore's ports are published on the host
+func (d Datastore) IsExposed() bool {
+	if d.InternalNetwork {
+		return false
+	}
+	return d.Expose == nil || *d.Expose
 }
 
 // DatastoreType enumerates supported datastores
@@ -34,22 +135,244 @@ const (
 	DatastoreNeo4j      DatastoreType = "neo4j"
 	DatastoreRedis      DatastoreType = "redis"
 	DatastoreRedisStack DatastoreType = "redis-stack"
+	DatastoreSQLite     DatastoreType = "sqlite"
 )
 
 // Runtime represents a language/framework container
 type Runtime struct {
-	Type         RuntimeType       `yaml:"type"`
-	Name         string            `yaml:"name"`
-	Framework    string            `yaml:"framework,omitempty"`
-	Port         int               `yaml:"port"`
-	InternalPort int               `yaml:"internal_port"`
-	BuildContext string            `yaml:"build_context"`
-	Dockerfile   string            `yaml:"dockerfile"`
-	Volumes      []Volume          `yaml:"volumes"`
-	Environment  map[string]string `yaml:"environment"`
-	Command      string            `yaml:"command,omitempty"`
-	DependsOn    []string          `yaml:"depends_on"`
-	Networks     []string          `yaml:"networks"`
+	Type            RuntimeType       `yaml:"type"`
+	Name            string            `yaml:"name"`
+	Framework       string            `yaml:"framework,omitempty"`
+	Version         string            `yaml:"version,omitempty"` // toolchain version for the base image, e.g. 1.23
+	Port            int               `yaml:"port"`
+	InternalPort    int               `yaml:"internal_port"`
+	BuildContext    string            `yaml:"build_context"`
+	Dockerfile      string            `yaml:"dockerfile"`
+	Volumes         []Volume          `yaml:"volumes"`
+	Environment     map[string]string `yaml:"environment"`
+	Command         string            `yaml:"command,omitempty"`
+	DependsOn       []string          `yaml:"depends_on"`
+	Networks        []string          `yaml:"networks"`
+	StopGracePeriod string            `yaml:"stop_grace_period,omitempty"`
+	Init            *bool             `yaml:"init,omitempty"`       // run an init process (tini) as PID 1
+	PortMode        string            `yaml:"port_mode,omitempty"`  // host (default), none or range
+	PortRange       int               `yaml:"port_range,omitempty"` // host ports published in range mode
+	Replicas        int               `yaml:"replicas,omitempty"`   // replicas load-balanced by a generated nginx proxy
+	// HealthCheck adds a healthcheck to the service; without one, none is generated
+	HealthCheck *RuntimeHealthCheck `yaml:"health_check,omitempty"`
+	// Memory-pressure settings, emitted only when set
+	MemSwappiness  *int  `yaml:"mem_swappiness,omitempty"` // 0-100
+	OOMKillDisable *bool `yaml:"oom_kill_disable,omitempty"`
+	// PortRanges publishes contiguous port ranges besides Port, e.g. for
+	// FTP passive mode or WebRTC media ports
+	PortRanges []string `yaml:"port_ranges,omitempty"`
+	// EnvFiles replaces the default env_file [.env], in order; later files
+	// override earlier ones, e.g. [.env, .env.local]
+	EnvFiles []string `yaml:"env_files,omitempty"`
+	// PackageManager is npm (default), pnpm or yarn (node only)
+	PackageManager string `yaml:"package_manager,omitempty"`
+	// DepTool is pip (default, requirements.txt), poetry, pipenv or uv
+	// (python only)
+	DepTool string `yaml:"dep_tool,omitempty"`
+	// DebugPort publishes the runtime's debugger (see RuntimeInfo.DebugPort)
+	// on this host port and starts the app with it enabled; 0 disables it
+	DebugPort int `yaml:"debug_port,omitempty"`
+}
+
+// RuntimeHealthCheck configures a runtime's healthcheck. Cmd replaces the
+// default HTTP probe of Path, e.g. for gRPC or TCP health checks.
+type RuntimeHealthCheck struct {
+	Cmd      string `yaml:"cmd,omitempty"`      // shell command run in the container
+	Path     string `yaml:"path,omitempty"`     // HTTP path probed when Cmd is empty (default /health)
+	Interval string `yaml:"interval,omitempty"` // time between checks (default 10s)
+}
+
+// ValidateMemSwappiness checks a mem_swappiness value, allowing nil for
+// the Docker default
+func ValidateMemSwappiness(value *int) error {
+	if value != nil && (*value < 0 || *value > 100) {
+		return fmt.Errorf("mem_swappiness %d is out of range 0-100", *value)
+	}
+	return nil
+}
+
+// PortRange is a contiguous range of host ports published to an equally
+// long range of container ports
+type PortRange struct {
+	HostStart, HostEnd           int
+	ContainerStart, ContainerEnd int
+	Protocol                     string // tcp, udp or empty for compose's default
+}
+
+// ParsePortRange parses a range mapping in compose syntax, start-end or
+// start-end:start-end with an optional /tcp or /udp suffix
+func ParsePortRange(spec string) (PortRange, error) {
+	var r PortRange
+	mapping, proto, hasProto := strings.Cut(spec, "/")
+	if hasProto {
+		if proto != "tcp" && proto != "udp" {
+			return r, fmt.Errorf("port range %q: protocol must be tcp or udp", spec)
+		}
+		r.Protocol = proto
+	}
+	host, container, mapped := strings.Cut(mapping, ":")
+	if !mapped {
+		container = host
+	}
+	var err error
+	if r.HostStart, r.HostEnd, err = parseRangeSide(host); err != nil {
+		return r, fmt.Errorf("port range %q: %w", spec, err)
+	}
+	if r.ContainerStart, r.ContainerEnd, err = parseRangeSide(container); err != nil {
+		return r, fmt.Errorf("port range %q: %w", spec, err)
+	}
+	if r.HostEnd-r.HostStart != r.ContainerEnd-r.ContainerStart {
+		return r, fmt.Errorf("port range %q: host and container ranges differ in length", spec)
+	}
+	return r, nil
+}
+
+// parseRangeSide parses one start-end side of a port range
+func parseRangeSide(side string) (start, end int, err error) {
+	from, to, ok := strings.Cut(side, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected start-end, got %q", side)
+	}
+	if start, err = strconv.Atoi(from); err != nil {
+		return 0, 0, fmt.Errorf("invalid port %q", from)
+	}
+	if end, err = strconv.Atoi(to); err != nil {
+		return 0, 0, fmt.Errorf("invalid port %q", to)
+	}
+	if start < 1 || end > 65535 {
+		return 0, 0, fmt.Errorf("ports must be between 1 and 65535")
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("range %d-%d ends before it starts", start, end)
+	}
+	return start, end, nil
+}
+
+// String renders the range as a compose ports entry
+func (r PortRange) String() string {
+	s := fmt.Sprintf("%d-%d:%d-%d", r.HostStart, r.HostEnd, r.ContainerStart, r.ContainerEnd)
+	if r.Protocol != "" {
+		s += "/" + r.Protocol
+	}
+	return s
+}
+
+// DefaultHealthCheckPath is the HTTP path probed by runtime healthchecks
+const DefaultHealthCheckPath = "/health"
+
+// Runtime port modes
+const (
+	PortModeHost  = "host"  // publish Port on the host
+	PortModeNone  = "none"  // publish nothing, e.g. for workers
+	PortModeRange = "range" // publish a host port range for scaled replicas
+)
+
+// DefaultPortRange is the number of host ports published in range mode
+const DefaultPortRange = 10
+
+// PortRangeSize returns the number of host ports published in range mode
+func (r Runtime) PortRangeSize() int {
+	if r.PortRange > 0 {
+		return r.PortRange
+	}
+	return DefaultPortRange
+}
+
+// NodePackageManagers lists the package managers Node runtimes can use
+var NodePackageManagers = []string{"npm", "pnpm", "yarn"}
+
+// ValidatePackageManager checks a Node package manager, allowing empty for
+// npm
+func ValidatePackageManager(name string) error {
+	if name == "" || slices.Contains(NodePackageManagers, name) {
+		return nil
+	}
+	return fmt.Errorf("unknown package manager %q (use npm, pnpm or yarn)", name)
+}
+
+// PythonDepTools lists the dependency tools Python runtimes can use
+var PythonDepTools = []string{"pip", "poetry", "pipenv", "uv"}
+
+// ValidateDepTool checks a Python dependency tool, allowing empty for pip
+func ValidateDepTool(name string) error {
+	if name == "" || slices.Contains(PythonDepTools, name) {
+		return nil
+	}
+	return fmt.Errorf("unknown dependency tool %q (use pip, poetry, pipenv or uv)", name)
+}
+
+// Redis persistence modes
+const (
+	PersistenceNone = "none" // nothing on disk, /data is a tmpfs
+	PersistenceRDB  = "rdb"  // periodic snapshots
+	PersistenceAOF  = "aof"  // append-only file
+	PersistenceBoth = "both" // snapshots and append-only file
+)
+
+// ValidatePersistence checks a persistence mode, allowing empty for the
+// default
+func ValidatePersistence(mode string) error {
+	switch mode {
+	case "", PersistenceNone, PersistenceRDB, PersistenceAOF, PersistenceBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown persistence %q (use none, rdb, aof or both)", mode)
+}
+
+// Character set and collation 'stackgen add datastore mysql' uses unless
+// told otherwise: full Unicode, unlike the legacy latin1 of older images
+const (
+	DefaultMySQLCharset   = "utf8mb4"
+	DefaultMySQLCollation = "utf8mb4_unicode_ci"
+)
+
+var charsetPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// CollationCharset returns the character set a collation belongs to, e.g.
+// utf8mb4 for utf8mb4_unicode_ci
+func CollationCharset(collation string) string {
+	charset, _, _ := strings.Cut(collation, "_")
+	return charset
+}
+
+// ValidateCharset checks a MySQL character set and collation, either of
+// which may be empty. A collation must belong to the character set.
+func ValidateCharset(charset, collation string) error {
+	for _, name := range []string{charset, collation} {
+		if name != "" && !charsetPattern.MatchString(name) {
+			return fmt.Errorf("invalid character set or collation %q", name)
+		}
+	}
+	if charset != "" && collation != "" && !strings.HasPrefix(collation, charset+"_") {
+		return fmt.Errorf("collation %s does not belong to character set %s", collation, charset)
+	}
+	return nil
+}
+
+// PullPolicies are the compose pull_policy values stackgen accepts
+var PullPolicies = []string{"never", "missing", "always"}
+
+// ValidatePullPolicy checks a pull policy, allowing empty for compose's
+// default
+func ValidatePullPolicy(policy string) error {
+	if policy == "" || slices.Contains(PullPolicies, policy) {
+		return nil
+	}
+	return fmt.Errorf("unknown pull policy %q (use %s)", policy, strings.Join(PullPolicies, ", "))
+}
+
+// ValidatePortMode checks a runtime port mode, allowing empty for the default
+func ValidatePortMode(mode string) error {
+	switch mode {
+	case "", PortModeHost, PortModeNone, PortModeRange:
+		return nil
+	}
+	return fmt.Errorf("unknown port mode %q (use host, none or range)", mode)
 }
 
 // RuntimeType enumerates supported runtimes
@@ -97,25 +420,148 @@ type EnvVar struct {
 
 // ComposeService represents a service in docker-compose.yml
 type ComposeService struct {
-	Image         string            `yaml:"image,omitempty"`
-	Build         *ComposeBuild     `yaml:"build,omitempty"`
-	ContainerName string            `yaml:"container_name,omitempty"`
-	Ports         []string          `yaml:"ports,omitempty"`
-	Volumes       []string          `yaml:"volumes,omitempty"`
-	Environment   map[string]string `yaml:"environment,omitempty"`
-	EnvFile       []string          `yaml:"env_file,omitempty"`
-	DependsOn     []string          `yaml:"depends_on,omitempty"`
-	Networks      []string          `yaml:"networks,omitempty"`
-	HealthCheck   *ComposeHealth    `yaml:"healthcheck,omitempty"`
-	Restart       string            `yaml:"restart,omitempty"`
-	Command       string            `yaml:"command,omitempty"`
-	User          string            `yaml:"user,omitempty"`
+	Extends         *ComposeExtends        `yaml:"extends,omitempty"`
+	Image           string                 `yaml:"image,omitempty"`
+	Build           *ComposeBuild          `yaml:"build,omitempty"`
+	PullPolicy      string                 `yaml:"pull_policy,omitempty"`
+	ContainerName   string                 `yaml:"container_name,omitempty"`
+	Ports           []string               `yaml:"ports,omitempty"`
+	Expose          []string               `yaml:"expose,omitempty"`
+	Volumes         []string               `yaml:"volumes,omitempty"`
+	Environment     map[string]string      `yaml:"environment,omitempty"`
+	EnvFile         []ComposeEnvFile       `yaml:"env_file,omitempty"`
+	DependsOn       ComposeDependsOn       `yaml:"depends_on,omitempty"`
+	Networks        []string               `yaml:"networks,omitempty"`
+	HealthCheck     *ComposeHealth         `yaml:"healthcheck,omitempty"`
+	Restart         string                 `yaml:"restart,omitempty"`
+	Command         string                 `yaml:"command,omitempty"`
+	User            string                 `yaml:"user,omitempty"`
+	StopGracePeriod string                 `yaml:"stop_grace_period,omitempty"`
+	Init            *bool                  `yaml:"init,omitempty"`
+	ReadOnly        bool                   `yaml:"read_only,omitempty"`
+	Tmpfs           []string               `yaml:"tmpfs,omitempty"`
+	Ulimits         map[string]interface{} `yaml:"ulimits,omitempty"`
+	CapAdd          []string               `yaml:"cap_add,omitempty"`
+	CapDrop         []string               `yaml:"cap_drop,omitempty"`
+	SecurityOpt     []string               `yaml:"security_opt,omitempty"`
+	ShmSize         string                 `yaml:"shm_size,omitempty"`
+	MemSwappiness   *int                   `yaml:"mem_swappiness,omitempty"`
+	OOMKillDisable  *bool                  `yaml:"oom_kill_disable,omitempty"`
+	Labels          map[string]string      `yaml:"labels,omitempty"`
+	Deploy          *ComposeDeploy         `yaml:"deploy,omitempty"`
+	Develop         *ComposeDevelop        `yaml:"develop,omitempty"`
+}
+
+// ComposeDeploy holds the deploy settings compose applies locally
+type ComposeDeploy struct {
+	Replicas int `yaml:"replicas,omitempty"`
+}
+
+// ComposeDevelop configures docker compose watch
+type ComposeDevelop struct {
+	Watch []ComposeWatch `yaml:"watch"`
+}
+
+// ComposeWatch is a develop.watch rule
+type ComposeWatch struct {
+	Action string   `yaml:"action"`
+	Path   string   `yaml:"path"`
+	Target string   `yaml:"target,omitempty"`
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// ComposeExtends references a service to inherit configuration from
+type ComposeExtends struct {
+	File    string `yaml:"file"`
+	Service string `yaml:"service"`
 }
 
 // ComposeBuild represents build configuration
 type ComposeBuild struct {
-	Context    string `yaml:"context"`
-	Dockerfile string `yaml:"dockerfile"`
+	Context    string            `yaml:"context"`
+	Dockerfile string            `yaml:"dockerfile"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+}
+
+// Conditions a service can wait for in depends_on
+const (
+	ConditionStarted = "service_started"
+	ConditionHealthy = "service_healthy"
+)
+
+// ComposeDependsOn is the long form of depends_on, keyed by service name
+type ComposeDependsOn map[string]ComposeDependency
+
+// ComposeDependency is a depends_on entry
+type ComposeDependency struct {
+	Condition string `yaml:"condition"`
+}
+
+// DependsOnStarted returns depends_on waiting for each service to start
+func DependsOnStarted(services ...string) ComposeDependsOn {
+	if len(services) == 0 {
+		return nil
+	}
+	deps := make(ComposeDependsOn, len(services))
+	for _, service := range services {
+		deps[service] = ComposeDependency{Condition: ConditionStarted}
+	}
+	return deps
+}
+
+// UnmarshalYAML also accepts the short list form, as hand-written compose
+// files use it
+func (d *ComposeDependsOn) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var services []string
+		if err := value.Decode(&services); err != nil {
+			return err
+		}
+		*d = DependsOnStarted(services...)
+		return nil
+	}
+	var deps map[string]ComposeDependency
+	if err := value.Decode(&deps); err != nil {
+		return err
+	}
+	*d = deps
+	return nil
+}
+
+// ComposeEnvFile is an env_file entry. Optional files are written in the
+// long form with required: false, so compose skips them when missing.
+type ComposeEnvFile struct {
+	Path     string
+	Optional bool
+}
+
+// composeEnvFileLong is the long form of an env_file entry
+type composeEnvFileLong struct {
+	Path     string `yaml:"path"`
+	Required *bool  `yaml:"required,omitempty"`
+}
+
+// MarshalYAML writes required files as a plain path
+func (f ComposeEnvFile) MarshalYAML() (interface{}, error) {
+	if !f.Optional {
+		return f.Path, nil
+	}
+	required := false
+	return composeEnvFileLong{Path: f.Path, Required: &required}, nil
+}
+
+// UnmarshalYAML accepts both the plain path and the long form
+func (f *ComposeEnvFile) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*f = ComposeEnvFile{Path: value.Value}
+		return nil
+	}
+	var long composeEnvFileLong
+	if err := value.Decode(&long); err != nil {
+		return err
+	}
+	*f = ComposeEnvFile{Path: long.Path, Optional: long.Required != nil && !*long.Required}
+	return nil
 }
 
 // ComposeHealth represents healthcheck in compose format
@@ -130,7 +576,8 @@ type ComposeHealth struct {
 // ComposeFile represents the full docker-compose.yml structure
 type ComposeFile struct {
 	Version  string                    `yaml:"version,omitempty"`
-	Services map[string]ComposeService `yaml:"services"`
+	Include  []string                  `yaml:"include,omitempty"`
+	Services map[string]ComposeService `yaml:"services,omitempty"`
 	Volumes  map[string]interface{}    `yaml:"volumes,omitempty"`
 	Networks map[string]interface{}    `yaml:"networks,omitempty"`
 }
@@ -144,6 +591,7 @@ func AvailableDatastores() []DatastoreType {
 		DatastoreNeo4j,
 		DatastoreRedis,
 		DatastoreRedisStack,
+		DatastoreSQLite,
 	}
 }
 
@@ -166,6 +614,15 @@ type DatastoreInfo struct {
 	Description string
 	DefaultPort int
 	Edition     string
+	// Ulimits the server needs to start reliably, as soft[:hard] by name
+	Ulimits map[string]string
+	// ShmSize replaces Docker's 64MB /dev/shm for servers that need more
+	ShmSize string
+	// VersionAliases maps latest, stable and lts to concrete tags; lts
+	// falls back to stable for datastores without long-term releases
+	VersionAliases map[string]string
+	// MajorTag turns a major version into a tag, e.g. %s-alpine
+	MajorTag string
 }
 
 // GetDatastoreInfo returns metadata for a datastore type
@@ -177,6 +634,10 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "Powerful open-source relational database",
 			DefaultPort: 5432,
 			Edition:     "Official Image",
+			// Parallel queries allocate dynamic shared memory in /dev/shm
+			ShmSize:        "256m",
+			VersionAliases: map[string]string{"latest": "17-alpine", "stable": "16-alpine"},
+			MajorTag:       "%s-alpine",
 		},
 		DatastoreMySQL: {
 			Type:        DatastoreMySQL,
@@ -184,6 +645,9 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "Popular open-source relational database",
 			DefaultPort: 3306,
 			Edition:     "Official Image",
+			// 8.4 is MySQL's first LTS release line
+			VersionAliases: map[string]string{"latest": "9.1", "stable": "8.0", "lts": "8.4"},
+			MajorTag:       "%s",
 		},
 		DatastoreMSSQL: {
 			Type:        DatastoreMSSQL,
@@ -191,6 +655,9 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "Microsoft SQL Server (Developer Edition)",
 			DefaultPort: 1433,
 			Edition:     "Developer Edition - for development use only",
+			// Majors are release years, e.g. 2022
+			VersionAliases: map[string]string{"latest": "2022-latest", "stable": "2022-latest"},
+			MajorTag:       "%s-latest",
 		},
 		DatastoreNeo4j: {
 			Type:        DatastoreNeo4j,
@@ -198,6 +665,10 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "Graph database for connected data",
 			DefaultPort: 7474,
 			Edition:     "Community Edition",
+			// Neo4j warns and can run out of file handles below 40000
+			Ulimits:        map[string]string{"nofile": "40000:40000"},
+			VersionAliases: map[string]string{"latest": "5", "stable": "5", "lts": "5.26"},
+			MajorTag:       "%s",
 		},
 		DatastoreRedis: {
 			Type:        DatastoreRedis,
@@ -205,6 +676,9 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "In-memory data store and cache",
 			DefaultPort: 6379,
 			Edition:     "Community",
+			// 7 follows the newest 7.x release, latest pins 7.4
+			VersionAliases: map[string]string{"latest": "7.4-alpine", "stable": "7-alpine"},
+			MajorTag:       "%s-alpine",
 		},
 		DatastoreRedisStack: {
 			Type:        DatastoreRedisStack,
@@ -212,11 +686,263 @@ func GetDatastoreInfo(t DatastoreType) DatastoreInfo {
 			Description: "Redis with JSON, Search, TimeSeries modules",
 			DefaultPort: 6379,
 			Edition:     "Community",
+			// Bundles are versioned like 7.4.0-v1, so there is no MajorTag
+			VersionAliases: map[string]string{"latest": "latest", "stable": "latest"},
+		},
+		DatastoreSQLite: {
+			Type:        DatastoreSQLite,
+			DisplayName: "SQLite",
+			Description: "Database file mounted into runtimes, no service",
+			Edition:     "File only",
 		},
 	}
 	return info[t]
 }
 
+// ValidateDefaultFrameworks checks each default names a framework the
+// runtime supports
+func ValidateDefaultFrameworks(defaults map[RuntimeType]string) error {
+	for rt, framework := range defaults {
+		info := GetRuntimeInfo(rt)
+		if info.Type == "" {
+			return fmt.Errorf("default_frameworks: unknown runtime %s", rt)
+		}
+		if !containsString(info.Frameworks, framework) {
+			return fmt.Errorf("default_frameworks: %s does not support framework %s (available: %s)", rt, framework, strings.Join(info.Frameworks, ", "))
+		}
+	}
+	return nil
+}
+
+// majorVersionPattern matches a bare major version such as 16 or 2022
+var majorVersionPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// ResolveVersion turns a friendly version (latest, stable, lts or a major
+// version number) into the datastore's concrete image tag
+func ResolveVersion(t DatastoreType, version string) (string, error) {
+	info := GetDatastoreInfo(t)
+	if tag, ok := info.VersionAliases[version]; ok {
+		return tag, nil
+	}
+	if version == "lts" {
+		if tag, ok := info.VersionAliases["stable"]; ok {
+			return tag, nil
+		}
+	}
+	if majorVersionPattern.MatchString(version) && info.MajorTag != "" {
+		return fmt.Sprintf(info.MajorTag, version), nil
+	}
+	return "", fmt.Errorf("unknown %s version %q (use latest, stable, lts or a major version)", t, version)
+}
+
+// imageTagPattern is the charset docker accepts for image tags
+var imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// ValidateImageTag checks that tag is a valid docker image tag, allowing
+// empty for stackgen's default
+func ValidateImageTag(tag string) error {
+	if tag == "" || imageTagPattern.MatchString(tag) {
+		return nil
+	}
+	return fmt.Errorf("image tag %q must be at most 128 letters, digits, '_', '.' and '-', not starting with '.' or '-'", tag)
+}
+
+// ValidateToolchainVersion checks a runtime toolchain version, which ends
+// up in the FROM line of the Dockerfile, allowing empty for stackgen's
+// pinned version
+func ValidateToolchainVersion(version string) error {
+	if version == "" || imageTagPattern.MatchString(version) {
+		return nil
+	}
+	return fmt.Errorf("toolchain version %q must be at most 128 letters, digits, '_', '.' and '-', not starting with '.' or '-'", version)
+}
+
+var (
+	// serviceNamePattern is the charset docker compose accepts for services
+	serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	// containerNamePattern is the charset docker accepts for containers
+	containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+	// invalidNameChars matches runs of characters no name may contain
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+// ValidateNetworkName checks an external network name
+func ValidateNetworkName(name string) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("network name %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// ValidateProjectName checks that the project name makes valid
+// <project>-<service> container names
+func ValidateProjectName(name string) error {
+	if !containerNamePattern.MatchString(name + "-x") {
+		return fmt.Errorf("project name %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// ValidateServiceName checks that name is a valid compose service name for
+// a datastore or runtime
+func ValidateServiceName(kind, name string) error {
+	if !serviceNamePattern.MatchString(name) {
+		return fmt.Errorf("%s name %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", kind, name)
+	}
+	return nil
+}
+
+// ProxyServiceName is the name of the nginx service generated in front of
+// a runtime with replicas
+func ProxyServiceName(runtime string) string {
+	return runtime + "-proxy"
+}
+
+// JaegerServiceName is the compose service name of the tracing backend
+const JaegerServiceName = "jaeger"
+
+// ValidateNames checks the project name and every service name, and the
+// <project>-<service> container names built from them, returning one error
+// per invalid name. Names used by two services, or taken by Jaeger or the
+// proxy of a scaled runtime, are reported too.
+func ValidateNames(p *Project) []error {
+	var errs []error
+	if err := ValidateProjectName(p.Name); err != nil {
+		errs = append(errs, err)
+	}
+	check := func(kind, name string) {
+		if err := ValidateServiceName(kind, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, ds := range p.Datastores {
+		if ds.HasService() {
+			check("datastore", ds.Name)
+		}
+	}
+	for _, rt := range p.Runtimes {
+		check("runtime", rt.Name)
+	}
+
+	// Compose keys services by name, so a duplicate silently replaces the
+	// service generated before it
+	owners := make(map[string]string)
+	if p.Jaeger {
+		owners[JaegerServiceName] = "the Jaeger tracing backend"
+	}
+	claim := func(kind, name string) {
+		if owner, ok := owners[name]; ok {
+			errs = append(errs, fmt.Errorf("%s name %q is already used by %s; rename the service", kind, name, owner))
+			return
+		}
+		owners[name] = "a " + kind
+	}
+	for _, ds := range p.Datastores {
+		if ds.HasService() {
+			claim("datastore", ds.Name)
+		}
+	}
+	for _, rt := range p.Runtimes {
+		claim("runtime", rt.Name)
+	}
+	for _, rt := range p.Runtimes {
+		if proxy := ProxyServiceName(rt.Name); rt.Replicas > 1 && owners[proxy] != "" {
+			errs = append(errs, fmt.Errorf("service name %q is reserved for the proxy of runtime %s; rename the service", proxy, rt.Name))
+		}
+	}
+	return errs
+}
+
+// SanitizeName turns name into a valid service name by replacing invalid
+// characters with '-' and dropping leading separators
+func SanitizeName(name string) string {
+	name = strings.TrimLeft(invalidNameChars.ReplaceAllString(name, "-"), "_.-")
+	if name == "" {
+		return "service"
+	}
+	return name
+}
+
+// SanitizeNames fixes invalid project and service names in place, updating
+// depends_on references, and returns the renames made keyed by old name.
+// A sanitized name that clashes with another service gets a -2, -3, ...
+// suffix.
+func SanitizeNames(p *Project) map[string]string {
+	renames := make(map[string]string)
+	if fixed := SanitizeName(p.Name); fixed != p.Name {
+		renames[p.Name] = fixed
+		p.Name = fixed
+	}
+
+	// Names kept as they are, which renamed services must avoid
+	taken := make(map[string]bool)
+	if p.Jaeger {
+		taken[JaegerServiceName] = true
+	}
+	for _, ds := range p.Datastores {
+		if ds.HasService() && SanitizeName(ds.Name) == ds.Name {
+			taken[ds.Name] = true
+		}
+	}
+	for _, rt := range p.Runtimes {
+		if SanitizeName(rt.Name) == rt.Name {
+			taken[rt.Name] = true
+			if rt.Replicas > 1 {
+				taken[ProxyServiceName(rt.Name)] = true
+			}
+		}
+	}
+	unique := func(name string) string {
+		fixed := name
+		for n := 2; taken[fixed]; n++ {
+			fixed = fmt.Sprintf("%s-%d", name, n)
+		}
+		taken[fixed] = true
+		return fixed
+	}
+
+	services := make(map[string]string)
+	for i := range p.Datastores {
+		ds := &p.Datastores[i]
+		if fixed := SanitizeName(ds.Name); ds.HasService() && fixed != ds.Name {
+			fixed = unique(fixed)
+			services[ds.Name] = fixed
+			ds.Name = fixed
+		}
+	}
+	for i := range p.Runtimes {
+		rt := &p.Runtimes[i]
+		if fixed := SanitizeName(rt.Name); fixed != rt.Name {
+			fixed = unique(fixed)
+			if rt.Replicas > 1 {
+				taken[ProxyServiceName(fixed)] = true
+			}
+			services[rt.Name] = fixed
+			rt.Name = fixed
+		}
+	}
+	for i := range p.Runtimes {
+		for j, dep := range p.Runtimes[i].DependsOn {
+			if fixed, ok := services[dep]; ok {
+				p.Runtimes[i].DependsOn[j] = fixed
+			}
+		}
+	}
+	for old, fixed := range services {
+		renames[old] = fixed
+	}
+	return renames
+}
+
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // RuntimeInfo provides metadata about a runtime
 type RuntimeInfo struct {
 	Type        RuntimeType
@@ -224,6 +950,7 @@ type RuntimeInfo struct {
 	Description string
 	DefaultPort int
 	Frameworks  []string
+	DebugPort   int // debugger port in the container, 0 when unsupported
 }
 
 // GetRuntimeInfo returns metadata for a runtime type
@@ -235,6 +962,7 @@ func GetRuntimeInfo(t RuntimeType) RuntimeInfo {
 			Description: "Fast, statically typed language",
 			DefaultPort: 8080,
 			Frameworks:  []string{"stdlib", "gin", "fiber", "echo"},
+			DebugPort:   2345,
 		},
 		RuntimeNode: {
 			Type:        RuntimeNode,
@@ -242,6 +970,7 @@ func GetRuntimeInfo(t RuntimeType) RuntimeInfo {
 			Description: "JavaScript runtime for server-side",
 			DefaultPort: 3000,
 			Frameworks:  []string{"express", "fastify", "nextjs", "nestjs"},
+			DebugPort:   9229,
 		},
 		RuntimePython: {
 			Type:        RuntimePython,
@@ -249,6 +978,7 @@ func GetRuntimeInfo(t RuntimeType) RuntimeInfo {
 			Description: "Versatile scripting language",
 			DefaultPort: 8000,
 			Frameworks:  []string{"fastapi", "flask", "django"},
+			DebugPort:   5678,
 		},
 		RuntimeJava: {
 			Type:        RuntimeJava,
@@ -256,6 +986,7 @@ func GetRuntimeInfo(t RuntimeType) RuntimeInfo {
 			Description: "Enterprise-grade JVM language",
 			DefaultPort: 8080,
 			Frameworks:  []string{"spring-boot", "quarkus", "micronaut"},
+			DebugPort:   5005,
 		},
 		RuntimeRust: {
 			Type:        RuntimeRust,
@@ -5,7 +5,7 @@ import "testing"
 func TestAvailableDatastores(t *testing.T) {
 	datastores := AvailableDatastores()
 	
-	expected := 6
+	expected := 7
 	if len(datastores) != expected {
 		t.Errorf("Expected %d datastores, got %d", expected, len(datastores))
 	}
@@ -18,6 +18,7 @@ func TestAvailableDatastores(t *testing.T) {
 		DatastoreNeo4j:      false,
 		DatastoreRedis:      false,
 		DatastoreRedisStack: false,
+		DatastoreSQLite:     false,
 	}
 
 	for _, ds := range datastores {
@@ -105,3 +106,150 @@ func TestNeo4jInfo(t *testing.T) {
 		t.Error("Neo4j should specify Community Edition")
 	}
 }
+
+func TestValidateDefaultFrameworks(t *testing.T) {
+	if err := ValidateDefaultFrameworks(map[RuntimeType]string{RuntimeGo: "gin", RuntimeNode: "nextjs"}); err != nil {
+		t.Errorf("Valid defaults should pass: %v", err)
+	}
+
+	if err := ValidateDefaultFrameworks(map[RuntimeType]string{RuntimeGo: "django"}); err == nil {
+		t.Error("Framework from another runtime should fail")
+	}
+
+	if err := ValidateDefaultFrameworks(map[RuntimeType]string{"cobol": "cics"}); err == nil {
+		t.Error("Unknown runtime should fail")
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	p := &Project{
+		Name:       "shop",
+		Datastores: []Datastore{{Type: DatastorePostgres, Name: "postgres"}},
+		Runtimes:   []Runtime{{Type: RuntimeGo, Name: "my_service.v2"}},
+	}
+	if errs := ValidateNames(p); len(errs) != 0 {
+		t.Errorf("Valid names reported as invalid: %v", errs)
+	}
+
+	p.Runtimes = append(p.Runtimes, Runtime{Type: RuntimeNode, Name: "web app", DependsOn: []string{"-db"}})
+	p.Datastores = append(p.Datastores, Datastore{Type: DatastoreRedis, Name: "-db"})
+	if errs := ValidateNames(p); len(errs) != 2 {
+		t.Errorf("Expected 2 invalid names, got %v", errs)
+	}
+
+	renames := SanitizeNames(p)
+	if renames["web app"] != "web-app" || renames["-db"] != "db" {
+		t.Errorf("Unexpected renames: %v", renames)
+	}
+	if p.Runtimes[1].DependsOn[0] != "db" {
+		t.Errorf("depends_on should follow the rename, got %v", p.Runtimes[1].DependsOn)
+	}
+	if errs := ValidateNames(p); len(errs) != 0 {
+		t.Errorf("Sanitized names should be valid: %v", errs)
+	}
+
+	p.Runtimes[0].Replicas = 2
+	p.Datastores = append(p.Datastores, Datastore{Type: DatastoreRedis, Name: "my_service.v2-proxy"})
+	if errs := ValidateNames(p); len(errs) != 1 {
+		t.Errorf("The proxy name of a scaled runtime should be reserved, got %v", errs)
+	}
+}
+
+func TestSanitizeNamesAvoidsClashes(t *testing.T) {
+	p := &Project{
+		Name:   "shop",
+		Jaeger: true,
+		Datastores: []Datastore{
+			{Type: DatastoreRedis, Name: "cache"},
+			{Type: DatastoreRedis, Name: "-cache"},
+			{Type: DatastorePostgres, Name: "-jaeger"},
+		},
+		Runtimes: []Runtime{{Type: RuntimeGo, Name: "api", DependsOn: []string{"-cache"}}},
+	}
+	if errs := ValidateNames(p); len(errs) != 2 {
+		t.Errorf("Expected 2 invalid names, got %v", errs)
+	}
+
+	renames := SanitizeNames(p)
+	if renames["-cache"] != "cache-2" || renames["-jaeger"] != "jaeger-2" {
+		t.Errorf("Sanitized names should not clash with other services: %v", renames)
+	}
+	if p.Runtimes[0].DependsOn[0] != "cache-2" {
+		t.Errorf("depends_on should follow the rename, got %v", p.Runtimes[0].DependsOn)
+	}
+	if errs := ValidateNames(p); len(errs) != 0 {
+		t.Errorf("Sanitized names should be valid: %v", errs)
+	}
+
+	// Hand-edited duplicates are valid names but would drop a service
+	p.Runtimes = append(p.Runtimes, Runtime{Type: RuntimeNode, Name: "cache"})
+	if errs := ValidateNames(p); len(errs) != 1 {
+		t.Errorf("A duplicate service name should be reported, got %v", errs)
+	}
+	p.Runtimes[1].Name = "jaeger"
+	if errs := ValidateNames(p); len(errs) != 1 {
+		t.Errorf("The Jaeger service name should be reserved, got %v", errs)
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	tests := []struct {
+		dsType  DatastoreType
+		version string
+		want    string
+	}{
+		{DatastorePostgres, "latest", "17-alpine"},
+		{DatastorePostgres, "lts", "16-alpine"}, // no LTS line, falls back to stable
+		{DatastorePostgres, "15", "15-alpine"},
+		{DatastoreMySQL, "lts", "8.4"},
+		{DatastoreMSSQL, "2019", "2019-latest"},
+	}
+	for _, tt := range tests {
+		got, err := ResolveVersion(tt.dsType, tt.version)
+		if err != nil || got != tt.want {
+			t.Errorf("ResolveVersion(%s, %s) = %q, %v; want %q", tt.dsType, tt.version, got, err, tt.want)
+		}
+	}
+	if _, err := ResolveVersion(DatastoreRedisStack, "7"); err == nil {
+		t.Error("Redis Stack has no major version tags")
+	}
+	if _, err := ResolveVersion(DatastorePostgres, "newest"); err == nil {
+		t.Error("Unknown aliases should fail")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	for spec, want := range map[string]string{
+		"8000-8010:8000-8010":     "8000-8010:8000-8010",
+		"21000-21010":             "21000-21010:21000-21010",
+		"9000-9002:7000-7002/udp": "9000-9002:7000-7002/udp",
+	} {
+		r, err := ParsePortRange(spec)
+		if err != nil {
+			t.Errorf("ParsePortRange(%q) failed: %v", spec, err)
+			continue
+		}
+		if r.String() != want {
+			t.Errorf("ParsePortRange(%q) = %s, want %s", spec, r, want)
+		}
+	}
+
+	for _, spec := range []string{"8000", "8000-8010:8000-8005", "8010-8000", "0-10", "8000-8010/sctp", "a-b"} {
+		if _, err := ParsePortRange(spec); err == nil {
+			t.Errorf("ParsePortRange(%q) should fail", spec)
+		}
+	}
+}
+
+func TestValidateToolchainVersion(t *testing.T) {
+	for _, version := range []string{"", "1.23", "20", "3.12-slim", "8.0_rc1"} {
+		if err := ValidateToolchainVersion(version); err != nil {
+			t.Errorf("ValidateToolchainVersion(%q) = %v", version, err)
+		}
+	}
+	for _, version := range []string{"20\nRUN echo injected", "-1", ".5", "1.23 AS x", "a/b"} {
+		if err := ValidateToolchainVersion(version); err == nil {
+			t.Errorf("ValidateToolchainVersion(%q) should fail", version)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package ports
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+// MaxPort is the highest valid port; searches for a free port stop there
+const MaxPort = 65535
+
+// jaegerPorts are the fixed host ports of the Jaeger all-in-one service
+var jaegerPorts = []int{16686, 4317, 4318}
+
+// Conflict is a host port claimed by several services or already taken
+// by another process on the host
+type Conflict struct {
+	Port     int
+	Services []string
+	InUse    bool
+}
+
+// Move records a service reassigned to a new host port. Project is set
+// by FixAll to tell apart services of different projects.
+type Move struct {
+	Project  string
+	Service  string
+	From, To int
+}
+
+// block is the set of host ports one service publishes, as offsets from
+// its configured port. set applies a new port; nil means it is fixed.
+type block struct {
+	project string
+	service string
+	start   int
+	offsets []int
+	set     func(int)
+}
+
+// fits reports whether every port of a block starting at start is valid
+func fits(start int, offsets []int) bool {
+	for _, off := range offsets {
+		if start+off > MaxPort {
+			return false
+		}
+	}
+	return true
+}
+
+// run returns offsets for count consecutive ports
+func run(count int) []int {
+	offsets := make([]int, count)
+	for i := range offsets {
+		offsets[i] = i
+	}
+	return offsets
+}
+
+// datastoreOffsets mirrors the extra host ports the generator publishes
+// relative to a datastore's port
+func datastoreOffsets(ds *models.Datastore) []int {
+	switch ds.Type {
+	case models.DatastorePostgres:
+		// Replicas publish on the ports following the primary
+		return run(1 + ds.Replicas)
+	case models.DatastoreNeo4j:
+		return []int{0, 213} // HTTP and Bolt (7474, 7687)
+	case models.DatastoreRedisStack:
+		return []int{0, 1622} // Redis and RedisInsight (6379, 8001)
+	}
+	return []int{0}
+}
+
+// blocks lists the host ports each service publishes, in generation order
+func blocks(project *models.Project) []block {
+	var out []block
+	for i := range project.Datastores {
+		ds := &project.Datastores[i]
+		if !ds.IsExposed() || ds.Port <= 0 {
+			continue
+		}
+		out = append(out, block{service: ds.Name, start: ds.Port, offsets: datastoreOffsets(ds), set: func(p int) { ds.Port = p }})
+	}
+	// Port ranges are spelled out by the user and never moved
+	for _, ds := range project.Datastores {
+		if ds.IsExposed() {
+			out = append(out, rangeBlocks(ds.Name, ds.PortRanges)...)
+		}
+	}
+	// Metrics exporter sidecars publish a port of their own
+	for i := range project.Datastores {
+		ds := &project.Datastores[i]
+		if ds.MetricsPort > 0 {
+			out = append(out, block{service: ds.Name + "-exporter", start: ds.MetricsPort, offsets: []int{0}, set: func(p int) { ds.MetricsPort = p }})
+		}
+	}
+	if project.Jaeger {
+		for _, p := range jaegerPorts {
+			out = append(out, block{service: "jaeger", start: p, offsets: []int{0}})
+		}
+	}
+	for i := range project.Runtimes {
+		rt := &project.Runtimes[i]
+		if rt.Port <= 0 {
+			continue
+		}
+		offsets := []int{0}
+		switch rt.PortMode {
+		case models.PortModeNone:
+			continue
+		case models.PortModeRange:
+			offsets = run(rt.PortRangeSize())
+		}
+		out = append(out, block{service: rt.Name, start: rt.Port, offsets: offsets, set: func(p int) { rt.Port = p }})
+	}
+	for _, rt := range project.Runtimes {
+		out = append(out, rangeBlocks(rt.Name, rt.PortRanges)...)
+	}
+	// Debugger ports are published next to the runtime's own port
+	for i := range project.Runtimes {
+		rt := &project.Runtimes[i]
+		if rt.DebugPort > 0 {
+			out = append(out, block{service: rt.Name + "-debug", start: rt.DebugPort, offsets: []int{0}, set: func(p int) { rt.DebugPort = p }})
+		}
+	}
+	return out
+}
+
+// rangeBlocks returns fixed blocks for a service's port_ranges, skipping
+// invalid ones that generation reports
+func rangeBlocks(service string, ranges []string) []block {
+	var out []block
+	for _, spec := range ranges {
+		if r, err := models.ParsePortRange(spec); err == nil {
+			out = append(out, block{service: service, start: r.HostStart, offsets: run(r.HostEnd - r.HostStart + 1)})
+		}
+	}
+	return out
+}
+
+// Check reports host ports claimed by more than one service and, when
+// inUse is given, ports another process already holds
+func Check(project *models.Project, inUse func(int) bool) []Conflict {
+	return check(blocks(project), inUse)
+}
+
+// CheckAll is Check across several projects sharing one host, naming
+// services <project>/<service>
+func CheckAll(projects []*models.Project, inUse func(int) bool) []Conflict {
+	return check(workspaceBlocks(projects), inUse)
+}
+
+func check(all []block, inUse func(int) bool) []Conflict {
+	claims := make(map[int][]string)
+	for _, b := range all {
+		name := b.service
+		if b.project != "" {
+			name = b.project + "/" + b.service
+		}
+		for _, off := range b.offsets {
+			claims[b.start+off] = append(claims[b.start+off], name)
+		}
+	}
+
+	var conflicts []Conflict
+	for port, services := range claims {
+		busy := inUse != nil && inUse(port)
+		if len(services) > 1 || busy {
+			conflicts = append(conflicts, Conflict{Port: port, Services: services, InUse: busy})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Port < conflicts[j].Port })
+	return conflicts
+}
+
+// Fix moves services off conflicting ports, scanning upward for the next
+// port at which all of a service's ports are free. Earlier services keep
+// their ports and fixed ports (Jaeger) never move. It returns the moves,
+// or an error when a service finds no free ports below MaxPort.
+func Fix(project *models.Project, inUse func(int) bool) ([]Move, error) {
+	return fix(blocks(project), inUse)
+}
+
+// FixAll is Fix across several projects sharing one host, such as the
+// members of a workspace, so that every host port is claimed by one
+// service of one project. Earlier projects keep their ports.
+func FixAll(projects []*models.Project, inUse func(int) bool) ([]Move, error) {
+	return fix(workspaceBlocks(projects), inUse)
+}
+
+// workspaceBlocks lists the blocks of several projects, tagged with the
+// project name
+func workspaceBlocks(projects []*models.Project) []block {
+	var out []block
+	for _, project := range projects {
+		for _, b := range blocks(project) {
+			b.project = project.Name
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func fix(all []block, inUse func(int) bool) ([]Move, error) {
+	taken := make(map[int]bool)
+	for _, b := range all {
+		if b.set == nil {
+			for _, off := range b.offsets {
+				taken[b.start+off] = true
+			}
+		}
+	}
+
+	free := func(start int, offsets []int) bool {
+		for _, off := range offsets {
+			if taken[start+off] || (inUse != nil && inUse(start+off)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var moves []Move
+	for _, b := range all {
+		if b.set == nil {
+			continue
+		}
+		port := b.start
+		if !free(port, b.offsets) {
+			for port++; fits(port, b.offsets) && !free(port, b.offsets); port++ {
+			}
+			if !fits(port, b.offsets) {
+				name := b.service
+				if b.project != "" {
+					name = b.project + "/" + b.service
+				}
+				return moves, fmt.Errorf("no free host ports for %s between %d and %d", name, b.start, MaxPort)
+			}
+			b.set(port)
+			moves = append(moves, Move{Project: b.project, Service: b.service, From: b.start, To: port})
+		}
+		for _, off := range b.offsets {
+			taken[port+off] = true
+		}
+	}
+	return moves, nil
+}
+
+// NextFree returns the first port at or after ds.Port at which every host
+// port the datastore publishes is unclaimed by the project's services, so
+// it can be added to the project without a conflict. It fails when there
+// is no such port below MaxPort.
+func NextFree(project *models.Project, ds *models.Datastore) (int, error) {
+	taken := make(map[int]bool)
+	for _, b := range blocks(project) {
+		for _, off := range b.offsets {
+			taken[b.start+off] = true
+		}
+	}
+	offsets := datastoreOffsets(ds)
+	for port := ds.Port; fits(port, offsets); port++ {
+		free := true
+		for _, off := range offsets {
+			if taken[port+off] {
+				free = false
+				break
+			}
+		}
+		if free {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("no free host port for %s between %d and %d", ds.Name, ds.Port, MaxPort)
+}
+
+// InUse reports whether a TCP port on the host is already bound
+func InUse(port int) bool {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+	l.Close()
+	return false
+}
@@ -0,0 +1,160 @@
+package ports
+
+import (
+	"testing"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+)
+
+func TestCheckAndFix(t *testing.T) {
+	project := &models.Project{
+		Name: "portstest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+			{Type: models.DatastoreRedisStack, Name: "redis-stack", Port: 6379},
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 5433},
+		},
+	}
+	inUse := func(port int) bool { return port == 6380 }
+
+	conflicts := Check(project, inUse)
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected conflicts on 5433 and 6379, got %+v", conflicts)
+	}
+
+	moves, err := Fix(project, inUse)
+	if err != nil {
+		t.Fatalf("Fix failed: %v", err)
+	}
+	if len(moves) != 2 {
+		t.Fatalf("Expected 2 moves, got %+v", moves)
+	}
+	if project.Datastores[1].Port != 6381 {
+		t.Errorf("redis-stack should skip 6379 (taken) and 6380 (in use), got %d", project.Datastores[1].Port)
+	}
+	if project.Runtimes[0].Port != 5434 {
+		t.Errorf("api should move past the postgres replica port, got %d", project.Runtimes[0].Port)
+	}
+	if len(Check(project, inUse)) != 0 {
+		t.Error("No conflicts should remain after Fix")
+	}
+}
+
+func TestFixKeepsJaegerPorts(t *testing.T) {
+	project := &models.Project{
+		Name:   "jaegertest",
+		Jaeger: true,
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeNode, Name: "web", Port: 4317},
+		},
+	}
+
+	moves, err := Fix(project, nil)
+	if err != nil {
+		t.Fatalf("Fix failed: %v", err)
+	}
+	if len(moves) != 1 || moves[0].Service != "web" || project.Runtimes[0].Port != 4319 {
+		t.Errorf("web should move past the fixed Jaeger ports, got %+v", moves)
+	}
+}
+
+func TestFixKeepsPortRanges(t *testing.T) {
+	project := &models.Project{
+		Name: "rangetest",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379, PortRanges: []string{"8000-8002"}},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 8001},
+		},
+	}
+
+	moves, err := Fix(project, nil)
+	if err != nil {
+		t.Fatalf("Fix failed: %v", err)
+	}
+	if len(moves) != 1 || moves[0].Service != "api" || project.Runtimes[0].Port != 8003 {
+		t.Errorf("api should move past the fixed port range, got %+v", moves)
+	}
+}
+
+func TestFixAllAcrossProjects(t *testing.T) {
+	api := &models.Project{
+		Name: "api",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080},
+		},
+	}
+	billing := &models.Project{
+		Name: "billing",
+		Datastores: []models.Datastore{
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432},
+		},
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "go-app", Port: 8080},
+		},
+	}
+	projects := []*models.Project{api, billing}
+
+	conflicts := CheckAll(projects, nil)
+	if len(conflicts) != 2 || conflicts[0].Services[1] != "billing/postgres" {
+		t.Fatalf("Expected conflicts on 5432 and 8080 naming billing's services, got %+v", conflicts)
+	}
+
+	moves, err := FixAll(projects, nil)
+	if err != nil {
+		t.Fatalf("FixAll failed: %v", err)
+	}
+	if len(moves) != 2 || moves[0].Project != "billing" {
+		t.Fatalf("Expected billing's two services to move, got %+v", moves)
+	}
+	if api.Datastores[0].Port != 5432 || billing.Datastores[0].Port != 5433 || billing.Runtimes[0].Port != 8081 {
+		t.Errorf("Unexpected ports after FixAll: api postgres %d, billing postgres %d, billing go-app %d",
+			api.Datastores[0].Port, billing.Datastores[0].Port, billing.Runtimes[0].Port)
+	}
+	if len(CheckAll(projects, nil)) != 0 {
+		t.Error("No conflicts should remain after FixAll")
+	}
+}
+
+func TestNextFree(t *testing.T) {
+	project := &models.Project{
+		Name: "nextfree",
+		Datastores: []models.Datastore{
+			{Type: models.DatastoreRedis, Name: "redis", Port: 6379},
+			{Type: models.DatastorePostgres, Name: "postgres", Port: 5432, Replicas: 1},
+		},
+	}
+
+	if got, _ := NextFree(project, &models.Datastore{Type: models.DatastoreRedisStack, Port: 6379}); got != 6380 {
+		t.Errorf("redis-stack should move past redis to 6380, got %d", got)
+	}
+	if got, _ := NextFree(project, &models.Datastore{Type: models.DatastorePostgres, Port: 5432, Replicas: 1}); got != 5434 {
+		t.Errorf("A second postgres with a replica needs two free ports from 5434, got %d", got)
+	}
+	if got, _ := NextFree(project, &models.Datastore{Type: models.DatastoreMySQL, Port: 3306}); got != 3306 {
+		t.Errorf("mysql's default port is free, got %d", got)
+	}
+	if _, err := NextFree(project, &models.Datastore{Type: models.DatastoreNeo4j, Name: "neo4j", Port: 65400}); err == nil {
+		t.Error("NextFree should fail when the ports would pass 65535")
+	}
+}
+
+func TestFixStopsAtMaxPort(t *testing.T) {
+	project := &models.Project{
+		Name: "maxport",
+		Runtimes: []models.Runtime{
+			{Type: models.RuntimeGo, Name: "api", Port: 65535},
+			{Type: models.RuntimeGo, Name: "worker", Port: 65535},
+		},
+	}
+	if _, err := Fix(project, nil); err == nil {
+		t.Error("Fix should fail when no port up to 65535 is free")
+	}
+}
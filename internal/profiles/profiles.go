@@ -1,46 +1,71 @@
 package profiles
 
-import "github.com/stackgen-cli/stackgen/internal/models"
+import (
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"github.com/stackgen-cli/stackgen/internal/ports"
+)
 
 // Profile represents a preset configuration
 type Profile struct {
-	Name        string
-	Description string
-	Datastores  []models.DatastoreType
-	Runtimes    []RuntimeConfig
+	Name        string            `yaml:"name"`
+	Description string            `yaml:"description,omitempty"`
+	Datastores  []DatastoreConfig `yaml:"datastores,omitempty"`
+	Runtimes    []RuntimeConfig   `yaml:"runtimes,omitempty"`
+	Jaeger      bool              `yaml:"jaeger,omitempty"`
+	// Environments maps a target environment (e.g. ci, staging) to
+	// datastore tag overrides
+	Environments map[string]map[models.DatastoreType]string `yaml:"environments,omitempty"`
+}
+
+// DatastoreConfig holds datastore configuration for a profile
+type DatastoreConfig struct {
+	Type models.DatastoreType `yaml:"type"`
+	Tag  string               `yaml:"tag,omitempty"` // image tag; empty uses stackgen's default for the type
 }
 
 // RuntimeConfig holds runtime configuration for a profile
 type RuntimeConfig struct {
-	Type      models.RuntimeType
-	Framework string
+	Type      models.RuntimeType `yaml:"type"`
+	Framework string             `yaml:"framework,omitempty"`
+	Version   string             `yaml:"version,omitempty"` // toolchain version; empty uses stackgen's pinned version
 }
 
-// AvailableProfiles returns all preset profiles
+// AvailableProfiles returns the built-in profiles followed by those
+// imported into the user profiles file
 func AvailableProfiles() []Profile {
+	user, _ := LoadUser()
+	return append(builtinProfiles(), user...)
+}
+
+// builtinProfiles returns the profiles shipped with stackgen
+func builtinProfiles() []Profile {
 	return []Profile{
 		{
 			Name:        "web-app",
 			Description: "Full-stack web application (Node.js + Postgres + Redis)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres, models.DatastoreRedis},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}, {Type: models.DatastoreRedis}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimeNode, Framework: "express"}},
 		},
 		{
 			Name:        "api",
 			Description: "REST API backend (Go + Postgres)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimeGo, Framework: "stdlib"}},
+			Environments: map[string]map[models.DatastoreType]string{
+				// Debian-based image, as typically run in production
+				"staging": {models.DatastorePostgres: "16"},
+			},
 		},
 		{
 			Name:        "ml",
 			Description: "Machine learning / data science (Python + Postgres + Redis)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres, models.DatastoreRedis},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}, {Type: models.DatastoreRedis}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimePython, Framework: "fastapi"}},
 		},
 		{
 			Name:        "fullstack",
 			Description: "Complete microservices stack (Node + Go + Postgres + Redis + Neo4j)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres, models.DatastoreRedis, models.DatastoreNeo4j},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}, {Type: models.DatastoreRedis}, {Type: models.DatastoreNeo4j}},
 			Runtimes: []RuntimeConfig{
 				{Type: models.RuntimeNode, Framework: "express"},
 				{Type: models.RuntimeGo, Framework: "stdlib"},
@@ -49,21 +74,28 @@ func AvailableProfiles() []Profile {
 		{
 			Name:        "java-enterprise",
 			Description: "Enterprise Java stack (Spring Boot + Postgres + Redis)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres, models.DatastoreRedis},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}, {Type: models.DatastoreRedis}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimeJava, Framework: "spring-boot"}},
 		},
 		{
 			Name:        "dotnet",
 			Description: ".NET Core application (C# + SQL Server)",
-			Datastores:  []models.DatastoreType{models.DatastoreMSSQL},
+			Datastores:  []DatastoreConfig{{Type: models.DatastoreMSSQL}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimeCSharp, Framework: "aspnetcore"}},
 		},
 		{
 			Name:        "rust-api",
 			Description: "High-performance Rust API (Rust + Postgres + Redis)",
-			Datastores:  []models.DatastoreType{models.DatastorePostgres, models.DatastoreRedis},
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}, {Type: models.DatastoreRedis}},
 			Runtimes:    []RuntimeConfig{{Type: models.RuntimeRust, Framework: "actix-web"}},
 		},
+		{
+			Name:        "tracing",
+			Description: "Traced API with local Jaeger backend (Go + Postgres + Jaeger)",
+			Datastores:  []DatastoreConfig{{Type: models.DatastorePostgres}},
+			Runtimes:    []RuntimeConfig{{Type: models.RuntimeGo, Framework: "stdlib"}},
+			Jaeger:      true,
+		},
 	}
 }
 
@@ -77,24 +109,99 @@ func GetProfile(name string) *Profile {
 	return nil
 }
 
+// ForEnvironment returns a copy of the profile with the datastore tags of
+// the named environment applied. It reports false when the profile does
+// not define env, in which case the copy keeps the default tags.
+func (p Profile) ForEnvironment(env string) (Profile, bool) {
+	tags, ok := p.Environments[env]
+	if !ok {
+		return p, false
+	}
+	datastores := make([]DatastoreConfig, len(p.Datastores))
+	for i, ds := range p.Datastores {
+		if tag, ok := tags[ds.Type]; ok {
+			ds.Tag = tag
+		}
+		datastores[i] = ds
+	}
+	p.Datastores = datastores
+	return p, true
+}
+
+// Filter returns the profiles that include every given datastore and
+// runtime type
+func Filter(datastores []models.DatastoreType, runtimes []models.RuntimeType) []Profile {
+	var matched []Profile
+	for _, p := range AvailableProfiles() {
+		if p.hasDatastores(datastores) && p.hasRuntimes(runtimes) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+func (p Profile) hasDatastores(types []models.DatastoreType) bool {
+	for _, t := range types {
+		found := false
+		for _, ds := range p.Datastores {
+			if ds.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (p Profile) hasRuntimes(types []models.RuntimeType) bool {
+	for _, t := range types {
+		found := false
+		for _, rt := range p.Runtimes {
+			if rt.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // BuildProjectFromProfile creates a Project from a profile
-func BuildProjectFromProfile(profile *Profile, projectName, outputDir string) *models.Project {
+func BuildProjectFromProfile(profile *Profile, projectName, outputDir string) (*models.Project, error) {
 	project := &models.Project{
 		Name:      projectName,
 		OutputDir: outputDir,
 		Profile:   profile.Name,
+		Jaeger:    profile.Jaeger,
 	}
 
-	// Add datastores with default ports
-	portOffset := 0
-	for _, dsType := range profile.Datastores {
-		info := models.GetDatastoreInfo(dsType)
+	// Add datastores with default ports, moving past ports taken by
+	// earlier ones (Redis and Redis Stack both default to 6379)
+	for _, dsConfig := range profile.Datastores {
+		info := models.GetDatastoreInfo(dsConfig.Type)
+		tag := dsConfig.Tag
+		if tag == "" {
+			tag = getDefaultTag(dsConfig.Type)
+		}
 		ds := models.Datastore{
-			Type:         dsType,
-			Name:         string(dsType),
-			Port:         info.DefaultPort + portOffset,
+			Type:         dsConfig.Type,
+			Name:         string(dsConfig.Type),
+			Port:         info.DefaultPort,
 			InternalPort: info.DefaultPort,
-			Tag:          getDefaultTag(dsType),
+			Tag:          tag,
+		}
+		if ds.Port > 0 {
+			port, err := ports.NextFree(project, &ds)
+			if err != nil {
+				return nil, err
+			}
+			ds.Port = port
 		}
 		project.Datastores = append(project.Datastores, ds)
 	}
@@ -102,8 +209,10 @@ func BuildProjectFromProfile(profile *Profile, projectName, outputDir string) *m
 	// Add runtimes
 	runtimePortOffset := 0
 	var dependsOn []string
-	for _, dsType := range profile.Datastores {
-		dependsOn = append(dependsOn, string(dsType))
+	for _, dsConfig := range profile.Datastores {
+		if (models.Datastore{Type: dsConfig.Type}).HasService() {
+			dependsOn = append(dependsOn, string(dsConfig.Type))
+		}
 	}
 
 	for _, rtConfig := range profile.Runtimes {
@@ -112,6 +221,7 @@ func BuildProjectFromProfile(profile *Profile, projectName, outputDir string) *m
 			Type:         rtConfig.Type,
 			Name:         string(rtConfig.Type) + "-app",
 			Framework:    rtConfig.Framework,
+			Version:      rtConfig.Version,
 			Port:         info.DefaultPort + runtimePortOffset,
 			InternalPort: info.DefaultPort,
 			BuildContext: string(rtConfig.Type) + "-app",
@@ -122,7 +232,7 @@ func BuildProjectFromProfile(profile *Profile, projectName, outputDir string) *m
 		runtimePortOffset += 1000
 	}
 
-	return project
+	return project, nil
 }
 
 func getDefaultTag(dsType models.DatastoreType) string {
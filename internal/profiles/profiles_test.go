@@ -64,7 +64,10 @@ func TestBuildProjectFromProfile(t *testing.T) {
 		t.Fatal("web-app profile should exist")
 	}
 
-	project := BuildProjectFromProfile(profile, "myproject", ".")
+	project, err := BuildProjectFromProfile(profile, "myproject", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
 
 	if project.Name != "myproject" {
 		t.Errorf("Expected myproject, got %s", project.Name)
@@ -79,6 +82,27 @@ func TestBuildProjectFromProfile(t *testing.T) {
 	}
 }
 
+func TestBuildProjectFromProfileSQLite(t *testing.T) {
+	profile := &Profile{
+		Name: "sqlite-app",
+		Datastores: []DatastoreConfig{
+			{Type: models.DatastoreSQLite},
+			{Type: models.DatastoreRedis},
+		},
+		Runtimes: []RuntimeConfig{{Type: models.RuntimePython, Framework: "flask"}},
+	}
+
+	project, err := BuildProjectFromProfile(profile, "myproject", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+
+	deps := project.Runtimes[0].DependsOn
+	if len(deps) != 1 || deps[0] != "redis" {
+		t.Errorf("Runtimes should only depend on datastores with a service, got %v", deps)
+	}
+}
+
 func TestFullstackProfile(t *testing.T) {
 	profile := GetProfile("fullstack")
 	if profile == nil {
@@ -105,7 +129,7 @@ func TestDotnetProfile(t *testing.T) {
 	// Should use MSSQL
 	found := false
 	for _, ds := range profile.Datastores {
-		if ds == models.DatastoreMSSQL {
+		if ds.Type == models.DatastoreMSSQL {
 			found = true
 			break
 		}
@@ -126,3 +150,182 @@ func TestDotnetProfile(t *testing.T) {
 		t.Error("dotnet profile should include C# runtime")
 	}
 }
+
+func TestTracingProfile(t *testing.T) {
+	profile := GetProfile("tracing")
+	if profile == nil {
+		t.Fatal("tracing profile should exist")
+	}
+
+	project, err := BuildProjectFromProfile(profile, "traced", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+	if !project.Jaeger {
+		t.Error("tracing profile should enable Jaeger")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	matched := Filter([]models.DatastoreType{models.DatastoreRedis}, []models.RuntimeType{models.RuntimeGo})
+	if len(matched) != 1 || matched[0].Name != "fullstack" {
+		t.Errorf("Only fullstack has both redis and go, got %v", matched)
+	}
+
+	if len(Filter(nil, nil)) != len(AvailableProfiles()) {
+		t.Error("Filter without criteria should return every profile")
+	}
+	if len(Filter([]models.DatastoreType{"nonexistent"}, nil)) != 0 {
+		t.Error("Filter on an unknown datastore should match nothing")
+	}
+}
+
+func TestBuildProjectFromProfileTags(t *testing.T) {
+	profile := &Profile{
+		Name:       "pinned",
+		Datastores: []DatastoreConfig{{Type: models.DatastorePostgres, Tag: "15"}, {Type: models.DatastoreRedis}},
+		Runtimes:   []RuntimeConfig{{Type: models.RuntimeGo, Framework: "stdlib", Version: "1.23"}},
+	}
+
+	project, err := BuildProjectFromProfile(profile, "pinned", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+
+	if project.Datastores[0].Tag != "15" {
+		t.Errorf("Profile tag should be used, got %s", project.Datastores[0].Tag)
+	}
+	if project.Datastores[1].Tag != getDefaultTag(models.DatastoreRedis) {
+		t.Errorf("Datastores without a tag should get the default, got %s", project.Datastores[1].Tag)
+	}
+	if project.Runtimes[0].Version != "1.23" {
+		t.Errorf("Profile toolchain version should be used, got %s", project.Runtimes[0].Version)
+	}
+}
+
+func TestForEnvironment(t *testing.T) {
+	profile := GetProfile("api")
+	if profile == nil {
+		t.Fatal("api profile should exist")
+	}
+
+	staging, ok := profile.ForEnvironment("staging")
+	if !ok {
+		t.Fatal("api profile should define a staging environment")
+	}
+	project, err := BuildProjectFromProfile(&staging, "api", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+	if project.Datastores[0].Tag != "16" {
+		t.Errorf("Staging tag should be applied, got %s", project.Datastores[0].Tag)
+	}
+	if profile.Datastores[0].Tag != "" {
+		t.Error("ForEnvironment should not modify the original profile")
+	}
+
+	missing, ok := profile.ForEnvironment("nonexistent")
+	if ok {
+		t.Error("Undefined environments should be reported")
+	}
+	project, err = BuildProjectFromProfile(&missing, "api", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+	if project.Datastores[0].Tag != getDefaultTag(models.DatastorePostgres) {
+		t.Errorf("Undefined environments should fall back to default tags, got %s", project.Datastores[0].Tag)
+	}
+}
+
+func TestBuildProjectFromProfileDistinctPorts(t *testing.T) {
+	profile := &Profile{
+		Name: "caches",
+		Datastores: []DatastoreConfig{
+			{Type: models.DatastoreRedis},
+			{Type: models.DatastoreRedisStack},
+			{Type: models.DatastorePostgres},
+		},
+	}
+	project, err := BuildProjectFromProfile(profile, "caches", ".")
+	if err != nil {
+		t.Fatalf("BuildProjectFromProfile failed: %v", err)
+	}
+
+	redis, stack := project.Datastores[0], project.Datastores[1]
+	if redis.Port != 6379 {
+		t.Errorf("redis should keep its default port, got %d", redis.Port)
+	}
+	if stack.Port == redis.Port {
+		t.Fatalf("redis and redis-stack both got host port %d", stack.Port)
+	}
+	if stack.Port != 6380 || stack.InternalPort != 6379 {
+		t.Errorf("redis-stack should move to host port 6380 and keep container port 6379, got %d:%d", stack.Port, stack.InternalPort)
+	}
+	if project.Datastores[2].Port != 5432 {
+		t.Errorf("postgres should keep its default port, got %d", project.Datastores[2].Port)
+	}
+}
+
+func TestParse(t *testing.T) {
+	valid := `profiles:
+  - name: team-api
+    datastores:
+      - type: postgres
+        tag: 16-alpine
+    runtimes:
+      - type: go
+        framework: gin
+    environments:
+      ci:
+        postgres: "16"
+`
+	parsed, err := Parse([]byte(valid))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(parsed) != 1 || parsed[0].Runtimes[0].Framework != "gin" || parsed[0].Environments["ci"][models.DatastorePostgres] != "16" {
+		t.Errorf("Unexpected profiles: %+v", parsed)
+	}
+
+	for name, doc := range map[string]string{
+		"unknown field":     "profiles:\n  - name: x\n    jaeger: true\n    command: rm -rf /\n",
+		"unknown datastore": "profiles:\n  - name: x\n    datastores: [{type: oracle}]\n",
+		"unknown framework": "profiles:\n  - name: x\n    runtimes: [{type: go, framework: rails}]\n",
+		"built-in name":     "profiles:\n  - name: api\n    jaeger: true\n",
+		"invalid name":      "profiles:\n  - name: ../x\n    jaeger: true\n",
+		"empty":             "profiles:\n  - name: x\n",
+		"stray env tag":     "profiles:\n  - name: x\n    jaeger: true\n    environments: {ci: {redis: '7'}}\n",
+		"injected version":  "profiles:\n  - name: x\n    runtimes: [{type: node, framework: express, version: \"20\\nRUN echo injected\"}]\n",
+		"invalid tag":       "profiles:\n  - name: x\n    datastores: [{type: redis, tag: '7 AS x'}]\n",
+		"invalid env tag":   "profiles:\n  - name: x\n    datastores: [{type: redis}]\n    environments: {ci: {redis: '-7'}}\n",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("%s: Parse should fail", name)
+		}
+	}
+}
+
+func TestSaveUser(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	first := Profile{Name: "team", Description: "v1", Jaeger: true}
+	if _, err := SaveUser([]Profile{first}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	second := Profile{Name: "other", Jaeger: true}
+	first.Description = "v2"
+	if _, err := SaveUser([]Profile{first, second}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	user, err := LoadUser()
+	if err != nil {
+		t.Fatalf("LoadUser failed: %v", err)
+	}
+	if len(user) != 2 || user[0].Description != "v2" || user[1].Name != "other" {
+		t.Errorf("Saved profiles should be merged by name, got %+v", user)
+	}
+	if p := GetProfile("team"); p == nil || p.Description != "v2" {
+		t.Error("Imported profiles should be available next to the built-in ones")
+	}
+}
@@ -0,0 +1,155 @@
+package profiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// UserFileName is the profiles file kept in the user's config directory
+const UserFileName = "profiles.yaml"
+
+// userFile is the document stored in the user profiles file and served
+// by shared profile sources
+type userFile struct {
+	Profiles []Profile `yaml:"profiles"`
+}
+
+// UserFile returns the path of the user profiles file,
+// <config dir>/stackgen/profiles.yaml
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "stackgen", UserFileName), nil
+}
+
+// LoadUser returns the profiles imported into the user profiles file, or
+// none when it does not exist
+func LoadUser() ([]Profile, error) {
+	path, err := UserFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// SaveUser merges profiles into the user profiles file, replacing those
+// with the same name, and returns the file's path
+func SaveUser(profiles []Profile) (string, error) {
+	path, err := UserFile()
+	if err != nil {
+		return "", err
+	}
+	existing, err := LoadUser()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, p := range profiles {
+		i := slices.IndexFunc(existing, func(e Profile) bool { return e.Name == p.Name })
+		if i >= 0 {
+			existing[i] = p
+		} else {
+			existing = append(existing, p)
+		}
+	}
+
+	data, err := yaml.Marshal(userFile{Profiles: existing})
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// Parse reads a profiles document. Unknown fields are rejected rather than
+// ignored, so a shared file cannot smuggle in settings stackgen would
+// silently drop, and every profile is validated.
+func Parse(data []byte) ([]Profile, error) {
+	var doc userFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid profiles file: %w", err)
+	}
+	seen := make(map[string]bool, len(doc.Profiles))
+	for _, p := range doc.Profiles {
+		if err := Validate(p); err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("profile %s is defined twice", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return doc.Profiles, nil
+}
+
+// Validate checks that a profile only uses datastores, runtimes and
+// frameworks stackgen supports, that its tags and versions are valid
+// docker tags, and that it does not shadow a built-in profile
+func Validate(p Profile) error {
+	if p.Name == "" {
+		return fmt.Errorf("profile without a name")
+	}
+	if models.SanitizeName(p.Name) != p.Name {
+		return fmt.Errorf("profile name %q must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", p.Name)
+	}
+	for _, b := range builtinProfiles() {
+		if b.Name == p.Name {
+			return fmt.Errorf("profile %s clashes with the built-in profile of that name", p.Name)
+		}
+	}
+	if len(p.Datastores)+len(p.Runtimes) == 0 && !p.Jaeger {
+		return fmt.Errorf("profile %s has no datastores, runtimes or jaeger", p.Name)
+	}
+	for _, ds := range p.Datastores {
+		if !slices.Contains(models.AvailableDatastores(), ds.Type) {
+			return fmt.Errorf("profile %s: unknown datastore %q", p.Name, ds.Type)
+		}
+		if err := models.ValidateImageTag(ds.Tag); err != nil {
+			return fmt.Errorf("profile %s: %s: %w", p.Name, ds.Type, err)
+		}
+	}
+	for _, rt := range p.Runtimes {
+		if !slices.Contains(models.AvailableRuntimes(), rt.Type) {
+			return fmt.Errorf("profile %s: unknown runtime %q", p.Name, rt.Type)
+		}
+		if rt.Framework == "" {
+			return fmt.Errorf("profile %s: runtime %s needs a framework", p.Name, rt.Type)
+		}
+		if !slices.Contains(models.GetRuntimeInfo(rt.Type).Frameworks, rt.Framework) {
+			return fmt.Errorf("profile %s: unknown %s framework %q", p.Name, rt.Type, rt.Framework)
+		}
+		if err := models.ValidateToolchainVersion(rt.Version); err != nil {
+			return fmt.Errorf("profile %s: %s: %w", p.Name, rt.Type, err)
+		}
+	}
+	for env, tags := range p.Environments {
+		for dsType, tag := range tags {
+			if !slices.ContainsFunc(p.Datastores, func(ds DatastoreConfig) bool { return ds.Type == dsType }) {
+				return fmt.Errorf("profile %s: environment %s sets a tag for %s, which the profile does not include", p.Name, env, dsType)
+			}
+			if err := models.ValidateImageTag(tag); err != nil {
+				return fmt.Errorf("profile %s: environment %s: %s: %w", p.Name, env, dsType, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dockerHub is the registry host for images without an explicit registry
+const dockerHub = "registry-1.docker.io"
+
+// manifestTypes are accepted so multi-arch images resolve to their index
+// digest, which is what docker pulls by
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Client resolves image tags against registries using the v2 HTTP API
+type Client struct {
+	HTTP *http.Client
+}
+
+// New creates a registry client with a request timeout
+func New() *Client {
+	return &Client{HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// ParseRepository splits an image repository into registry host and path,
+// applying Docker Hub defaults
+func ParseRepository(repo string) (host, path string) {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if !strings.Contains(repo, "/") {
+		return dockerHub, "library/" + repo
+	}
+	return dockerHub, repo
+}
+
+// ResolveDigest returns the content digest a tag currently points at
+func (c *Client) ResolveDigest(repo, tag string) (string, error) {
+	host, path := ParseRepository(repo)
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, path, tag)
+
+	resp, err := c.headManifest(url, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := c.fetchToken(resp.Header.Get("Www-Authenticate"))
+		if err != nil {
+			return "", fmt.Errorf("failed to authenticate with %s: %w", host, err)
+		}
+		if resp, err = c.headManifest(url, token); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s:%s: registry returned %s", repo, tag, resp.Status)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("%s:%s: registry did not return a digest", repo, tag)
+	}
+	return digest, nil
+}
+
+func (c *Client) headManifest(url, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach registry: %w", err)
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// fetchToken requests an anonymous pull token from the realm named in a
+// Bearer challenge
+func (c *Client) fetchToken(challenge string) (string, error) {
+	params, err := ParseChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, params["realm"], nil)
+	if err != nil {
+		return "", err
+	}
+	q := req.URL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			q.Set(key, params[key])
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// ParseChallenge parses a WWW-Authenticate Bearer challenge into its
+// parameters
+func ParseChallenge(challenge string) (map[string]string, error) {
+	scheme, rest, ok := strings.Cut(challenge, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for rest != "" {
+		key, value, ok := strings.Cut(strings.TrimLeft(rest, ", "), "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("malformed auth challenge %q", challenge)
+			}
+			params[key] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(value, ",")
+			params[key] = value
+		}
+	}
+	if params["realm"] == "" {
+		return nil, fmt.Errorf("auth challenge has no realm: %q", challenge)
+	}
+	return params, nil
+}
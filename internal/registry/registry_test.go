@@ -0,0 +1,38 @@
+package registry
+
+import "testing"
+
+func TestParseRepository(t *testing.T) {
+	tests := []struct {
+		repo, host, path string
+	}{
+		{"postgres", "registry-1.docker.io", "library/postgres"},
+		{"redis/redis-stack", "registry-1.docker.io", "redis/redis-stack"},
+		{"mcr.microsoft.com/mssql/server", "mcr.microsoft.com", "mssql/server"},
+		{"localhost:5000/app", "localhost:5000", "app"},
+	}
+
+	for _, tt := range tests {
+		host, path := ParseRepository(tt.repo)
+		if host != tt.host || path != tt.path {
+			t.Errorf("ParseRepository(%q) = %q, %q; want %q, %q", tt.repo, host, path, tt.host, tt.path)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	params, err := ParseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/postgres:pull"`)
+	if err != nil {
+		t.Fatalf("ParseChallenge failed: %v", err)
+	}
+	if params["realm"] != "https://auth.docker.io/token" {
+		t.Errorf("Unexpected realm %q", params["realm"])
+	}
+	if params["scope"] != "repository:library/postgres:pull" {
+		t.Errorf("Unexpected scope %q", params["scope"])
+	}
+
+	if _, err := ParseChallenge(`Basic realm="x"`); err == nil {
+		t.Error("ParseChallenge should reject non-Bearer challenges")
+	}
+}
@@ -1,12 +1,62 @@
 package templates
 
-// GoDockerfile returns a Dockerfile for Go applications
-func GoDockerfile(framework string) string {
-	return `# Go Dockerfile - Generated by stackgen
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Toolchain versions used when a runtime does not set one
+const (
+	DefaultGoVersion     = "1.22"
+	DefaultNodeVersion   = "20"
+	DefaultPythonVersion = "3.12"
+	DefaultJavaVersion   = "21"
+	DefaultRustVersion   = "1.75"
+	DefaultDotnetVersion = "8.0"
+)
+
+// versionOr returns version, or def when version is empty
+func versionOr(version, def string) string {
+	if version == "" {
+		return def
+	}
+	return version
+}
+
+// goFrameworkModules maps Go frameworks to the module the build must fetch
+var goFrameworkModules = map[string]string{
+	"gin":   "github.com/gin-gonic/gin",
+	"fiber": "github.com/gofiber/fiber/v2",
+	"echo":  "github.com/labstack/echo/v4",
+}
+
+// GoDockerfile returns a Dockerfile for Go applications on the given Go
+// version (DefaultGoVersion when empty).
+// Expected layout: go.mod at the build context root and a main package
+// there that listens on $PORT.
+func GoDockerfile(framework, version string) string {
+	v := versionOr(version, DefaultGoVersion)
+	title := "Go"
+	fetch := ""
+	if module, ok := goFrameworkModules[framework]; ok {
+		title = "Go (" + framework + ")"
+		// After COPY . ., so the go.mod change isn't overwritten by it
+		fetch = fmt.Sprintf(`
+# Add the framework if go.mod doesn't require it yet
+RUN go list -m %[1]s >/dev/null 2>&1 || go get %[1]s
+`, module)
+	}
+
+	return `# ` + title + ` Dockerfile - Generated by stackgen
 # Multi-stage build for optimal image size
+#
+# Expected layout:
+#   go.mod, go.sum     module files at the build context root
+#   main.go            package main, listening on $PORT
 
 # Build stage
-FROM golang:1.22-alpine AS builder
+FROM golang:` + v + `-alpine AS builder
 
 WORKDIR /app
 
@@ -19,7 +69,7 @@ RUN go mod download
 
 # Copy source code
 COPY . .
-
+` + fetch + `
 # Build the application
 RUN CGO_ENABLED=0 GOOS=linux go build -ldflags="-w -s" -o /app/server .
 
@@ -41,48 +91,249 @@ CMD ["/app/server"]
 `
 }
 
-// NodeDockerfile returns a Dockerfile for Node.js applications
-func NodeDockerfile(framework string) string {
+// delveVersion is the Delve release GoDebugDockerfile installs
+const delveVersion = "v1.23.1"
+
+// GoDebugDockerfile returns a Dockerfile running a Go application under a
+// headless Delve listening on debugPort. The binary is built without
+// optimizations and kept outside /app, which the source bind mount covers.
+func GoDebugDockerfile(framework, version string, debugPort int) string {
+	v := versionOr(version, DefaultGoVersion)
+	fetch := "RUN go mod download"
+	if module, ok := goFrameworkModules[framework]; ok {
+		fetch = fmt.Sprintf(`RUN go mod download && \
+    (go list -m %[1]s >/dev/null 2>&1 || go get %[1]s)`, module)
+	}
+	port := strconv.Itoa(debugPort)
+
+	return `# Go Dockerfile (Delve debugger) - Generated by stackgen
+# Attach a debugger to port ` + port + `; the app starts without waiting for it
+FROM golang:` + v + `-alpine
+
+WORKDIR /app
+
+RUN apk add --no-cache git ca-certificates
+RUN go install github.com/go-delve/delve/cmd/dlv@` + delveVersion + `
+
+COPY go.mod go.sum* ./
+` + fetch + `
+
+COPY . .
+
+# Keep debug information and disable optimizations
+RUN CGO_ENABLED=0 go build -gcflags="all=-N -l" -o /usr/local/bin/server .
+
+EXPOSE 8080 ` + port + `
+
+CMD ["dlv", "exec", "/usr/local/bin/server", "--headless", "--listen=:` + port + `", "--api-version=2", "--accept-multiclient", "--continue"]
+`
+}
+
+// NodeDebugCommand returns the command starting a Node framework's server
+// with the inspector listening on debugPort. npm is bypassed, since
+// NODE_OPTIONS would start an inspector in npm itself.
+func NodeDebugCommand(framework string, debugPort int) string {
+	entry := "index.js"
 	switch framework {
 	case "nextjs":
-		return `# Next.js Dockerfile - Generated by stackgen
-FROM node:20-alpine AS base
+		entry = "node_modules/next/dist/bin/next start"
+	case "nestjs":
+		entry = "dist/main.js"
+	}
+	return fmt.Sprintf("node --inspect=0.0.0.0:%d %s", debugPort, entry)
+}
 
-# Install dependencies only when needed
-FROM base AS deps
-WORKDIR /app
-COPY package.json package-lock.json* yarn.lock* pnpm-lock.yaml* ./
+// NodePackageManager holds the commands a Dockerfile uses for one Node
+// package manager
+type NodePackageManager struct {
+	Name        string
+	Lockfile    string // copied next to package.json
+	Install     string // reproducible install from the lockfile
+	InstallProd string // production dependencies only
+	Corepack    bool   // needs 'corepack enable' before use
+}
+
+// nodePackageManagers maps models.NodePackageManagers to their commands
+var nodePackageManagers = map[string]NodePackageManager{
+	"npm":  {Name: "npm", Lockfile: "package-lock.json", Install: "npm ci", InstallProd: "npm ci --only=production"},
+	"pnpm": {Name: "pnpm", Lockfile: "pnpm-lock.yaml", Install: "pnpm install --frozen-lockfile", InstallProd: "pnpm install --frozen-lockfile --prod", Corepack: true},
+	"yarn": {Name: "yarn", Lockfile: "yarn.lock", Install: "yarn install --frozen-lockfile", InstallProd: "yarn install --frozen-lockfile --production"},
+}
+
+// NodeManager returns the commands of a package manager, npm when empty
+func NodeManager(name string) NodePackageManager {
+	if pm, ok := nodePackageManagers[name]; ok {
+		return pm
+	}
+	return nodePackageManagers["npm"]
+}
+
+// CopyFiles returns the package files to COPY before installing
+func (pm NodePackageManager) CopyFiles() string {
+	if pm.Name == "npm" {
+		return "package*.json"
+	}
+	return "package.json " + pm.Lockfile
+}
+
+// Setup returns the RUN line that makes the manager available, if any
+func (pm NodePackageManager) Setup() string {
+	if pm.Corepack {
+		return "RUN corepack enable\n"
+	}
+	return ""
+}
+
+// Script returns the command running a package.json script with extra
+// arguments passed through to it
+func (pm NodePackageManager) Script(script string, args ...string) []string {
+	command := []string{pm.Name, "run", script}
+	if len(args) > 0 && pm.Name == "npm" {
+		command = append(command, "--")
+	}
+	return append(command, args...)
+}
+
+// ExecForm renders a command as a Dockerfile exec-form array
+func ExecForm(command []string) string {
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = fmt.Sprintf("%q", arg)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// nextDepsInstall returns the Next.js deps stage install. npm, the
+// default, keeps detecting the lockfile the project actually has.
+func nextDepsInstall(pm NodePackageManager) string {
+	if pm.Name != "npm" {
+		return "COPY " + pm.CopyFiles() + " ./\nRUN " + pm.Install + "\n"
+	}
+	return `COPY package.json package-lock.json* yarn.lock* pnpm-lock.yaml* ./
 RUN \
   if [ -f yarn.lock ]; then yarn --frozen-lockfile; \
   elif [ -f package-lock.json ]; then npm ci; \
   elif [ -f pnpm-lock.yaml ]; then corepack enable && pnpm i --frozen-lockfile; \
   else npm install; \
   fi
+`
+}
 
+// NodeDockerfile returns a Dockerfile for Node.js applications on the given
+// toolchain version, or the default when empty, installing dependencies
+// and building with packageManager (npm when empty). Containers start
+// their scripts with npm, which ships with the image, so corepack does not
+// have to download pnpm again at run time.
+func NodeDockerfile(framework, version, packageManager string) string {
+	v := versionOr(version, DefaultNodeVersion)
+	pm := NodeManager(packageManager)
+	switch framework {
+	case "nextjs":
+		return `# Next.js Dockerfile - Generated by stackgen
+#
+# Expected layout: package.json with "dev" (next dev), "build" (next build)
+# and "start" (next start) scripts
+#
+# The default target serves the production build. For live development set
+# "target: development" under the service's build section.
+
+FROM node:` + v + `-alpine AS base
+` + pm.Setup() + `
+# Install dependencies only when needed
+FROM base AS deps
+WORKDIR /app
+` + nextDepsInstall(pm) + `
 # Development stage
 FROM base AS development
 WORKDIR /app
+ENV NODE_ENV=development
 COPY --from=deps /app/node_modules ./node_modules
 COPY . .
 
 EXPOSE 3000
 
 CMD ["npm", "run", "dev"]
+
+# Build stage
+FROM base AS builder
+WORKDIR /app
+COPY --from=deps /app/node_modules ./node_modules
+COPY . .
+ENV NEXT_TELEMETRY_DISABLED=1
+RUN ` + strings.Join(pm.Script("build"), " ") + `
+
+# Runtime stage
+FROM base AS runner
+WORKDIR /app
+ENV NODE_ENV=production
+ENV NEXT_TELEMETRY_DISABLED=1
+
+RUN addgroup -g 1001 -S nodejs && adduser -S nextjs -u 1001
+
+COPY --from=builder /app/package.json ./package.json
+COPY --from=builder /app/node_modules ./node_modules
+COPY --from=builder --chown=nextjs:nodejs /app/.next ./.next
+COPY --from=builder /app/public* ./public/
+
+USER nextjs
+
+EXPOSE 3000
+
+CMD ["npm", "start"]
+`
+	case "nestjs":
+		return `# NestJS Dockerfile - Generated by stackgen
+# Multi-stage build
+#
+# Expected layout: package.json with a "build" (nest build) script that
+# compiles src/ to dist/main.js
+
+# Build stage
+FROM node:` + v + `-alpine AS builder
+
+WORKDIR /app
+` + pm.Setup() + `
+COPY ` + pm.CopyFiles() + ` ./
+RUN ` + pm.Install + `
+
+COPY . .
+RUN ` + strings.Join(pm.Script("build"), " ") + `
+
+# Runtime stage
+FROM node:` + v + `-alpine
+
+WORKDIR /app
+` + pm.Setup() + `
+# Add non-root user for security
+RUN addgroup -g 1001 -S nodejs && adduser -S nodejs -u 1001
+
+COPY ` + pm.CopyFiles() + ` ./
+RUN ` + pm.InstallProd + `
+
+COPY --from=builder --chown=nodejs:nodejs /app/dist ./dist
+
+USER nodejs
+
+EXPOSE 3000
+
+CMD ["node", "dist/main.js"]
 `
 	default:
 		return `# Node.js Dockerfile - Generated by stackgen
-FROM node:20-alpine
+#
+# Expected layout: package.json and an index.js entrypoint listening on $PORT
+FROM node:` + v + `-alpine
 
 WORKDIR /app
-
+` + pm.Setup() + `
 # Add non-root user for security
 RUN addgroup -g 1001 -S nodejs && adduser -S nodejs -u 1001
 
 # Copy package files
-COPY package*.json ./
+COPY ` + pm.CopyFiles() + ` ./
 
 # Install dependencies
-RUN npm ci --only=production
+RUN ` + pm.InstallProd + `
 
 # Copy source code
 COPY --chown=nodejs:nodejs . .
@@ -96,12 +347,57 @@ CMD ["node", "index.js"]
 	}
 }
 
-// PythonDockerfile returns a Dockerfile for Python applications
-func PythonDockerfile(framework string) string {
+// PythonDepTool holds the commands a Dockerfile uses for one Python
+// dependency tool. Every tool installs into the image's system Python so
+// the generated CMDs work without activating a virtualenv.
+type PythonDepTool struct {
+	Name       string
+	Files      string // manifests copied before installing
+	Install    string // runtime dependencies from the lockfile
+	InstallDev string // runtime and development dependencies
+}
+
+// pythonDepTools maps models.PythonDepTools to their commands
+var pythonDepTools = map[string]PythonDepTool{
+	"pip": {Name: "pip", Files: "requirements.txt", Install: "pip install --no-cache-dir -r requirements.txt", InstallDev: "pip install --no-cache-dir -r requirements.txt"},
+	"poetry": {Name: "poetry", Files: "pyproject.toml poetry.lock",
+		Install:    "pip install --no-cache-dir poetry && POETRY_VIRTUALENVS_CREATE=false poetry install --no-interaction --no-root --only main",
+		InstallDev: "pip install --no-cache-dir poetry && POETRY_VIRTUALENVS_CREATE=false poetry install --no-interaction --no-root"},
+	"pipenv": {Name: "pipenv", Files: "Pipfile Pipfile.lock",
+		Install:    "pip install --no-cache-dir pipenv && pipenv install --system --deploy",
+		InstallDev: "pip install --no-cache-dir pipenv && pipenv install --system --deploy --dev"},
+	"uv": {Name: "uv", Files: "pyproject.toml uv.lock",
+		Install:    "pip install --no-cache-dir uv && UV_PROJECT_ENVIRONMENT=/usr/local uv sync --frozen --no-install-project --no-dev",
+		InstallDev: "pip install --no-cache-dir uv && UV_PROJECT_ENVIRONMENT=/usr/local uv sync --frozen --no-install-project"},
+}
+
+// DepTool returns the commands of a dependency tool, pip when empty
+func DepTool(name string) PythonDepTool {
+	if tool, ok := pythonDepTools[name]; ok {
+		return tool
+	}
+	return pythonDepTools["pip"]
+}
+
+// pythonDepsInstall returns the COPY and RUN lines installing a runtime's
+// dependencies. pip keeps the single requirements.txt copy.
+func pythonDepsInstall(tool PythonDepTool) string {
+	if tool.Name == "pip" {
+		return "COPY requirements.txt .\nRUN " + tool.Install + "\n"
+	}
+	return "COPY " + tool.Files + " ./\nRUN " + tool.Install + "\n"
+}
+
+// PythonDockerfile returns a Dockerfile for Python applications on the given
+// toolchain version, or the default when empty, installing dependencies
+// with depTool (pip and requirements.txt when empty)
+func PythonDockerfile(framework, version, depTool string) string {
+	v := versionOr(version, DefaultPythonVersion)
+	tool := DepTool(depTool)
 	switch framework {
 	case "fastapi":
 		return `# FastAPI Dockerfile - Generated by stackgen
-FROM python:3.12-slim
+FROM python:` + v + `-slim
 
 WORKDIR /app
 
@@ -109,9 +405,7 @@ WORKDIR /app
 RUN useradd -m -u 1000 appuser
 
 # Install dependencies
-COPY requirements.txt .
-RUN pip install --no-cache-dir -r requirements.txt
-
+` + pythonDepsInstall(tool) + `
 # Copy source code
 COPY --chown=appuser:appuser . .
 
@@ -123,7 +417,7 @@ CMD ["uvicorn", "main:app", "--host", "0.0.0.0", "--port", "8000", "--reload"]
 `
 	case "django":
 		return `# Django Dockerfile - Generated by stackgen
-FROM python:3.12-slim
+FROM python:` + v + `-slim
 
 WORKDIR /app
 
@@ -136,9 +430,7 @@ RUN apt-get update && apt-get install -y --no-install-recommends \
     && rm -rf /var/lib/apt/lists/*
 
 # Install Python dependencies
-COPY requirements.txt .
-RUN pip install --no-cache-dir -r requirements.txt
-
+` + pythonDepsInstall(tool) + `
 # Copy source code
 COPY --chown=appuser:appuser . .
 
@@ -149,8 +441,13 @@ EXPOSE 8000
 CMD ["python", "manage.py", "runserver", "0.0.0.0:8000"]
 `
 	default:
+		// requirements.txt is optional for the plain template
+		deps := "COPY requirements.txt* .\nRUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; fi\n"
+		if tool.Name != "pip" {
+			deps = pythonDepsInstall(tool)
+		}
 		return `# Python Dockerfile - Generated by stackgen
-FROM python:3.12-slim
+FROM python:` + v + `-slim
 
 WORKDIR /app
 
@@ -158,9 +455,7 @@ WORKDIR /app
 RUN useradd -m -u 1000 appuser
 
 # Install dependencies
-COPY requirements.txt* .
-RUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; fi
-
+` + deps + `
 # Copy source code
 COPY --chown=appuser:appuser . .
 
@@ -173,15 +468,43 @@ CMD ["python", "app.py"]
 	}
 }
 
-// JavaDockerfile returns a Dockerfile for Java applications
-func JavaDockerfile(framework string) string {
+// AddDebugpy installs debugpy in a Dockerfile from PythonDockerfile, ahead
+// of the source copy so it is cached with the dependencies
+func AddDebugpy(dockerfile string) string {
+	return strings.Replace(dockerfile, "# Copy source code\n",
+		"# Debugger\nRUN pip install --no-cache-dir debugpy\n\n# Copy source code\n", 1)
+}
+
+// PythonDebugCommand returns the command starting a Python framework's
+// server under debugpy listening on debugPort
+func PythonDebugCommand(framework string, debugPort int) string {
+	args := "app.py"
+	switch framework {
+	case "fastapi":
+		args = "-m uvicorn main:app --host 0.0.0.0 --port 8000 --reload"
+	case "django":
+		args = "manage.py runserver 0.0.0.0:8000"
+	}
+	return fmt.Sprintf("python -m debugpy --listen 0.0.0.0:%d %s", debugPort, args)
+}
+
+// JavaDebugOptions returns JAVA_TOOL_OPTIONS loading the JDWP agent on
+// debugPort, which every JVM picks up without changing its command
+func JavaDebugOptions(debugPort int) string {
+	return fmt.Sprintf("-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=*:%d", debugPort)
+}
+
+// JavaDockerfile returns a Dockerfile for Java applications on the given
+// toolchain version, or the default when empty
+func JavaDockerfile(framework, version string) string {
+	v := versionOr(version, DefaultJavaVersion)
 	switch framework {
 	case "spring-boot":
 		return `# Spring Boot Dockerfile - Generated by stackgen
 # Multi-stage build
 
 # Build stage
-FROM eclipse-temurin:21-jdk-alpine AS builder
+FROM eclipse-temurin:` + v + `-jdk-alpine AS builder
 
 WORKDIR /app
 
@@ -201,7 +524,7 @@ RUN if [ -f mvnw ]; then ./mvnw package -DskipTests; \
     elif [ -f gradlew ]; then ./gradlew bootJar; fi
 
 # Runtime stage
-FROM eclipse-temurin:21-jre-alpine
+FROM eclipse-temurin:` + v + `-jre-alpine
 
 WORKDIR /app
 
@@ -218,7 +541,7 @@ ENTRYPOINT ["java", "-jar", "app.jar"]
 `
 	default:
 		return `# Java Dockerfile - Generated by stackgen
-FROM eclipse-temurin:21-jdk-alpine
+FROM eclipse-temurin:` + v + `-jdk-alpine
 
 WORKDIR /app
 
@@ -237,13 +560,15 @@ CMD ["java", "-jar", "app.jar"]
 	}
 }
 
-// RustDockerfile returns a Dockerfile for Rust applications
-func RustDockerfile(framework string) string {
+// RustDockerfile returns a Dockerfile for Rust applications on the given
+// toolchain version, or the default when empty
+func RustDockerfile(framework, version string) string {
+	v := versionOr(version, DefaultRustVersion)
 	return `# Rust Dockerfile - Generated by stackgen
 # Multi-stage build for minimal image size
 
 # Build stage
-FROM rust:1.75-alpine AS builder
+FROM rust:` + v + `-alpine AS builder
 
 WORKDIR /app
 
@@ -284,13 +609,15 @@ CMD ["/app/server"]
 `
 }
 
-// CSharpDockerfile returns a Dockerfile for C#/.NET applications
-func CSharpDockerfile(framework string) string {
+// CSharpDockerfile returns a Dockerfile for C#/.NET applications on the given
+// toolchain version, or the default when empty
+func CSharpDockerfile(framework, version string) string {
+	v := versionOr(version, DefaultDotnetVersion)
 	return `# .NET Dockerfile - Generated by stackgen
 # Multi-stage build
 
 # Build stage
-FROM mcr.microsoft.com/dotnet/sdk:8.0-alpine AS builder
+FROM mcr.microsoft.com/dotnet/sdk:` + v + `-alpine AS builder
 
 WORKDIR /app
 
@@ -303,7 +630,7 @@ COPY . ./
 RUN dotnet publish -c Release -o out
 
 # Runtime stage
-FROM mcr.microsoft.com/dotnet/aspnet:8.0-alpine
+FROM mcr.microsoft.com/dotnet/aspnet:` + v + `-alpine
 
 WORKDIR /app
 
@@ -320,6 +647,79 @@ ENTRYPOINT ["dotnet", "app.dll"]
 `
 }
 
+// PostgresReplicationInit returns a primary init script that creates the
+// replication role and allows it in pg_hba.conf
+func PostgresReplicationInit() string {
+	return `# PostgreSQL replication setup - Generated by stackgen
+# Runs once from /docker-entrypoint-initdb.d on first start of the primary
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+	CREATE ROLE replicator WITH REPLICATION LOGIN PASSWORD '$POSTGRES_REPLICATION_PASSWORD';
+EOSQL
+
+echo "host replication replicator all scram-sha-256" >> "$PGDATA/pg_hba.conf"
+`
+}
+
+// PostgresDatabase is an extra database created by PostgresDatabasesInit.
+// With a Role, the database is owned by that login role, whose password is
+// read from the container's PasswordVar environment variable (none when
+// PasswordVar is empty).
+type PostgresDatabase struct {
+	Name        string
+	Role        string
+	PasswordVar string
+}
+
+// PostgresDatabasesInit returns an init script creating extra databases
+// (and their roles) next to POSTGRES_DB, skipping any that already exist
+func PostgresDatabasesInit(databases []PostgresDatabase) string {
+	var b strings.Builder
+	b.WriteString(`# PostgreSQL databases - Generated by stackgen
+# Runs once from /docker-entrypoint-initdb.d on first start
+
+psql -v ON_ERROR_STOP=1 --username "$POSTGRES_USER" --dbname "$POSTGRES_DB" <<-EOSQL
+`)
+	for _, db := range databases {
+		owner := ""
+		if db.Role != "" {
+			password := ""
+			if db.PasswordVar != "" {
+				password = fmt.Sprintf(" PASSWORD '$%s'", db.PasswordVar)
+			}
+			// \$ keeps the shell from expanding the dollar quotes
+			b.WriteString("\tDO \\$\\$ BEGIN\n")
+			fmt.Fprintf(&b, "\t\tIF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = '%s') THEN\n", db.Role)
+			fmt.Fprintf(&b, "\t\t\tCREATE ROLE \"%s\" LOGIN%s;\n", db.Role, password)
+			b.WriteString("\t\tEND IF;\n\tEND \\$\\$;\n")
+			owner = fmt.Sprintf(" OWNER \"%s\"", db.Role)
+		}
+		fmt.Fprintf(&b, "\tSELECT 'CREATE DATABASE \"%s\"%s' WHERE NOT EXISTS (SELECT FROM pg_database WHERE datname = '%s')\\gexec\n", db.Name, owner, db.Name)
+	}
+	b.WriteString("EOSQL\n")
+	return b.String()
+}
+
+// PostgresReplicaEntrypoint returns a replica start script that clones the
+// primary on first start and then runs postgres as a hot standby
+func PostgresReplicaEntrypoint() string {
+	return `#!/bin/sh
+# PostgreSQL replica entrypoint - Generated by stackgen
+set -e
+
+if [ ! -s "$PGDATA/PG_VERSION" ]; then
+  echo "Cloning primary $PRIMARY_HOST..."
+  until pg_basebackup -h "$PRIMARY_HOST" -D "$PGDATA" -U replicator -R -X stream; do
+    echo "Waiting for primary..."
+    sleep 2
+  done
+  chmod 0700 "$PGDATA"
+fi
+
+exec postgres
+`
+}
+
 // GitIgnore returns a .gitignore file for stackgen projects
 func GitIgnore() string {
 	return `# stackgen generated .gitignore
@@ -328,6 +728,7 @@ func GitIgnore() string {
 .env
 .env.local
 .env.*.local
+.env.test
 
 # Docker volumes (if using bind mounts)
 data/
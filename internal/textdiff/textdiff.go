@@ -0,0 +1,142 @@
+// Package textdiff renders line diffs between two versions of a generated
+// file
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Line kinds, printed as the line prefix in unified output
+const (
+	Equal  = ' '
+	Delete = '-'
+	Insert = '+'
+)
+
+// Line is one line of an edit script
+type Line struct {
+	Kind byte
+	Text string
+}
+
+// splitLines splits s into lines without their newlines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Lines returns the edit script turning a into b, keeping the longest
+// common subsequence of lines unchanged
+func Lines(a, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]Line, 0, len(x)+len(y))
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, Line{Equal, x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Delete, x[i]})
+			i++
+		default:
+			lines = append(lines, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, Line{Delete, x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, Line{Insert, y[j]})
+	}
+	return lines
+}
+
+// Hunks groups the changes of an edit script into unified diff hunks with
+// up to context unchanged lines around them. Each hunk starts with its
+// "@@ -a,n +b,m @@" header; an empty result means a and b are equal.
+func Hunks(a, b string, context int) [][]string {
+	lines := Lines(a, b)
+
+	// Line numbers in a and b before each script line
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.Kind != Insert {
+			aPos[i+1]++
+		}
+		if l.Kind != Delete {
+			bPos[i+1]++
+		}
+	}
+
+	var hunks [][]string
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].Kind == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Extend over changes separated by at most 2*context equal lines
+		end := first
+		for {
+			for end < len(lines) && lines[end].Kind != Equal {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].Kind == Equal {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+
+		from := max(start, first-context)
+		to := min(len(lines), end+context)
+		hunk := []string{fmt.Sprintf("@@ -%s +%s @@",
+			hunkRange(aPos[from], aPos[to]-aPos[from]),
+			hunkRange(bPos[from], bPos[to]-bPos[from]))}
+		for _, l := range lines[from:to] {
+			hunk = append(hunk, string(l.Kind)+l.Text)
+		}
+		hunks = append(hunks, hunk)
+		start = to
+	}
+	return hunks
+}
+
+// hunkRange formats a hunk's start line and length; empty ranges point at
+// the line before them, as in diff -u
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
@@ -0,0 +1,47 @@
+package textdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	lines := Lines("a\nb\nc\n", "a\nc\nd\n")
+	var got []string
+	for _, l := range lines {
+		got = append(got, string(l.Kind)+l.Text)
+	}
+	want := []string{" a", "-b", " c", "+d"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Lines = %v, want %v", got, want)
+	}
+}
+
+func TestHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		line := string(rune('a' + i))
+		a = append(a, line)
+		b = append(b, line)
+	}
+	b[1] = "B"
+	b[15] = "P"
+
+	hunks := Hunks(strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n", 3)
+	if len(hunks) != 2 {
+		t.Fatalf("Distant changes should form 2 hunks, got %d", len(hunks))
+	}
+	if hunks[0][0] != "@@ -1,5 +1,5 @@" {
+		t.Errorf("First hunk header = %q", hunks[0][0])
+	}
+	if hunks[1][0] != "@@ -13,7 +13,7 @@" {
+		t.Errorf("Second hunk header = %q", hunks[1][0])
+	}
+
+	if len(Hunks("same\n", "same\n", 3)) != 0 {
+		t.Error("Equal inputs should have no hunks")
+	}
+	if got := Hunks("", "new\n", 3); len(got) != 1 || got[0][0] != "@@ -0,0 +1,1 @@" {
+		t.Errorf("A new file should be one insert hunk, got %v", got)
+	}
+}
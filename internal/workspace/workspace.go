@@ -0,0 +1,117 @@
+// Package workspace reads stackgen.workspace.yaml, which groups several
+// stackgen projects kept in subdirectories
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the workspace file stackgen looks for
+const FileName = "stackgen.workspace.yaml"
+
+// ConfigName is the project config expected in each member directory
+const ConfigName = "stackgen.yaml"
+
+// Workspace lists member project directories, relative to the workspace
+// file. Earlier members keep their host ports when ports are allocated
+// across the workspace.
+type Workspace struct {
+	Members []string `yaml:"members"`
+
+	dir string
+}
+
+// Load reads a workspace file and checks that every member has a config
+func Load(path string) (*Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("workspace file not found: %s\nRun 'stackgen workspace init' to create one", path)
+		}
+		return nil, fmt.Errorf("failed to read workspace file: %w", err)
+	}
+	ws := &Workspace{}
+	if err := yaml.Unmarshal(data, ws); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace file: %w", err)
+	}
+	if len(ws.Members) == 0 {
+		return nil, fmt.Errorf("%s lists no members", path)
+	}
+	ws.dir = filepath.Dir(path)
+
+	seen := make(map[string]bool, len(ws.Members))
+	for _, member := range ws.Members {
+		dir := ws.MemberDir(member)
+		if seen[dir] {
+			return nil, fmt.Errorf("member %s is listed twice", member)
+		}
+		seen[dir] = true
+		if _, err := os.Stat(filepath.Join(dir, ConfigName)); err != nil {
+			return nil, fmt.Errorf("member %s has no %s", member, ConfigName)
+		}
+	}
+	return ws, nil
+}
+
+// MemberDir returns the directory of a member, resolved against the
+// workspace file's directory
+func (w *Workspace) MemberDir(member string) string {
+	if filepath.IsAbs(member) {
+		return filepath.Clean(member)
+	}
+	return filepath.Join(w.dir, member)
+}
+
+// Discover returns the subdirectories of dir, up to depth levels down,
+// that contain a stackgen.yaml, relative to dir and sorted
+func Discover(dir string, depth int) ([]string, error) {
+	var members []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if rel == "." {
+			return nil
+		}
+		if d.Name()[0] == '.' || d.Name() == "node_modules" || d.Name() == "vendor" {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, ConfigName)); err == nil {
+			members = append(members, filepath.ToSlash(rel))
+		}
+		if depthOf(rel) >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// depthOf counts the path elements of a relative path
+func depthOf(rel string) int {
+	n := 1
+	for _, c := range filepath.ToSlash(rel) {
+		if c == '/' {
+			n++
+		}
+	}
+	return n
+}
+
+// Marshal renders a workspace file
+func (w *Workspace) Marshal() ([]byte, error) {
+	return yaml.Marshal(w)
+}
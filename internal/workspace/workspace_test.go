@@ -0,0 +1,67 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigName), []byte("name: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, filepath.Join(root, "api"))
+	writeConfig(t, filepath.Join(root, "services", "billing"))
+	writeConfig(t, filepath.Join(root, "services", "deep", "too-deep"))
+	writeConfig(t, filepath.Join(root, "node_modules", "pkg"))
+	writeConfig(t, filepath.Join(root, ".hidden"))
+
+	members, err := Discover(root, 2)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	want := []string{"api", "services/billing"}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("Discover = %v, want %v", members, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, filepath.Join(root, "api"))
+	path := filepath.Join(root, FileName)
+
+	if err := os.WriteFile(path, []byte("members:\n  - api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := ws.MemberDir("api"); got != filepath.Join(root, "api") {
+		t.Errorf("MemberDir = %s, want it resolved against the workspace file", got)
+	}
+
+	for content, want := range map[string]string{
+		"members: []\n":                 "no members",
+		"members:\n  - api\n  - api/\n": "listed twice",
+		"members:\n  - billing\n":       "has no stackgen.yaml",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Load(%q) error = %v, want %q", content, err, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package yamledit rewrites YAML files while keeping the comments users
+// added to the version being replaced
+package yamledit
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal encodes v like yaml.Marshal and carries over comments from
+// previous. Mapping keys are matched by name and sequence items by their
+// name field, falling back to position; comments on removed entries are
+// dropped.
+func Marshal(v interface{}, previous []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode: %w", err)
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{&node}}
+
+	var old yaml.Node
+	if len(previous) > 0 && yaml.Unmarshal(previous, &old) == nil && old.Kind == yaml.DocumentNode {
+		copyComments(doc, &old)
+	}
+	return yaml.Marshal(doc)
+}
+
+// copyComments copies comments from src onto the matching parts of dst
+func copyComments(dst, src *yaml.Node) {
+	dst.HeadComment = src.HeadComment
+	dst.LineComment = src.LineComment
+	dst.FootComment = src.FootComment
+
+	if dst.Kind != src.Kind {
+		return
+	}
+	switch dst.Kind {
+	case yaml.DocumentNode:
+		if len(dst.Content) > 0 && len(src.Content) > 0 {
+			copyComments(dst.Content[0], src.Content[0])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			if j := keyIndex(src, dst.Content[i].Value); j >= 0 {
+				copyComments(dst.Content[i], src.Content[j])
+				copyComments(dst.Content[i+1], src.Content[j+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range dst.Content {
+			if match := sequenceMatch(src, item, i); match != nil {
+				copyComments(item, match)
+			}
+		}
+	}
+}
+
+// keyIndex returns the index of key in a mapping node, or -1
+func keyIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// sequenceMatch finds the item in src that corresponds to item at index i:
+// the mapping with the same name, or for unnamed items the one at i
+func sequenceMatch(src, item *yaml.Node, i int) *yaml.Node {
+	if name := nameOf(item); name != "" {
+		for _, candidate := range src.Content {
+			if nameOf(candidate) == name {
+				return candidate
+			}
+		}
+		return nil
+	}
+	if i < len(src.Content) {
+		return src.Content[i]
+	}
+	return nil
+}
+
+// nameOf returns the name field of a mapping node
+func nameOf(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	if j := keyIndex(node, "name"); j >= 0 {
+		return node.Content[j+1].Value
+	}
+	return ""
+}
@@ -0,0 +1,62 @@
+package yamledit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stackgen-cli/stackgen/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalKeepsComments(t *testing.T) {
+	previous := []byte(`# Shared dev stack for the billing team
+name: billing
+output_dir: .
+datastores:
+    # Pinned to match production
+    - type: postgres
+      name: postgres
+      port: 5433 # 5432 is taken by the host database
+      internal_port: 5432
+      tag: "15"
+`)
+	project := &models.Project{}
+	if err := yaml.Unmarshal(previous, project); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	// Mirror an add: a new datastore goes in front of the commented one
+	project.Datastores = append([]models.Datastore{{Type: models.DatastoreRedis, Name: "redis", Port: 6379}}, project.Datastores...)
+
+	data, err := Marshal(project, previous)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	out := string(data)
+
+	for _, comment := range []string{
+		"# Shared dev stack for the billing team",
+		"# Pinned to match production",
+		"port: 5433 # 5432 is taken by the host database",
+	} {
+		if !strings.Contains(out, comment) {
+			t.Errorf("Output should keep %q, got:\n%s", comment, out)
+		}
+	}
+	if strings.Index(out, "# Pinned to match production") < strings.Index(out, "name: redis") {
+		t.Error("Comments should follow their datastore, not its old position")
+	}
+}
+
+func TestMarshalWithoutPrevious(t *testing.T) {
+	project := &models.Project{Name: "fresh"}
+
+	data, err := Marshal(project, nil)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want, _ := yaml.Marshal(project)
+	if string(data) != string(want) {
+		t.Errorf("Marshal without previous content should match yaml.Marshal, got:\n%s", data)
+	}
+}